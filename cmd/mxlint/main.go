@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"time"
 
 	"github.com/cinaq/mendix-cli/lint"
@@ -26,27 +28,379 @@ func main() {
 			outputDirectory, _ := cmd.Flags().GetString("output")
 			raw, _ := cmd.Flags().GetBool("raw")
 			mode, _ := cmd.Flags().GetString("mode")
+			format, _ := cmd.Flags().GetString("format")
+			layout, _ := cmd.Flags().GetString("layout")
+			fileNameTemplate, _ := cmd.Flags().GetString("filename-template")
+			moduleDirTemplate, _ := cmd.Flags().GetString("module-dir-template")
+			workers, _ := cmd.Flags().GetInt("workers")
+			fileWorkers, _ := cmd.Flags().GetInt("file-workers")
+			modules, _ := cmd.Flags().GetStringArray("module")
+			excludeTypes, _ := cmd.Flags().GetStringArray("exclude-type")
+			skip, _ := cmd.Flags().GetStringArray("skip")
+			stripKeys, _ := cmd.Flags().GetStringArray("strip-key")
+			nameFilter, _ := cmd.Flags().GetString("name-filter")
+			since, _ := cmd.Flags().GetString("since")
+			rootFolderName, _ := cmd.Flags().GetString("root-folder-name")
+			strictFolders, _ := cmd.Flags().GetBool("strict-folders")
+			strict, _ := cmd.Flags().GetBool("strict")
+			includeContainments, _ := cmd.Flags().GetStringArray("include-containment")
+			replaceContainments, _ := cmd.Flags().GetBool("replace-containments")
+			continueOnError, _ := cmd.Flags().GetBool("continue-on-error")
+			lockRetries, _ := cmd.Flags().GetInt("lock-retries")
+			lockTimeout, _ := cmd.Flags().GetDuration("lock-timeout")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			archive, _ := cmd.Flags().GetBool("archive")
+			incremental, _ := cmd.Flags().GetBool("incremental")
+			checksums, _ := cmd.Flags().GetBool("checksums")
+			gitIgnore, _ := cmd.Flags().GetBool("gitignore")
+			metadataOnly, _ := cmd.Flags().GetBool("metadata-only")
+			validateRoundtrip, _ := cmd.Flags().GetBool("validate-roundtrip")
+			encryptKey, _ := cmd.Flags().GetString("encrypt-key")
+			if encryptKey == "" {
+				encryptKey = os.Getenv("MXLINT_ENCRYPT_KEY")
+			}
+			perFileSubdir, _ := cmd.Flags().GetBool("per-file-subdir")
+			onCollision, _ := cmd.Flags().GetString("on-collision")
+			onDuplicateModule, _ := cmd.Flags().GetString("on-duplicate-module")
+			graphML, _ := cmd.Flags().GetBool("graphml")
+			entitiesSummary, _ := cmd.Flags().GetBool("entities-summary")
+			emitTree, _ := cmd.Flags().GetBool("emit-tree")
+			emitDiagrams, _ := cmd.Flags().GetBool("emit-diagrams")
+			microflowMetrics, _ := cmd.Flags().GetBool("microflow-metrics")
+			embedMetrics, _ := cmd.Flags().GetBool("embed-microflow-metrics")
+			emitBSON, _ := cmd.Flags().GetBool("emit-bson")
+			links, _ := cmd.Flags().GetBool("links")
+			publicAPI, _ := cmd.Flags().GetBool("public-api")
+			splitModules, _ := cmd.Flags().GetBool("split-modules")
+			translations, _ := cmd.Flags().GetBool("translations")
+			languageTexts, _ := cmd.Flags().GetBool("language-texts")
+			normalizeIDs, _ := cmd.Flags().GetBool("normalize-ids")
+			includeIDs, _ := cmd.Flags().GetBool("include-ids")
+			idEncoding, _ := cmd.Flags().GetString("id-encoding")
+			resolveReferences, _ := cmd.Flags().GetBool("resolve-references")
+			delta, _ := cmd.Flags().GetBool("delta")
+			captionLanguages, _ := cmd.Flags().GetStringSlice("caption-languages")
+			codeOwners, _ := cmd.Flags().GetString("codeowners")
+			contentStore, _ := cmd.Flags().GetString("content-store")
+			redact, _ := cmd.Flags().GetStringSlice("redact")
+			timeZone, _ := cmd.Flags().GetString("timezone")
+			timeFormat, _ := cmd.Flags().GetString("time-format")
+			maxStringLength, _ := cmd.Flags().GetInt("max-string-length")
+			largeStrings, _ := cmd.Flags().GetString("large-strings")
+			maxFileBytes, _ := cmd.Flags().GetInt("max-file-bytes")
+			maxUnitBytes, _ := cmd.Flags().GetInt("max-unit-bytes")
+			logFile, _ := cmd.Flags().GetBool("log-file")
 			verbose, _ := cmd.Flags().GetBool("verbose")
+			quiet, _ := cmd.Flags().GetBool("quiet")
 
 			log := logrus.New()
 			if verbose {
 				log.SetLevel(logrus.DebugLevel)
+			} else if quiet {
+				log.SetLevel(logrus.WarnLevel)
 			} else {
 				log.SetLevel(logrus.InfoLevel)
 			}
 
 			mpr.SetLogger(log)
-			mpr.ExportModel(inputDirectory, outputDirectory, raw, mode)
+			var sinceTime time.Time
+			if since != "" {
+				var err error
+				if sinceTime, err = time.Parse(time.RFC3339, since); err != nil {
+					log.Errorf("Invalid --since %s, expected RFC3339 like 2024-01-31T00:00:00Z: %s", since, err)
+					os.Exit(1)
+				}
+			}
+			options := mpr.ExportOptions{
+				Raw:                 raw,
+				Mode:                mode,
+				Format:              format,
+				Layout:              layout,
+				FileNameTemplate:    fileNameTemplate,
+				ModuleDirTemplate:   moduleDirTemplate,
+				Workers:             workers,
+				FileWorkers:         fileWorkers,
+				Modules:             modules,
+				ExcludeTypes:        excludeTypes,
+				Skip:                skip,
+				StripKeys:           stripKeys,
+				NameFilter:          nameFilter,
+				Since:               sinceTime,
+				RootFolderName:      rootFolderName,
+				StrictFolders:       strictFolders,
+				Strict:              strict,
+				IncludeContainments: includeContainments,
+				ReplaceContainments: replaceContainments,
+				ContinueOnError:     continueOnError,
+				LockRetries:         lockRetries,
+				LockTimeout:         lockTimeout,
+				DryRun:              dryRun,
+				Archive:             archive,
+				Incremental:         incremental,
+				Checksums:           checksums,
+				GitIgnore:           gitIgnore,
+				MetadataOnly:        metadataOnly,
+				ValidateRoundtrip:   validateRoundtrip,
+				EncryptKey:          encryptKey,
+				MergeMetadata:       !perFileSubdir,
+				OnCollision:         onCollision,
+				OnDuplicateModule:   onDuplicateModule,
+				GraphML:             graphML,
+				EntitiesSummary:     entitiesSummary,
+				EmitTree:            emitTree,
+				EmitDiagrams:        emitDiagrams,
+				MicroflowMetrics:    microflowMetrics,
+				EmbedMetrics:        embedMetrics,
+				EmitBSON:            emitBSON,
+				Links:               links,
+				PublicAPI:           publicAPI,
+				SplitModules:        splitModules,
+				Translations:        translations,
+				LanguageTexts:       languageTexts,
+				LogFile:             logFile,
+				NormalizeIDs:        normalizeIDs,
+				IncludeIDs:          includeIDs,
+				IDEncoding:          idEncoding,
+				ResolveReferences:   resolveReferences,
+				Delta:               delta,
+				CaptionLanguages:    captionLanguages,
+				CodeOwners:          codeOwners,
+				ContentStore:        contentStore,
+				Redact:              redact,
+				TimeZone:            timeZone,
+				TimeFormat:          timeFormat,
+				MaxStringLength:     maxStringLength,
+				LargeStrings:        largeStrings,
+				MaxFileBytes:        maxFileBytes,
+				MaxUnitBytes:        maxUnitBytes,
+				Progress:            logProgress(log),
+			}
+			if err := mpr.ExportModel(inputDirectory, outputDirectory, options); err != nil {
+				log.Errorf("Export failed: %s", err)
+				os.Exit(1)
+			}
 		},
 	}
 
 	cmdExportModel.Flags().StringP("input", "i", ".", "Path to directory or mpr file to export. If it's a directory, all mpr files will be exported")
-	cmdExportModel.Flags().StringP("output", "o", "modelsource", "Path to directory to write the yaml files. If it doesn't exist, it will be created")
-	cmdExportModel.Flags().StringP("mode", "m", "basic", "Export mode. Valid options: basic, advanced")
+	cmdExportModel.Flags().StringP("output", "o", "modelsource", "Path to directory to write the yaml files. If it doesn't exist, it will be created. Use s3://bucket/prefix to upload the files to an S3 compatible object store; credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION, and AWS_ENDPOINT_URL selects a store other than AWS")
+	cmdExportModel.Flags().StringP("mode", "m", "basic", "Export mode. Valid options: basic, advanced, headers, schema-stats. The headers mode only writes Catalog.yaml with the name, type, path and documentation of every document. The schema-stats mode only writes schema-stats.yaml with the number of occurrences and the value types of every key path in the units, to help map the format of the model")
+	cmdExportModel.Flags().String("format", "yaml", "Output format of the exported files. Valid options: yaml, json, xml, properties. In xml, keys starting with $ become attributes and list items repeat the element of their key. In properties, every scalar value is a line with a dotted key, e.g. ObjectCollection.Objects[0].$Type")
+	cmdExportModel.Flags().String("layout", "tree", "Layout of the exported files. Valid options: tree (a file per document in the folder structure), flat-module (a single <Module>.yaml per module with the list of its documents), by-type (the folder structure below a directory per document type, e.g. Microflows$Microflow/MyModule/...)")
+	cmdExportModel.Flags().String("filename-template", "", "Go template for the file names of documents relative to their folder, with the fields {{.Name}}, {{.Type}} and {{.Path}}, e.g. '{{.Type}}/{{.Name}}'. The extension is appended. Defaults to {{.Name}}.{{.Type}}. Documents without a name keep their default file name")
+	cmdExportModel.Flags().String("module-dir-template", "", "Go template for the directory of every module, e.g. '{{.Name}}-{{.Version}}' or '{{.Name}}-{{.Attributes.AppStoreVersion}}', so exports of different model versions do not overwrite each other. Fields: Name, Version, Source, Attributes. Modules whose template references a missing attribute keep their name")
+	cmdExportModel.Flags().Int("workers", runtime.NumCPU(), "Number of documents written concurrently")
+	cmdExportModel.Flags().Int("file-workers", 1, "Number of mpr files exported concurrently when the input holds several. Only applies with --per-file-subdir, as merged exports share the output directory")
+	cmdExportModel.Flags().StringArray("include-containment", []string{}, "Also export units with this containment name. Can be repeated. The containment names in the model that are not exported are logged")
+	cmdExportModel.Flags().Bool("replace-containments", false, "If set, only the units with the --include-containment names are exported instead of the defaults: ProjectDocuments, DomainModel, ModuleSettings, ModuleSecurity, Documents")
+	cmdExportModel.Flags().StringArray("exclude-type", []string{}, "Skip documents of this type. Can be repeated. Accepts a full type like Projects$ModuleSettings or a prefix like Projects")
+	cmdExportModel.Flags().StringArray("skip", mpr.DefaultSkipPaths, "Do not search files and directories below the input directory whose name matches this pattern, e.g. 'backup*', for mpr files. Can be repeated. Setting it replaces the default list of system managed directories")
+	cmdExportModel.Flags().StringArray("strip-key", mpr.DefaultStripKeys, "Remove attributes whose key matches this pattern, e.g. '*BezierVector', from all documents. Can be repeated. Setting it replaces the default list of attributes that change on every save or only hold editor layout. Ignored with --raw")
+	cmdExportModel.Flags().String("root-folder-name", "", "Name of the directory the project documents and modules are written to. By default they are written to the output directory itself")
+	cmdExportModel.Flags().Bool("strict-folders", false, "If set, the export fails when the parent of a folder is missing from the model. Otherwise a warning is logged and its documents are exported at a shallower path")
+	cmdExportModel.Flags().Bool("strict", false, "If set, the export fails when documents are not attached to any module because their container chain is broken, or when a unit cannot be decoded or exceeds --max-unit-bytes. Otherwise they are logged as warnings and such units are skipped")
+	cmdExportModel.Flags().String("since", "", "Only export documents changed after this RFC3339 time. Not supported yet: mpr files do not record when units were changed, so the export fails. Use diff-model or --incremental instead")
+	cmdExportModel.Flags().String("name-filter", "", "Only export documents whose name matches this regular expression. Combine with --dry-run to search the model")
+	cmdExportModel.Flags().StringArray("module", []string{}, "Only export documents of this module. Can be repeated. Matching ignores case and supports a trailing wildcard, e.g. MyModule*. Metadata still lists all modules")
+	cmdExportModel.Flags().Int("lock-retries", 5, "Number of times reading an mpr file is retried with increasing delays while it is locked, e.g. because it is open in Studio Pro")
+	cmdExportModel.Flags().Duration("lock-timeout", 30*time.Second, "Maximum time spent retrying a locked mpr file")
+	cmdExportModel.Flags().Bool("continue-on-error", false, "If set, the remaining mpr files are still exported when one fails. The command fails at the end if any export failed")
+	cmdExportModel.Flags().String("on-collision", "error", "What to do when documents resolve to the same file. Valid options: error, suffix (append part of the unit ID to the file name), overwrite")
+	cmdExportModel.Flags().String("on-duplicate-module", "warn", "What to do when modules share a name. Valid options: warn (the conflicting unit IDs are logged), error, suffix (append part of the unit ID to the module directory)")
+	cmdExportModel.Flags().Bool("per-file-subdir", true, "If the input contains multiple mpr files, export each into a subdirectory named after its path. If disabled, all are exported into the output directory with a single Metadata.yaml keyed by mpr file")
+	cmdExportModel.Flags().Bool("dry-run", false, "If set, nothing is written. The files that would be written are logged with their estimated size and path collisions are reported")
+	cmdExportModel.Flags().Bool("incremental", false, "If set, mpr files that did not change since the previous export are skipped. Their hashes are kept in export-state.yaml in the output directory")
+	cmdExportModel.Flags().Bool("checksums", false, "If set, the sha256 of every file in the output directory is written to checksums.yaml. Use verify-export to check the files against it")
+	cmdExportModel.Flags().Bool("gitignore", false, "If set, the auxiliary files of the export, like export-state.yaml, checksums.yaml, export.log and bson sidecars, are added to the .gitignore in the output directory")
+	cmdExportModel.Flags().Bool("metadata-only", false, "If set, only the metadata with the modules and versions is written, i.e. Metadata.yaml and the Module.yaml files. Only module units are read from the mpr file, which makes it fast for inventorying many models")
+	cmdExportModel.Flags().Bool("validate-roundtrip", false, "If set, every written document and the metadata are parsed again and compared to the exported values. The export fails with the key path of the first value that does not survive serialization. Doubles the cost of marshaling")
+	cmdExportModel.Flags().String("encrypt-key", "", "Passphrase every written file is encrypted with using AES-256-GCM. Files get an .enc suffix and the key derivation parameters are written to encryption.yaml. Defaults to the MXLINT_ENCRYPT_KEY environment variable. Use decrypt-export to read them")
+	cmdExportModel.Flags().Bool("archive", false, "If set, the output path is a .tar.gz file that receives the exported files instead of a directory. Extracting it reproduces the regular output")
 	cmdExportModel.Flags().Bool("raw", false, "If set, the output yaml will include all attributes as they are in the model. Otherwise, only the relevant attributes are included. You should never need this. Only useful when you are developing new functionalities for this tool.")
+	cmdExportModel.Flags().Bool("emit-diagrams", false, "If set, a Mermaid flowchart (.mmd) is written next to every microflow. Requires advanced mode")
+	cmdExportModel.Flags().Bool("microflow-metrics", false, "If set, microflow-metrics.yaml is written to the output directory with the number of activities, decisions and loops and the cyclomatic complexity of every microflow. Requires advanced mode")
+	cmdExportModel.Flags().Bool("embed-microflow-metrics", false, "If set, every exported microflow gets Metrics with its number of activities, decisions and loops and its cyclomatic complexity. Requires advanced mode")
+	cmdExportModel.Flags().Bool("emit-bson", false, "If set, the original BSON of every document as stored in the mpr file is written to a .bson file next to it. The yaml stays readable while the bson keeps the exact types, e.g. for importing the model again. Cannot be combined with --redact or a custom --strip-key")
+	cmdExportModel.Flags().Bool("emit-tree", false, "If set, tree.yaml is written to the output directory with the nested module and folder names of the project. Folders whose parent could not be resolved are listed separately")
+	cmdExportModel.Flags().Bool("entities-summary", false, "If set, entities.yaml is written to the output directory with every entity of the model, its attributes with their types and its associations. Useful for generating documentation or API clients.")
+	cmdExportModel.Flags().Bool("graphml", false, "If set, the object reference graph of the whole model is written to graph.graphml in the output directory. Useful for analyzing coupling with tools like Gephi.")
+	cmdExportModel.Flags().Bool("links", false, "If set, every resolved reference is written to links.yaml in the output directory as a source document, source field and target document. Useful for impact analysis.")
+	cmdExportModel.Flags().Bool("public-api", false, "If set, a PublicAPI.yaml is written per module listing its exposed microflows, Java actions, published services and entities. Useful for teams consuming shared modules.")
+	cmdExportModel.Flags().Bool("split-modules", false, "If set, every module is exported on its own into a directory named after the module, or a <module>.tar.gz with --archive, that holds only its documents and a Metadata.yaml scoped to that module, so it can be distributed to its owners. The input must hold a single mpr file")
+	cmdExportModel.Flags().Bool("translations", false, "If set, every translatable text is written with all its languages to translations.yaml in the output directory. Useful for localization teams to audit coverage.")
+	cmdExportModel.Flags().Bool("language-texts", false, "If set, a texts.<language>.yaml is written per language to the output directory mapping a stable key of every translatable text (document file#key path) to its text. Useful for translation review.")
+	cmdExportModel.Flags().Bool("normalize-ids", false, "If set, all identifiers are written as lowercase hex UUIDs instead of a mix of base64 strings and binary values. Makes it easier to join references in external tools.")
+	cmdExportModel.Flags().Bool("include-ids", false, "If set, every document gets _UnitID and _ContainerID with the base64 IDs of its row in the Unit table. Useful to correlate exported files with the mpr database.")
+	cmdExportModel.Flags().String("id-encoding", "base64", "Encoding of unit and container IDs, e.g. the module IDs in Metadata.yaml and _UnitID. Valid options: base64, base64url (without padding), hex. The latter two are safe in file names and URLs")
+	cmdExportModel.Flags().Bool("resolve-references", false, "If set, document IDs in microflows and pages get a _<Key>Ref next to them with the name and type of the referenced document. The IDs are kept. Requires advanced mode")
+	cmdExportModel.Flags().Bool("delta", false, "If set, only attributes that differ from their type default are written. Defaults are inferred from the most common value per type across the model and written to defaults.yaml.")
+	cmdExportModel.Flags().StringSlice("caption-languages", []string{}, "Resolve translated texts to a single caption using this fallback chain of languages, e.g. nl,en,*. A language matches the full code (en_US) or the language part (en); * matches any language. If not provided, all translations are exported.")
+	cmdExportModel.Flags().String("codeowners", "", "Path to a yaml file mapping module names to owning teams, e.g. MyFirstModule: '@org/team'. If provided, a CODEOWNERS file assigning each exported document to its owners is written to the output directory.")
+	cmdExportModel.Flags().String("content-store", "", "Path to a shared object store directory. If provided, documents are stored there by content hash and the output directory only gets a manifest.yaml mapping document paths to hashes. Unchanged documents of different exports share storage.")
+	cmdExportModel.Flags().StringSlice("redact", []string{}, "Regular expressions on dotted key paths, e.g. 'DefaultValue$'. Values below matching keys are replaced by REDACTED in all documents while the structure is kept. Useful for sharing a model without its business data. Cannot be combined with --links and --graphml")
+	cmdExportModel.Flags().String("timezone", "UTC", "Timezone timestamps in the model are written in, e.g. Europe/Amsterdam")
+	cmdExportModel.Flags().String("time-format", "2006-01-02T15:04:05Z07:00", "Go layout timestamps in the model are written with. Defaults to RFC3339")
+	cmdExportModel.Flags().Int("max-string-length", 0, "Strings longer than this number of characters are handled according to --large-strings. 0 disables it")
+	cmdExportModel.Flags().String("large-strings", "externalize", "How to handle strings longer than --max-string-length. Valid options: truncate, externalize. Externalized strings are written to sidecar files next to the document and replaced by a reference")
+	cmdExportModel.Flags().Int("max-file-bytes", 0, "Documents whose serialized size exceeds this number of bytes are written as a directory with numbered part files and an index.yaml instead of a single file. 0 disables it")
+	cmdExportModel.Flags().Int("max-unit-bytes", 0, "Units whose BSON contents exceed this number of bytes are skipped without decoding them, which guards against huge documents in untrusted models. With --strict the export fails instead. 0 disables it")
+	cmdExportModel.Flags().Bool("log-file", false, "If set, info, warning and error logs of the run are also written as ndjson to export.log in the output directory.")
 	cmdExportModel.Flags().Bool("verbose", false, "Turn on for debug logs")
+	cmdExportModel.Flags().Bool("quiet", false, "Only log warnings and errors")
 	rootCmd.AddCommand(cmdExportModel)
 
+	var cmdImportModel = &cobra.Command{
+		Use:   "import-model",
+		Short: "Rebuild an mpr file from exported yaml files",
+		Long:  "Best-effort reverse of export-model. Units and metadata are restored; use an export made with --raw to keep all attributes and IDs.",
+		Run: func(cmd *cobra.Command, args []string) {
+			inputDirectory, _ := cmd.Flags().GetString("input")
+			outputFile, _ := cmd.Flags().GetString("output")
+			verbose, _ := cmd.Flags().GetBool("verbose")
+
+			log := logrus.New()
+			if verbose {
+				log.SetLevel(logrus.DebugLevel)
+			} else {
+				log.SetLevel(logrus.InfoLevel)
+			}
+
+			mpr.SetLogger(log)
+			if err := mpr.ImportModel(inputDirectory, outputFile, mpr.ImportOptions{}); err != nil {
+				log.Errorf("Import failed: %s", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmdImportModel.Flags().StringP("input", "i", "modelsource", "Path to directory with exported model")
+	cmdImportModel.Flags().StringP("output", "o", "App.mpr", "Path of the mpr file to create. It must not exist yet")
+	cmdImportModel.Flags().Bool("verbose", false, "Turn on for debug logs")
+	rootCmd.AddCommand(cmdImportModel)
+
+	var cmdVerifyExport = &cobra.Command{
+		Use:   "verify-export",
+		Short: "Verify exported files against the checksums written by export-model --checksums",
+		Run: func(cmd *cobra.Command, args []string) {
+			outputDirectory, _ := cmd.Flags().GetString("input")
+
+			log := logrus.New()
+			log.SetLevel(logrus.InfoLevel)
+
+			mpr.SetLogger(log)
+			if err := mpr.VerifyExport(outputDirectory); err != nil {
+				log.Errorf("Verification failed: %s", err)
+				os.Exit(1)
+			}
+			log.Infof("All files in %s match their checksums", outputDirectory)
+		},
+	}
+
+	cmdVerifyExport.Flags().StringP("input", "i", "modelsource", "Path to directory with exported model")
+	rootCmd.AddCommand(cmdVerifyExport)
+
+	var cmdDecryptExport = &cobra.Command{
+		Use:   "decrypt-export",
+		Short: "Decrypt the files written by export-model --encrypt-key",
+		Run: func(cmd *cobra.Command, args []string) {
+			inputDirectory, _ := cmd.Flags().GetString("input")
+			outputDirectory, _ := cmd.Flags().GetString("output")
+			encryptKey, _ := cmd.Flags().GetString("encrypt-key")
+			if encryptKey == "" {
+				encryptKey = os.Getenv("MXLINT_ENCRYPT_KEY")
+			}
+
+			log := logrus.New()
+			log.SetLevel(logrus.InfoLevel)
+
+			mpr.SetLogger(log)
+			if err := mpr.DecryptExport(inputDirectory, outputDirectory, encryptKey); err != nil {
+				log.Errorf("Decryption failed: %s", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmdDecryptExport.Flags().StringP("input", "i", "modelsource", "Path to directory with the encrypted export")
+	cmdDecryptExport.Flags().StringP("output", "o", "modelsource-decrypted", "Path to directory to write the decrypted files to")
+	cmdDecryptExport.Flags().String("encrypt-key", "", "Passphrase the export was encrypted with. Defaults to the MXLINT_ENCRYPT_KEY environment variable")
+	rootCmd.AddCommand(cmdDecryptExport)
+
+	var cmdList = &cobra.Command{
+		Use:   "list",
+		Short: "List the modules and the number of documents per type of an mpr file without exporting it",
+		Long:  "Prints a tab separated line per module (module, name, version) and per document type (type, name, count) to stdout. Nothing is written to disk.",
+		Run: func(cmd *cobra.Command, args []string) {
+			inputDirectory, _ := cmd.Flags().GetString("input")
+			verbose, _ := cmd.Flags().GetBool("verbose")
+
+			log := logrus.New()
+			if verbose {
+				log.SetLevel(logrus.DebugLevel)
+			} else {
+				log.SetLevel(logrus.WarnLevel)
+			}
+
+			mpr.SetLogger(log)
+			modules, documents, err := mpr.ListModel(inputDirectory, mpr.ExportOptions{})
+			if err != nil {
+				log.Errorf("List failed: %s", err)
+				os.Exit(1)
+			}
+			for _, module := range modules {
+				fmt.Printf("module\t%s\t%s\n", module.Name, module.Version)
+			}
+			counts := make(map[string]int)
+			for _, document := range documents {
+				counts[document.Type]++
+			}
+			types := make([]string, 0, len(counts))
+			for documentType := range counts {
+				types = append(types, documentType)
+			}
+			sort.Strings(types)
+			for _, documentType := range types {
+				fmt.Printf("type\t%s\t%d\n", documentType, counts[documentType])
+			}
+		},
+	}
+
+	cmdList.Flags().StringP("input", "i", ".", "Path to the mpr file or a directory containing it")
+	cmdList.Flags().Bool("verbose", false, "Turn on for debug logs")
+	rootCmd.AddCommand(cmdList)
+
+	var cmdDiffModel = &cobra.Command{
+		Use:   "diff-model",
+		Short: "Write the documents that were added, removed or changed between two mpr files to diff.yaml",
+		Run: func(cmd *cobra.Command, args []string) {
+			oldInput, _ := cmd.Flags().GetString("old")
+			newInput, _ := cmd.Flags().GetString("new")
+			outputDirectory, _ := cmd.Flags().GetString("output")
+			mode, _ := cmd.Flags().GetString("mode")
+			verbose, _ := cmd.Flags().GetBool("verbose")
+
+			log := logrus.New()
+			if verbose {
+				log.SetLevel(logrus.DebugLevel)
+			} else {
+				log.SetLevel(logrus.InfoLevel)
+			}
+
+			mpr.SetLogger(log)
+			if err := mpr.ExportModelDiff(oldInput, newInput, outputDirectory, mpr.ExportOptions{Mode: mode}); err != nil {
+				log.Errorf("Diff failed: %s", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmdDiffModel.Flags().String("old", "", "Path to the previous mpr file, or a directory containing it")
+	cmdDiffModel.Flags().String("new", ".", "Path to the current mpr file, or a directory containing it")
+	cmdDiffModel.Flags().StringP("output", "o", ".", "Path to directory to write diff.yaml to")
+	cmdDiffModel.Flags().StringP("mode", "m", "basic", "Export mode the documents are compared in. Valid options: basic, advanced")
+	cmdDiffModel.Flags().Bool("verbose", false, "Turn on for debug logs")
+	rootCmd.AddCommand(cmdDiffModel)
+
 	var cmdLint = &cobra.Command{
 		Use:   "lint",
 		Short: "Evaluate Mendix model against rules. Requires the model to be exported first",
@@ -115,7 +469,9 @@ func main() {
 						log.Infof("Output directory: %s", outputDirectory)
 						log.Infof("Rules directory: %s", rulesDirectory)
 						log.Infof("Mode: %s", mode)
-						mpr.ExportModel(inputDirectory, outputDirectory, false, mode)
+						if err := mpr.ExportModel(inputDirectory, outputDirectory, mpr.ExportOptions{Raw: false, Mode: mode}); err != nil {
+							log.Warningf("Export failed: %s", err)
+						}
 						err := lint.EvalAll(rulesDirectory, outputDirectory, "", "")
 						if err != nil {
 							log.Warningf("Lint failed: %s", err)
@@ -157,3 +513,13 @@ func main() {
 	}
 
 }
+
+// logProgress returns a progress callback that logs every 10 percent of the written documents. It keeps no state,
+// so the interleaved calls for mpr files exported with --file-workers are logged correctly.
+func logProgress(log *logrus.Logger) func(done, total int) {
+	return func(done, total int) {
+		if done == total || done*10/total != (done-1)*10/total {
+			log.Infof("Written %d of %d documents (%d%%)", done, total, done*100/total)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package mpr
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// archiveSink writes every file as an entry of a tar stream. Directories are implied by the entry names.
+type archiveSink struct {
+	lock    sync.Mutex
+	writer  *tar.Writer
+	modTime time.Time
+}
+
+func (s *archiveSink) MkdirAll(path string) error {
+	return nil
+}
+
+func (s *archiveSink) WriteFile(path string, data []byte) error {
+	// documents are exported concurrently, entries are written one at a time
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	header := &tar.Header{
+		Name:    path,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: s.modTime,
+	}
+	if err := s.writer.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := s.writer.Write(data)
+	return err
+}
+
+// exportArchive streams the export into a tar.gz at archivePath without writing loose files. The archive holds
+// the same relative paths as the loose layout, so extracting it reproduces the tree.
+func exportArchive(ctx context.Context, inputDirectory string, archivePath string, options ExportOptions) error {
+	if dir := filepath.Dir(archivePath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating directory: %v", err)
+		}
+	}
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("error creating archive: %v", err)
+	}
+	if err := writeArchive(ctx, inputDirectory, file, options); err != nil {
+		file.Close()
+		os.Remove(archivePath)
+		return err
+	}
+	return file.Close()
+}
+
+// writeArchive exports the model into a gzip compressed tar stream written to file
+func writeArchive(ctx context.Context, inputDirectory string, file *os.File, options ExportOptions) error {
+	gzipWriter := gzip.NewWriter(file)
+	sink := &archiveSink{writer: tar.NewWriter(gzipWriter), modTime: time.Now()}
+	if err := exportToSink(ctx, inputDirectory, sink, options); err != nil {
+		return err
+	}
+	if err := sink.writer.Close(); err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+	return nil
+}
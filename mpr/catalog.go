@@ -0,0 +1,24 @@
+package mpr
+
+// exportCatalog writes one compact record per document instead of the full document contents, with the file the
+// document is written to in a full export
+func exportCatalog(documents []MxDocument, files map[string]string, output Sink, format string, log Logger) error {
+	records := make([]map[string]interface{}, 0, len(documents))
+	for _, document := range documents {
+		id, _ := idString(document.Attributes["$ID"])
+		name := document.Name
+		if module := getMxModuleName(document.Path); module != "" && name != "" {
+			name = qualifiedName(module, name)
+		}
+		documentation, _ := document.Attributes["Documentation"].(string)
+		records = append(records, map[string]interface{}{
+			"Name":          name,
+			"Type":          document.Type,
+			"Path":          files[id],
+			"Documentation": documentation,
+		})
+	}
+	log.Infof("Writing catalog of %d documents", len(records))
+
+	return writeFile(output, "Catalog."+fileExtension(format), map[string]interface{}{"Documents": records}, format, log)
+}
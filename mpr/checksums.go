@@ -0,0 +1,166 @@
+package mpr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ghodss/yaml"
+)
+
+// checksumSink records the sha256 of every file written to the next sink, as it is stored there
+type checksumSink struct {
+	next      Sink
+	lock      sync.Mutex
+	checksums map[string]string
+}
+
+func newChecksumSink(next Sink) *checksumSink {
+	return &checksumSink{next: next, checksums: make(map[string]string)}
+}
+
+func (s *checksumSink) MkdirAll(path string) error {
+	return s.next.MkdirAll(path)
+}
+
+func (s *checksumSink) WriteFile(path string, data []byte) error {
+	if err := s.next.WriteFile(path, data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.checksums[path] = hex.EncodeToString(sum[:])
+	return nil
+}
+
+func (s *checksumSink) ReadFile(path string) ([]byte, error) {
+	return readSinkFile(s.next, path)
+}
+
+// writeChecksums records the sha256 of every written file in checksums.<ext>. Incremental exports keep the
+// checksums of the previous run for the files that were not written again.
+func (s *checksumSink) writeChecksums(format string, incremental bool, log Logger) error {
+	format = stateFormat(format)
+	files := make(map[string]interface{}, len(s.checksums))
+	if incremental {
+		if previous, err := readChecksumsFrom(s.next); err == nil {
+			for name, checksum := range previous {
+				files[name] = checksum
+			}
+		}
+	}
+	for name, checksum := range s.checksums {
+		files[name] = checksum
+	}
+	log.Infof("Writing checksums of %d files", len(files))
+	return writeFile(s.next, "checksums."+fileExtension(format), map[string]interface{}{"Files": files}, format, log)
+}
+
+// VerifyExport recomputes the checksums of the files in an output directory exported with checksums
+// and reports files that were changed, removed or added since.
+func VerifyExport(outputDirectory string) error {
+	expected, err := readChecksums(outputDirectory)
+	if err != nil {
+		return err
+	}
+	actual, err := getChecksums(outputDirectory)
+	if err != nil {
+		return fmt.Errorf("error computing checksums: %v", err)
+	}
+
+	var verifyErrors []error
+	for _, name := range sortedStringKeys(expected) {
+		checksum, ok := actual[name]
+		if !ok {
+			verifyErrors = append(verifyErrors, fmt.Errorf("%s is missing", name))
+		} else if checksum != expected[name] {
+			verifyErrors = append(verifyErrors, fmt.Errorf("%s was modified", name))
+		}
+	}
+	for _, name := range sortedStringKeys(actual) {
+		if _, ok := expected[name]; !ok {
+			verifyErrors = append(verifyErrors, fmt.Errorf("%s is not in the checksums", name))
+		}
+	}
+	return errors.Join(verifyErrors...)
+}
+
+func readChecksums(outputDirectory string) (map[string]string, error) {
+	checksums, err := readChecksumsFrom(NewLocalSink(outputDirectory))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no checksums found in %s", outputDirectory)
+	}
+	return checksums, err
+}
+
+// readChecksumsFrom reads the checksums file of the sink in any of the formats it is written in
+func readChecksumsFrom(output Sink) (map[string]string, error) {
+	for _, format := range []string{"yaml", "json"} {
+		contents, err := readSinkFile(output, "checksums."+fileExtension(format))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading checksums: %v", err)
+		}
+		var checksums struct {
+			Files map[string]string `json:"Files"`
+		}
+		if err := yaml.Unmarshal(contents, &checksums); err != nil {
+			return nil, fmt.Errorf("error parsing checksums: %v", err)
+		}
+		return checksums.Files, nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+// getChecksums returns the sha256 of every file below the directory by slash separated relative path,
+// except the checksum files themselves
+func getChecksums(directory string) (map[string]string, error) {
+	checksums := make(map[string]string)
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if name == "checksums.yaml" || name == "checksums.json" {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return err
+		}
+		checksums[name] = hex.EncodeToString(hash.Sum(nil))
+		return nil
+	})
+	return checksums, err
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
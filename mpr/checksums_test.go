@@ -0,0 +1,34 @@
+package mpr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyExport(t *testing.T) {
+	outputDirectory := "./../tmp/checksums"
+	os.RemoveAll(outputDirectory)
+	if err := ExportModel("./../resources/app", outputDirectory, ExportOptions{Mode: "basic", Checksums: true}); err != nil {
+		t.Fatalf("Failed to export model: %v", err)
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		if err := VerifyExport(outputDirectory); err != nil {
+			t.Errorf("Expected export to verify: %v", err)
+		}
+	})
+	t.Run("modified", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(outputDirectory, "Metadata.yaml"), []byte("tampered"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := VerifyExport(outputDirectory); err == nil {
+			t.Errorf("Expected modified file to be reported")
+		}
+	})
+	t.Run("no checksums", func(t *testing.T) {
+		if err := VerifyExport("./../resources/app"); err == nil {
+			t.Errorf("Expected error without checksums")
+		}
+	})
+}
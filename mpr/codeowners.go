@@ -0,0 +1,72 @@
+package mpr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// readOwnersMapping reads a yaml file mapping module names to one or more owning teams
+func readOwnersMapping(ownersFile string) (map[string][]string, error) {
+	contents, err := os.ReadFile(ownersFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading owners file: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(contents, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing owners file: %v", err)
+	}
+
+	owners := make(map[string][]string)
+	for module, value := range raw {
+		switch v := value.(type) {
+		case string:
+			owners[module] = []string{v}
+		case []interface{}:
+			for _, team := range v {
+				if teamString, ok := team.(string); ok {
+					owners[module] = append(owners[module], teamString)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("invalid owners for module %s: %v", module, value)
+		}
+	}
+	return owners, nil
+}
+
+// exportCodeOwners writes a CODEOWNERS file assigning the file of every exported document to the teams owning its
+// module
+func exportCodeOwners(documents []MxDocument, files map[string]string, ownersFile string, output Sink) error {
+	owners, err := readOwnersMapping(ownersFile)
+	if err != nil {
+		return err
+	}
+
+	var builder strings.Builder
+	builder.WriteString("# Generated by mxlint from " + filepath.Base(ownersFile) + "\n")
+	written := make(map[string]bool)
+	for _, document := range documents {
+		module := getMxModuleName(document.Path)
+		teams, ok := owners[module]
+		if !ok || len(teams) == 0 {
+			continue
+		}
+		id, _ := idString(document.Attributes["$ID"])
+		// documents of the flat-module layout share the file of their module
+		if written[files[id]] {
+			continue
+		}
+		written[files[id]] = true
+		documentPath := strings.ReplaceAll("/"+files[id], " ", "\\ ")
+		builder.WriteString(documentPath + " " + strings.Join(teams, " ") + "\n")
+	}
+
+	if err := writeSinkFile(output, "CODEOWNERS", []byte(builder.String())); err != nil {
+		return fmt.Errorf("error writing codeowners file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package mpr
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMPRCodeOwners(t *testing.T) {
+	t.Run("module-teams", func(t *testing.T) {
+		if err := os.MkdirAll("./../tmp", 0755); err != nil {
+			t.Fatalf("Failed to create tmp directory: %v", err)
+		}
+		ownersFile := "./../tmp/owners.yaml"
+		if err := os.WriteFile(ownersFile, []byte("MyFirstModule: '@org/bikes'\nAdministration:\n  - '@org/admins'\n  - '@org/security'\n"), 0644); err != nil {
+			t.Fatalf("Failed to write owners file: %v", err)
+		}
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Mode: "basic", CodeOwners: ownersFile}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+
+		codeOwners, err := os.ReadFile("./../tmp/CODEOWNERS")
+		if err != nil {
+			t.Fatalf("Failed to read CODEOWNERS file: %v", err)
+		}
+		if !strings.Contains(string(codeOwners), "/MyFirstModule/DomainModels$DomainModel.yaml @org/bikes\n") {
+			t.Errorf("Expected MyFirstModule domain model to be owned by @org/bikes")
+		}
+		if !strings.Contains(string(codeOwners), " @org/admins @org/security\n") {
+			t.Errorf("Expected Administration documents to be owned by both teams")
+		}
+		if strings.Contains(string(codeOwners), "/CommunityCommons/") {
+			t.Errorf("Unmapped modules should not be listed")
+		}
+	})
+	t.Run("by-type-layout", func(t *testing.T) {
+		ownersFile := "./../tmp/owners.yaml"
+		if err := os.WriteFile(ownersFile, []byte("MyFirstModule: '@org/bikes'\n"), 0644); err != nil {
+			t.Fatalf("Failed to write owners file: %v", err)
+		}
+		outputDirectory := "./../tmp/codeowners-by-type"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", CodeOwners: ownersFile, Layout: "by-type"}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		codeOwners, err := os.ReadFile(outputDirectory + "/CODEOWNERS")
+		if err != nil {
+			t.Fatalf("Failed to read CODEOWNERS file: %v", err)
+		}
+		file := "/DomainModels$DomainModel/MyFirstModule/DomainModels$DomainModel.yaml"
+		if !strings.Contains(string(codeOwners), file+" @org/bikes\n") {
+			t.Errorf("Expected the file of the by-type layout. Got: %s", codeOwners)
+		}
+		if _, err := os.Stat(outputDirectory + file); err != nil {
+			t.Errorf("Expected the owned file to exist: %v", err)
+		}
+	})
+}
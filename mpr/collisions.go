@@ -0,0 +1,151 @@
+package mpr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// resolveFileNames returns the file name of every document keyed by its $ID.
+// Unnamed documents that share a type and folder are written as <Type>.<hash>.yaml with a short hash of their unit ID.
+// Other documents that resolve to the same file are handled according to onCollision: error (default), suffix,
+// which adds the hash to the names of all of them, or overwrite.
+// Named documents get their file name from fileNameTemplate when it is set.
+func resolveFileNames(documents []MxDocument, format string, onCollision string, fileNameTemplate *template.Template, log Logger) (map[string]string, error) {
+	if onCollision == "" {
+		onCollision = "error"
+	}
+	if onCollision != "error" && onCollision != "suffix" && onCollision != "overwrite" {
+		return nil, fmt.Errorf("invalid collision handling %s", onCollision)
+	}
+
+	unnamed := make(map[string]int)
+	for _, document := range documents {
+		if document.Name == "" {
+			unnamed[filepath.Join(document.Path, getMxDocumentFileName(document, format))]++
+		}
+	}
+
+	fileNames := make([]string, len(documents))
+	paths := make(map[string]int, len(documents))
+	for i, document := range documents {
+		fname := getMxDocumentFileName(document, format)
+		if unnamed[filepath.Join(document.Path, fname)] > 1 {
+			fname = fmt.Sprintf("%s.%s.%s", document.Type, documentHash(document), fileExtension(format))
+		} else if fileNameTemplate != nil && document.Name != "" {
+			var err error
+			if fname, err = executeFileNameTemplate(fileNameTemplate, document, format); err != nil {
+				return nil, fmt.Errorf("error resolving file name of %s: %v", document.Name, err)
+			}
+		}
+		fileNames[i] = fname
+		paths[filepath.Join(document.Path, fname)]++
+	}
+
+	names := make(map[string]string, len(documents))
+	written := make(map[string]bool, len(documents))
+	for i, document := range documents {
+		id, _ := idString(document.Attributes["$ID"])
+		fname := fileNames[i]
+		path := filepath.Join(document.Path, fname)
+		if paths[path] > 1 {
+			switch onCollision {
+			case "error":
+				return nil, fmt.Errorf("file name collision: %s is produced by more than one document", path)
+			case "suffix":
+				extension := "." + fileExtension(format)
+				fname = strings.TrimSuffix(fname, extension) + "." + documentHash(document) + extension
+				log.Warnf("File name collision: writing unit %s to %s", id, filepath.Join(document.Path, fname))
+			case "overwrite":
+				if written[path] {
+					log.Warnf("File name collision: %s is overwritten by unit %s", path, id)
+				}
+			}
+		}
+		written[path] = true
+		names[id] = fname
+	}
+	return names, nil
+}
+
+// documentHash returns a short hash of the unit ID of a document as stored in the mpr file, which is stable across
+// runs and does not depend on the ID encoding. Documents that were not read from an mpr file hash their $ID.
+func documentHash(document MxDocument) string {
+	if len(document.unitID) > 0 {
+		return shortHash(document.unitID)
+	}
+	id, _ := idString(document.Attributes["$ID"])
+	return shortHash([]byte(id))
+}
+
+// shortHash returns the first characters of the sha256 hash of an ID, which are stable across runs
+func shortHash(id []byte) string {
+	sum := sha256.Sum256(id)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// idSuffix returns the first alphanumeric characters of an ID for use in file names
+func idSuffix(id string) string {
+	suffix := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, id)
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	return suffix
+}
+
+// resolveDuplicateModules reports modules that share a name, which would be exported into the same directory.
+// onDuplicate decides what happens: warn (default), error or suffix, which appends part of the unit ID to the
+// names of all conflicting modules.
+func resolveDuplicateModules(units []MxUnit, version MxVersion, onDuplicate string, log Logger) error {
+	if onDuplicate == "" {
+		onDuplicate = "warn"
+	}
+	if onDuplicate != "warn" && onDuplicate != "error" && onDuplicate != "suffix" {
+		return fmt.Errorf("invalid duplicate module handling %s", onDuplicate)
+	}
+
+	modules := make(map[string][]int)
+	for i, unit := range units {
+		if unit.ContainmentName != containmentName("Modules", version) {
+			continue
+		}
+		if name, ok := unit.Contents["Name"].(string); ok {
+			modules[name] = append(modules[name], i)
+		}
+	}
+	names := make([]string, 0, len(modules))
+	for name, indexes := range modules {
+		if len(indexes) > 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ids := make([]string, 0, len(modules[name]))
+		for _, i := range modules[name] {
+			ids = append(ids, units[i].UnitID)
+		}
+		switch onDuplicate {
+		case "error":
+			return fmt.Errorf("duplicate module name %s used by units %s", name, strings.Join(ids, ", "))
+		case "suffix":
+			for _, i := range modules[name] {
+				units[i].Contents["Name"] = name + "." + idSuffix(units[i].UnitID)
+			}
+			log.Warnf("Duplicate module name %s used by units %s, exporting them with their ID as suffix", name, strings.Join(ids, ", "))
+		default:
+			log.Warnf("Duplicate module name %s used by units %s, their documents are exported into the same directory", name, strings.Join(ids, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package mpr
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResolveFileNames(t *testing.T) {
+	documents := []MxDocument{
+		{Name: "ACT_Save", Type: "Microflows$Microflow", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "11111111-aaaa"}},
+		{Name: "ACT_Save", Type: "Microflows$Microflow", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "22222222-bbbb"}},
+	}
+
+	t.Run("error", func(t *testing.T) {
+		if _, err := resolveFileNames(documents, "yaml", "", nil, log); err == nil {
+			t.Errorf("Expected collision error")
+		}
+	})
+	t.Run("suffix", func(t *testing.T) {
+		names, err := resolveFileNames(documents, "yaml", "suffix", nil, log)
+		if err != nil {
+			t.Fatalf("Failed to resolve file names: %v", err)
+		}
+		if names["11111111-aaaa"] != "ACT_Save.Microflows$Microflow."+shortHash([]byte("11111111-aaaa"))+".yaml" {
+			t.Errorf("Unexpected file name %s", names["11111111-aaaa"])
+		}
+		if names["22222222-bbbb"] != "ACT_Save.Microflows$Microflow."+shortHash([]byte("22222222-bbbb"))+".yaml" {
+			t.Errorf("Unexpected file name %s", names["22222222-bbbb"])
+		}
+	})
+	t.Run("unit-id", func(t *testing.T) {
+		unitID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+		for _, id := range []interface{}{primitive.Binary{Data: unitID}, formatUUID(unitID)} {
+			unnamed := []MxDocument{
+				{Type: "Security$ModuleSecurity", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": id}, unitID: unitID},
+				{Type: "Security$ModuleSecurity", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "other"}, unitID: []byte{1}},
+			}
+			names, err := resolveFileNames(unnamed, "yaml", "", nil, log)
+			if err != nil {
+				t.Fatalf("Failed to resolve file names: %v", err)
+			}
+			key, _ := idString(id)
+			if names[key] != "Security$ModuleSecurity."+shortHash(unitID)+".yaml" {
+				t.Errorf("Expected the hash of the unit ID regardless of the $ID encoding, got %s", names[key])
+			}
+		}
+	})
+	t.Run("unnamed", func(t *testing.T) {
+		unnamed := []MxDocument{
+			{Name: "", Type: "Security$ModuleSecurity", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "11111111-aaaa"}},
+			{Name: "", Type: "Security$ModuleSecurity", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "22222222-bbbb"}},
+			{Name: "", Type: "DomainModels$DomainModel", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "33333333-cccc"}},
+		}
+		names, err := resolveFileNames(unnamed, "yaml", "", nil, log)
+		if err != nil {
+			t.Fatalf("Failed to resolve file names: %v", err)
+		}
+		if names["11111111-aaaa"] != "Security$ModuleSecurity."+shortHash([]byte("11111111-aaaa"))+".yaml" {
+			t.Errorf("Unexpected file name %s", names["11111111-aaaa"])
+		}
+		if names["11111111-aaaa"] == names["22222222-bbbb"] {
+			t.Errorf("Expected distinct file names, got %s", names["22222222-bbbb"])
+		}
+		if names["33333333-cccc"] != "DomainModels$DomainModel.yaml" {
+			t.Errorf("Expected unnamed document without conflict to keep its name, got %s", names["33333333-cccc"])
+		}
+	})
+	t.Run("overwrite", func(t *testing.T) {
+		names, err := resolveFileNames(documents, "yaml", "overwrite", nil, log)
+		if err != nil {
+			t.Fatalf("Failed to resolve file names: %v", err)
+		}
+		if names["22222222-bbbb"] != "ACT_Save.Microflows$Microflow.yaml" {
+			t.Errorf("Unexpected file name %s", names["22222222-bbbb"])
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := resolveFileNames(documents, "yaml", "rename", nil, log); err == nil {
+			t.Errorf("Expected error for invalid collision handling")
+		}
+	})
+}
+
+func TestResolveDuplicateModules(t *testing.T) {
+	newUnits := func() []MxUnit {
+		return []MxUnit{
+			{UnitID: "11111111-aaaa", ContainmentName: "Modules", Contents: map[string]interface{}{"Name": "Administration"}},
+			{UnitID: "22222222-bbbb", ContainmentName: "Modules", Contents: map[string]interface{}{"Name": "Administration"}},
+			{UnitID: "33333333-cccc", ContainmentName: "Modules", Contents: map[string]interface{}{"Name": "MyFirstModule"}},
+		}
+	}
+
+	t.Run("warn", func(t *testing.T) {
+		units := newUnits()
+		if err := resolveDuplicateModules(units, MxVersion{}, "", log); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if units[1].Contents["Name"] != "Administration" {
+			t.Errorf("Expected names to be kept, got %v", units[1].Contents["Name"])
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		err := resolveDuplicateModules(newUnits(), MxVersion{}, "error", log)
+		if err == nil || !strings.Contains(err.Error(), "22222222-bbbb") {
+			t.Errorf("Expected error listing the unit IDs, got %v", err)
+		}
+	})
+	t.Run("suffix", func(t *testing.T) {
+		units := newUnits()
+		if err := resolveDuplicateModules(units, MxVersion{}, "suffix", log); err != nil {
+			t.Fatalf("Failed to resolve duplicate modules: %v", err)
+		}
+		folders, err := getMxFolders(units, MxVersion{}, ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get folders: %v", err)
+		}
+		names := make(map[string]bool)
+		for _, folder := range folders {
+			names[folder.Name] = true
+		}
+		for _, name := range []string{"Administration.11111111", "Administration.22222222", "MyFirstModule"} {
+			if !names[name] {
+				t.Errorf("Expected module directory %s, got %v", name, names)
+			}
+		}
+	})
+}
+
+func TestFileNameTemplate(t *testing.T) {
+	documents := []MxDocument{
+		{Name: "ACT_Save", Type: "Microflows$Microflow", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "11111111-aaaa"}},
+		{Name: "", Type: "DomainModels$DomainModel", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "22222222-bbbb"}},
+	}
+
+	t.Run("sub-path", func(t *testing.T) {
+		tmpl, err := parseFileNameTemplate("{{.Type}}/{{.Name}}")
+		if err != nil {
+			t.Fatalf("Failed to parse template: %v", err)
+		}
+		names, err := resolveFileNames(documents, "yaml", "", tmpl, log)
+		if err != nil {
+			t.Fatalf("Failed to resolve file names: %v", err)
+		}
+		if names["11111111-aaaa"] != "Microflows$Microflow/ACT_Save.yaml" {
+			t.Errorf("Unexpected file name %s", names["11111111-aaaa"])
+		}
+		if names["22222222-bbbb"] != "DomainModels$DomainModel.yaml" {
+			t.Errorf("Expected default file name for unnamed documents, got %s", names["22222222-bbbb"])
+		}
+	})
+	t.Run("collision", func(t *testing.T) {
+		tmpl, err := parseFileNameTemplate("{{.Type}}")
+		if err != nil {
+			t.Fatalf("Failed to parse template: %v", err)
+		}
+		colliding := append(documents, MxDocument{Name: "ACT_Delete", Type: "Microflows$Microflow", Path: "MyFirstModule", Attributes: map[string]interface{}{"$ID": "33333333-cccc"}})
+		if _, err := resolveFileNames(colliding, "yaml", "", tmpl, log); err == nil {
+			t.Errorf("Expected collision error")
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, text := range []string{"{{.Name", "{{.Module}}", "../{{.Name}}", "{{.Name}}/"} {
+			if _, err := parseFileNameTemplate(text); err == nil {
+				t.Errorf("Expected error for template %s", text)
+			}
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package mpr
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// typeDefaults maps a $Type to the inferred default value of each of its scalar attributes
+type typeDefaults map[string]map[string]interface{}
+
+// typeValueCount counts the objects of a type that share a scalar attribute value
+type typeValueCount struct {
+	value interface{}
+	count int
+}
+
+// inferTypeDefaults derives defaults from the model itself: a scalar attribute value is considered
+// the default of its $Type when more than half of the objects of that type share it. Only attributes that every
+// object of the type has get a default, so restoring the defaults gives back the exact objects.
+func inferTypeDefaults(documents []MxDocument, raw bool, stripKeys []string) typeDefaults {
+	typeCounts := make(map[string]int)
+	valueCounts := make(map[string]map[string]map[string]*typeValueCount)
+	for _, document := range documents {
+		countTypeValues(cleanData(document.Attributes, raw, stripKeys), typeCounts, valueCounts)
+	}
+
+	defaults := make(typeDefaults)
+	for objType, attributes := range valueCounts {
+		if typeCounts[objType] < 2 {
+			continue
+		}
+		for key, values := range attributes {
+			if key == "$Type" || key == "Name" {
+				// kept by removeTypeDefaults
+				continue
+			}
+			total := 0
+			for _, value := range values {
+				total += value.count
+			}
+			if total != typeCounts[objType] {
+				continue
+			}
+			for _, value := range values {
+				if value.count*2 > typeCounts[objType] {
+					if defaults[objType] == nil {
+						defaults[objType] = make(map[string]interface{})
+					}
+					defaults[objType][key] = value.value
+				}
+			}
+		}
+	}
+	return defaults
+}
+
+func countTypeValues(value interface{}, typeCounts map[string]int, valueCounts map[string]map[string]map[string]*typeValueCount) {
+	switch v := value.(type) {
+	case bson.M:
+		countTypeValues(map[string]interface{}(v), typeCounts, valueCounts)
+	case map[string]interface{}:
+		objType, _ := v["$Type"].(string)
+		if objType != "" {
+			typeCounts[objType]++
+			if valueCounts[objType] == nil {
+				valueCounts[objType] = make(map[string]map[string]*typeValueCount)
+			}
+		}
+		for key, item := range v {
+			if scalar, ok := scalarKey(item); ok && objType != "" {
+				if valueCounts[objType][key] == nil {
+					valueCounts[objType][key] = make(map[string]*typeValueCount)
+				}
+				if valueCounts[objType][key][scalar] == nil {
+					valueCounts[objType][key][scalar] = &typeValueCount{value: item}
+				}
+				valueCounts[objType][key][scalar].count++
+				continue
+			}
+			countTypeValues(item, typeCounts, valueCounts)
+		}
+	case primitive.A:
+		countTypeValues([]interface{}(v), typeCounts, valueCounts)
+	case []interface{}:
+		for _, item := range v {
+			countTypeValues(item, typeCounts, valueCounts)
+		}
+	case []map[string]interface{}:
+		// the MainFunction of microflows in advanced mode
+		for _, item := range v {
+			countTypeValues(item, typeCounts, valueCounts)
+		}
+	}
+}
+
+// removeTypeDefaults returns a copy of the data without the attributes that equal their type default.
+// $Type and Name are always kept so the output stays identifiable.
+func removeTypeDefaults(value interface{}, defaults typeDefaults) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		return bson.M(removeTypeDefaults(map[string]interface{}(v), defaults).(map[string]interface{}))
+	case map[string]interface{}:
+		objType, _ := v["$Type"].(string)
+		result := make(map[string]interface{})
+		for key, item := range v {
+			if key != "$Type" && key != "Name" {
+				if scalar, ok := scalarKey(item); ok {
+					if def, ok := defaults[objType][key]; ok {
+						if defScalar, _ := scalarKey(def); defScalar == scalar {
+							continue
+						}
+					}
+				}
+			}
+			result[key] = removeTypeDefaults(item, defaults)
+		}
+		return result
+	case primitive.A:
+		return removeTypeDefaults([]interface{}(v), defaults)
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, removeTypeDefaults(item, defaults))
+		}
+		return result
+	case []map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		result := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, removeTypeDefaults(item, defaults).(map[string]interface{}))
+		}
+		return result
+	}
+	return value
+}
+
+// restoreTypeDefaults adds the attributes that were left out because they equal their type default back to the
+// objects of an exported document, which reverses removeTypeDefaults
+func restoreTypeDefaults(value interface{}, defaults map[string]map[string]interface{}) {
+	switch v := value.(type) {
+	case bson.M:
+		restoreTypeDefaults(map[string]interface{}(v), defaults)
+	case map[string]interface{}:
+		objType, _ := v["$Type"].(string)
+		for key, def := range defaults[objType] {
+			if _, ok := v[key]; !ok {
+				v[key] = def
+			}
+		}
+		for _, item := range v {
+			restoreTypeDefaults(item, defaults)
+		}
+	case primitive.A:
+		restoreTypeDefaults([]interface{}(v), defaults)
+	case []interface{}:
+		for _, item := range v {
+			restoreTypeDefaults(item, defaults)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			restoreTypeDefaults(item, defaults)
+		}
+	}
+}
+
+func scalarKey(value interface{}) (string, bool) {
+	switch value.(type) {
+	case nil, string, bool, int, int32, int64, float64:
+		return fmt.Sprintf("%T:%v", value, value), true
+	}
+	return "", false
+}
@@ -0,0 +1,126 @@
+package mpr
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// MxDocumentChange lists the top level attributes of a document that differ between two models
+type MxDocumentChange struct {
+	Document string   `yaml:"Document"`
+	Keys     []string `yaml:"Keys"`
+}
+
+// MxModelDiff holds the documents that were added, removed or changed between two models. Documents are
+// identified by their path relative to the output directory.
+type MxModelDiff struct {
+	Added   []string           `yaml:"Added"`
+	Removed []string           `yaml:"Removed"`
+	Changed []MxDocumentChange `yaml:"Changed"`
+}
+
+// ExportModelDiff exports the old and the new mpr file to memory and writes diff.yaml to the output directory
+// with the documents that were added, removed or changed in the new one.
+func ExportModelDiff(oldInput string, newInput string, outputDirectory string, options ExportOptions) error {
+	diff, err := getMxModelDiff(oldInput, newInput, options)
+	if err != nil {
+		return err
+	}
+	return writeFile(NewLocalSink(outputDirectory), "diff."+fileExtension(options.Format), map[string]interface{}{
+		"Added":   diff.Added,
+		"Removed": diff.Removed,
+		"Changed": diff.Changed,
+	}, options.Format, options.logger())
+}
+
+func getMxModelDiff(oldInput string, newInput string, options ExportOptions) (MxModelDiff, error) {
+	oldDocuments, _, err := ExportModelToMemory(oldInput, options)
+	if err != nil {
+		return MxModelDiff{}, fmt.Errorf("error exporting %s: %v", oldInput, err)
+	}
+	newDocuments, _, err := ExportModelToMemory(newInput, options)
+	if err != nil {
+		return MxModelDiff{}, fmt.Errorf("error exporting %s: %v", newInput, err)
+	}
+	oldIndex := getMxDocumentIndex(oldDocuments, options.Format)
+	newIndex := getMxDocumentIndex(newDocuments, options.Format)
+
+	diff := MxModelDiff{
+		Added:   make([]string, 0),
+		Removed: make([]string, 0),
+		Changed: make([]MxDocumentChange, 0),
+	}
+	for _, path := range sortedDocumentPaths(newIndex) {
+		oldDocument, ok := oldIndex[path]
+		if !ok {
+			diff.Added = append(diff.Added, path)
+			continue
+		}
+		keys, err := getChangedKeys(oldDocument.Attributes, newIndex[path].Attributes, options.Format)
+		if err != nil {
+			return MxModelDiff{}, fmt.Errorf("error comparing %s: %v", path, err)
+		}
+		if len(keys) > 0 {
+			diff.Changed = append(diff.Changed, MxDocumentChange{Document: path, Keys: keys})
+		}
+	}
+	for _, path := range sortedDocumentPaths(oldIndex) {
+		if _, ok := newIndex[path]; !ok {
+			diff.Removed = append(diff.Removed, path)
+		}
+	}
+	return diff, nil
+}
+
+// getMxDocumentIndex maps the documents by the path they would be exported to
+func getMxDocumentIndex(documents []MxDocument, format string) map[string]MxDocument {
+	index := make(map[string]MxDocument, len(documents))
+	for _, document := range documents {
+		index[filepath.ToSlash(filepath.Join(document.Path, getMxDocumentFileName(document, format)))] = document
+	}
+	return index
+}
+
+func sortedDocumentPaths(index map[string]MxDocument) []string {
+	paths := make([]string, 0, len(index))
+	for path := range index {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// getChangedKeys returns the top level keys whose serialized values differ between the two attribute maps
+func getChangedKeys(oldAttributes map[string]interface{}, newAttributes map[string]interface{}, format string) ([]string, error) {
+	keys := make(map[string]bool)
+	for key := range oldAttributes {
+		keys[key] = true
+	}
+	for key := range newAttributes {
+		keys[key] = true
+	}
+	changed := make([]string, 0)
+	for key := range keys {
+		_, inOld := oldAttributes[key]
+		_, inNew := newAttributes[key]
+		if inOld != inNew {
+			changed = append(changed, key)
+			continue
+		}
+		oldValue, err := marshal(map[string]interface{}{key: oldAttributes[key]}, format)
+		if err != nil {
+			return nil, err
+		}
+		newValue, err := marshal(map[string]interface{}{key: newAttributes[key]}, format)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(oldValue, newValue) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
@@ -0,0 +1,56 @@
+package mpr
+
+import (
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestExportModelDiff(t *testing.T) {
+	t.Run("same-model", func(t *testing.T) {
+		diff, err := getMxModelDiff("./../resources/app/App.mpr", "./../resources/app/App.mpr", ExportOptions{Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to diff models: %v", err)
+		}
+		if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+			t.Errorf("Expected no differences. Got: %v", diff)
+		}
+	})
+	t.Run("two-versions", func(t *testing.T) {
+		outputDirectory := "./../tmp/diff"
+		os.RemoveAll(outputDirectory)
+		if err := ExportModelDiff("./../resources/full-app-v1.mpr", "./../resources/full-app-v2.mpr", outputDirectory, ExportOptions{Mode: "basic"}); err != nil {
+			t.Fatalf("Failed to diff models: %v", err)
+		}
+		contents, err := os.ReadFile(outputDirectory + "/diff.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read diff: %v", err)
+		}
+		var diff MxModelDiff
+		if err := yaml.Unmarshal(contents, &diff); err != nil {
+			t.Fatalf("Failed to unmarshal diff: %v", err)
+		}
+		if len(diff.Added)+len(diff.Removed)+len(diff.Changed) == 0 {
+			t.Errorf("Expected differences between the versions")
+		}
+		for _, change := range diff.Changed {
+			if len(change.Keys) == 0 {
+				t.Errorf("Expected changed keys for %s", change.Document)
+			}
+		}
+	})
+	t.Run("changed-keys", func(t *testing.T) {
+		keys, err := getChangedKeys(
+			map[string]interface{}{"Name": "A", "Documentation": "", "Removed": nil},
+			map[string]interface{}{"Name": "B", "Documentation": "", "Added": 1},
+			"yaml",
+		)
+		if err != nil {
+			t.Fatalf("Failed to compare attributes: %v", err)
+		}
+		if len(keys) != 3 || keys[0] != "Added" || keys[1] != "Name" || keys[2] != "Removed" {
+			t.Errorf("Unexpected changed keys. Got: %v", keys)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package mpr
+
+import "strings"
+
+func transformDomainModel(dm MxDocument, log Logger) MxDocument {
+	log.Infof("Transforming domain model %s", dm.Path)
+
+	module := getMxModuleName(dm.Path)
+	eventHandlers := make([]map[string]interface{}, 0)
+	for _, entity := range getObjectList(dm.Attributes["Entities"]) {
+		entityName := qualifiedName(module, entity["Name"])
+		entity["Keys"] = getMxEntityKeys(entity)
+		for _, handler := range getObjectList(entity["Events"]) {
+			eventHandlers = append(eventHandlers, map[string]interface{}{
+				"Entity":            entityName,
+				"Moment":            handler["Moment"],
+				"Event":             handler["Event"],
+				"Microflow":         handler["Microflow"],
+				"RaiseErrorOnFalse": handler["RaiseErrorOnFalse"],
+				"PassEventObject":   handler["PassEventObject"],
+			})
+		}
+	}
+	dm.Attributes["EventHandlers"] = eventHandlers
+	dm.Attributes["Relationships"] = getMxRelationships(dm.Attributes, module)
+	return dm
+}
+
+// getMxRelationships lists the associations and cross-module associations of a domain model
+// as source entity, target entity and multiplicity
+func getMxRelationships(attributes map[string]interface{}, module string) []map[string]interface{} {
+	entityNames := make(map[string]string)
+	for _, entity := range getObjectList(attributes["Entities"]) {
+		if id, ok := idString(entity["$ID"]); ok {
+			entityNames[id] = qualifiedName(module, entity["Name"])
+		}
+	}
+
+	relationships := make([]map[string]interface{}, 0)
+	associations := append(getObjectList(attributes["Associations"]), getObjectList(attributes["CrossAssociations"])...)
+	for _, association := range associations {
+		parentID, _ := idString(association["ParentPointer"])
+		// cross-module associations refer to their child by qualified name
+		child, ok := association["Child"].(string)
+		if !ok {
+			childID, _ := idString(association["ChildPointer"])
+			child = entityNames[childID]
+		}
+		associationType, _ := association["Type"].(string)
+		owner, _ := association["Owner"].(string)
+		relationships = append(relationships, map[string]interface{}{
+			"Name":         qualifiedName(module, association["Name"]),
+			"Parent":       entityNames[parentID],
+			"Child":        child,
+			"Multiplicity": getMxMultiplicity(associationType, owner),
+			"Owner":        owner,
+		})
+	}
+	return relationships
+}
+
+// getMxMultiplicity translates the association type and owner to the multiplicity from parent to child
+func getMxMultiplicity(associationType string, owner string) string {
+	if associationType == "ReferenceSet" {
+		return "many-to-many"
+	}
+	if owner == "Both" {
+		return "one-to-one"
+	}
+	return "many-to-one"
+}
+
+func qualifiedName(module string, name interface{}) string {
+	nameString, _ := name.(string)
+	if module == "" {
+		return nameString
+	}
+	return module + "." + nameString
+}
+
+// getMxEntityKeys lists the indexes and unique constraints of an entity with the names of their member attributes
+func getMxEntityKeys(entity map[string]interface{}) []map[string]interface{} {
+	entityName, _ := entity["Name"].(string)
+	attributeNames := make(map[string]string)
+	for _, attribute := range getObjectList(entity["Attributes"]) {
+		if id, ok := idString(attribute["$ID"]); ok {
+			attributeNames[id], _ = attribute["Name"].(string)
+		}
+	}
+
+	keys := make([]map[string]interface{}, 0)
+	for _, index := range getObjectList(entity["Indexes"]) {
+		members := make([]string, 0)
+		for _, indexed := range getObjectList(index["Attributes"]) {
+			if id, ok := idString(indexed["AttributePointer"]); ok {
+				members = append(members, attributeNames[id])
+			}
+		}
+		keys = append(keys, map[string]interface{}{
+			"Name":       strings.Join(append([]string{entityName}, members...), "_"),
+			"Attributes": members,
+			"Unique":     false,
+		})
+	}
+	for _, rule := range getObjectList(entity["ValidationRules"]) {
+		ruleInfo, _ := getObject(rule["RuleInfo"])
+		if ruleInfo["$Type"] != "DomainModels$UniqueRuleInfo" {
+			continue
+		}
+		// unique rules refer to their attribute by qualified name
+		attribute, _ := rule["Attribute"].(string)
+		member := attribute[strings.LastIndex(attribute, ".")+1:]
+		keys = append(keys, map[string]interface{}{
+			"Name":       entityName + "_" + member,
+			"Attributes": []string{member},
+			"Unique":     true,
+		})
+	}
+	return keys
+}
@@ -0,0 +1,145 @@
+package mpr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMPRDomainModelEventHandlers(t *testing.T) {
+	t.Run("before-commit", func(t *testing.T) {
+		dm := MxDocument{
+			Path: "MyFirstModule",
+			Attributes: bson.M{
+				"$Type": "DomainModels$DomainModel",
+				"Entities": bson.A{
+					int32(3),
+					bson.M{
+						"$Type": "DomainModels$EntityImpl",
+						"Name":  "Bike",
+						"Events": bson.A{
+							int32(3),
+							bson.M{
+								"$Type":             "DomainModels$EventHandler",
+								"Moment":            "Before",
+								"Event":             "Commit",
+								"Microflow":         "MyFirstModule.BCo_Bike",
+								"RaiseErrorOnFalse": true,
+								"PassEventObject":   true,
+							},
+						},
+					},
+				},
+			},
+		}
+		result := transformDomainModel(dm, log)
+		handlers, ok := result.Attributes["EventHandlers"].([]map[string]interface{})
+		if !ok || len(handlers) != 1 {
+			t.Fatalf("Expected 1 event handler. Got: %v", result.Attributes["EventHandlers"])
+		}
+		if handlers[0]["Entity"] != "MyFirstModule.Bike" || handlers[0]["Microflow"] != "MyFirstModule.BCo_Bike" {
+			t.Errorf("Unexpected event handler: %v", handlers[0])
+		}
+	})
+}
+
+func TestMPRDomainModelKeys(t *testing.T) {
+	t.Run("index-and-unique", func(t *testing.T) {
+		nameID := primitive.Binary{Subtype: 0, Data: []byte("0123456789abcdef")}
+		colorID := primitive.Binary{Subtype: 0, Data: []byte("fedcba9876543210")}
+		entity := bson.M{
+			"$Type": "DomainModels$EntityImpl",
+			"Name":  "Bike",
+			"Attributes": bson.A{
+				int32(3),
+				bson.M{"$ID": nameID, "Name": "Name"},
+				bson.M{"$ID": colorID, "Name": "Color"},
+			},
+			"Indexes": bson.A{
+				int32(3),
+				bson.M{
+					"$Type": "DomainModels$EntityIndex",
+					"Attributes": bson.A{
+						int32(3),
+						bson.M{"$Type": "DomainModels$IndexedAttribute", "AttributePointer": nameID, "Ascending": true},
+						bson.M{"$Type": "DomainModels$IndexedAttribute", "AttributePointer": colorID, "Ascending": true},
+					},
+				},
+			},
+			"ValidationRules": bson.A{
+				int32(3),
+				bson.M{
+					"$Type":     "DomainModels$ValidationRule",
+					"Attribute": "MyFirstModule.Bike.Name",
+					"RuleInfo":  bson.M{"$Type": "DomainModels$UniqueRuleInfo"},
+				},
+			},
+		}
+
+		keys := getMxEntityKeys(entity)
+		if len(keys) != 2 {
+			t.Fatalf("Expected 2 keys. Got: %v", keys)
+		}
+		if keys[0]["Name"] != "Bike_Name_Color" || keys[0]["Unique"] != false {
+			t.Errorf("Unexpected index: %v", keys[0])
+		}
+		if keys[1]["Name"] != "Bike_Name" || keys[1]["Unique"] != true {
+			t.Errorf("Unexpected unique constraint: %v", keys[1])
+		}
+	})
+}
+
+func TestMPRDomainModelRelationships(t *testing.T) {
+	t.Run("associations", func(t *testing.T) {
+		dm := MxDocument{
+			Path: "MyFirstModule",
+			Attributes: bson.M{
+				"$Type": "DomainModels$DomainModel",
+				"Entities": bson.A{
+					int32(3),
+					bson.M{"$ID": primitive.Binary{Data: []byte("order")}, "$Type": "DomainModels$EntityImpl", "Name": "Order"},
+					bson.M{"$ID": primitive.Binary{Data: []byte("customer")}, "$Type": "DomainModels$EntityImpl", "Name": "Customer"},
+				},
+				"Associations": bson.A{
+					int32(3),
+					bson.M{
+						"$Type":         "DomainModels$Association",
+						"Name":          "Order_Customer",
+						"ParentPointer": primitive.Binary{Data: []byte("order")},
+						"ChildPointer":  primitive.Binary{Data: []byte("customer")},
+						"Type":          "Reference",
+						"Owner":         "Default",
+					},
+				},
+				"CrossAssociations": bson.A{
+					int32(3),
+					bson.M{
+						"$Type":         "DomainModels$CrossAssociation",
+						"Name":          "Order_User",
+						"ParentPointer": primitive.Binary{Data: []byte("order")},
+						"Child":         "System.User",
+						"Type":          "ReferenceSet",
+						"Owner":         "Both",
+					},
+				},
+			},
+		}
+		result := transformDomainModel(dm, log)
+		relationships, ok := result.Attributes["Relationships"].([]map[string]interface{})
+		if !ok || len(relationships) != 2 {
+			t.Fatalf("Expected 2 relationships. Got: %v", result.Attributes["Relationships"])
+		}
+		expected := []map[string]string{
+			{"Name": "MyFirstModule.Order_Customer", "Parent": "MyFirstModule.Order", "Child": "MyFirstModule.Customer", "Multiplicity": "many-to-one"},
+			{"Name": "MyFirstModule.Order_User", "Parent": "MyFirstModule.Order", "Child": "System.User", "Multiplicity": "many-to-many"},
+		}
+		for i, relationship := range relationships {
+			for key, value := range expected[i] {
+				if relationship[key] != value {
+					t.Errorf("Expected %s %s, got %v", key, value, relationship[key])
+				}
+			}
+		}
+	})
+}
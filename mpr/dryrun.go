@@ -0,0 +1,29 @@
+package mpr
+
+import (
+	"fmt"
+)
+
+// dryRunDocuments logs the files an export would write for the documents, using the files the export resolves
+func dryRunDocuments(documents []MxDocument, files map[string]string, options ExportOptions) error {
+	log := options.logger()
+	// documents of the flat-module layout share the file of their module
+	sizes := make(map[string]int)
+	order := make([]string, 0, len(documents))
+	for _, document := range documents {
+		id, _ := idString(document.Attributes["$ID"])
+		contents, err := marshal(cleanData(document.Attributes, options.Raw, options.StripKeys), options.Format)
+		if err != nil {
+			return fmt.Errorf("error marshaling %s: %v", files[id], err)
+		}
+		if _, ok := sizes[files[id]]; !ok {
+			order = append(order, files[id])
+		}
+		sizes[files[id]] += len(contents)
+	}
+	for _, file := range order {
+		log.Infof("Would write %s (%d bytes)", file, sizes[file])
+	}
+	log.Infof("Would write %d documents", len(documents))
+	return nil
+}
@@ -0,0 +1,197 @@
+package mpr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// encryptionIterations is the PBKDF2 work factor for new exports. Decryption uses the stored parameters.
+const encryptionIterations = 600000
+
+// encryptionParameters are written unencrypted next to the encrypted files, so the key can be derived again
+type encryptionParameters struct {
+	Algorithm  string `json:"Algorithm"`
+	KDF        string `json:"KDF"`
+	Iterations int    `json:"Iterations"`
+	Salt       string `json:"Salt"`
+}
+
+// encryptingSink encrypts every file with AES-256-GCM and passes it on with an .enc suffix. The path of the file
+// is authenticated as well, so encrypted files cannot be swapped.
+type encryptingSink struct {
+	next Sink
+	aead cipher.AEAD
+}
+
+// newEncryptingSink derives a key from the passphrase and writes the parameters to the sink. The parameters of a
+// previous export in the sink are kept, so the files an incremental export does not write again can still be
+// decrypted; a new export gets a random salt.
+func newEncryptingSink(next Sink, passphrase string, format string) (Sink, error) {
+	format = stateFormat(format)
+	name := "encryption." + fileExtension(format)
+	var parameters encryptionParameters
+	if contents, err := readSinkFile(next, name); err == nil {
+		if err := yaml.Unmarshal(contents, &parameters); err != nil {
+			return nil, fmt.Errorf("error parsing encryption parameters: %v", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error reading encryption parameters: %v", err)
+	} else {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("error generating salt: %v", err)
+		}
+		parameters = encryptionParameters{
+			Algorithm:  "AES-256-GCM",
+			KDF:        "PBKDF2-HMAC-SHA256",
+			Iterations: encryptionIterations,
+			Salt:       base64.StdEncoding.EncodeToString(salt),
+		}
+	}
+	aead, err := newExportCipher(passphrase, parameters)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := marshal(parameters, format)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling encryption parameters: %v", err)
+	}
+	if err := next.MkdirAll(""); err != nil {
+		return nil, fmt.Errorf("error creating directory: %v", err)
+	}
+	if err := next.WriteFile(name, contents); err != nil {
+		return nil, fmt.Errorf("error writing encryption parameters: %v", err)
+	}
+	return &encryptingSink{next: next, aead: aead}, nil
+}
+
+func (s *encryptingSink) MkdirAll(path string) error {
+	return s.next.MkdirAll(path)
+}
+
+func (s *encryptingSink) WriteFile(path string, data []byte) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("error generating nonce: %v", err)
+	}
+	return s.next.WriteFile(path+".enc", s.aead.Seal(nonce, nonce, data, []byte(path)))
+}
+
+// ReadFile decrypts a file written by a previous export, like the export state of an incremental export
+func (s *encryptingSink) ReadFile(path string) ([]byte, error) {
+	sealed, err := readSinkFile(s.next, path+".enc")
+	if err != nil {
+		return nil, err
+	}
+	return openSealed(s.aead, sealed, path)
+}
+
+// openSealed decrypts the contents of an .enc file and checks it was written for the given slash separated path
+func openSealed(aead cipher.AEAD, sealed []byte, path string) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("error decrypting %s: file is too short", path)
+	}
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(path))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting %s: wrong key or corrupted file", path)
+	}
+	return data, nil
+}
+
+// DecryptExport decrypts the files of an export made with ExportOptions.EncryptKey into the output directory
+func DecryptExport(inputDirectory string, outputDirectory string, passphrase string) error {
+	var contents []byte
+	var err error
+	for _, extension := range []string{"yaml", "json"} {
+		if contents, err = os.ReadFile(filepath.Join(inputDirectory, "encryption."+extension)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error reading encryption parameters: %v", err)
+	}
+	var parameters encryptionParameters
+	if err := yaml.Unmarshal(contents, &parameters); err != nil {
+		return fmt.Errorf("error parsing encryption parameters: %v", err)
+	}
+	aead, err := newExportCipher(passphrase, parameters)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(inputDirectory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".enc") {
+			return nil
+		}
+		name, err := filepath.Rel(inputDirectory, strings.TrimSuffix(path, ".enc"))
+		if err != nil {
+			return err
+		}
+		sealed, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		data, err := openSealed(aead, sealed, filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(outputDirectory, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("error creating directory: %v", err)
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
+
+func newExportCipher(passphrase string, parameters encryptionParameters) (cipher.AEAD, error) {
+	if parameters.Algorithm != "AES-256-GCM" || parameters.KDF != "PBKDF2-HMAC-SHA256" || parameters.Iterations <= 0 {
+		return nil, fmt.Errorf("unsupported encryption %s with %s", parameters.Algorithm, parameters.KDF)
+	}
+	salt, err := base64.StdEncoding.DecodeString(parameters.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %v", err)
+	}
+	block, err := aes.NewCipher(pbkdf2SHA256([]byte(passphrase), salt, parameters.Iterations, 32))
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key of keyLength bytes as specified in RFC 8018
+func pbkdf2SHA256(password []byte, salt []byte, iterations int, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLength+prf.Size())
+	for block := uint32(1); len(key) < keyLength; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLength]
+}
@@ -0,0 +1,102 @@
+package mpr
+
+import (
+	"encoding/hex"
+	"os"
+	"testing"
+)
+
+func TestEncryptExport(t *testing.T) {
+	t.Run("pbkdf2", func(t *testing.T) {
+		// test vectors of RFC 7914
+		key := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64))
+		if key != "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783" {
+			t.Errorf("Unexpected key: %s", key)
+		}
+		key = hex.EncodeToString(pbkdf2SHA256([]byte("Password"), []byte("NaCl"), 80000, 64))
+		if key != "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d" {
+			t.Errorf("Unexpected key: %s", key)
+		}
+	})
+
+	t.Run("roundtrip", func(t *testing.T) {
+		outputDirectory := "./../tmp/encrypted"
+		decryptedDirectory := "./../tmp/decrypted"
+		os.RemoveAll(outputDirectory)
+		os.RemoveAll(decryptedDirectory)
+		if err := ExportModel("./../resources/app", outputDirectory, ExportOptions{Mode: "basic"}); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		plain, err := os.ReadFile(outputDirectory + "/Metadata.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read Metadata.yaml: %v", err)
+		}
+		os.RemoveAll(outputDirectory)
+
+		options := ExportOptions{Mode: "basic", EncryptKey: "secret"}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		if _, err := os.Stat(outputDirectory + "/Metadata.yaml"); !os.IsNotExist(err) {
+			t.Errorf("Expected no plain Metadata.yaml")
+		}
+		if _, err := os.Stat(outputDirectory + "/encryption.yaml"); err != nil {
+			t.Errorf("Expected encryption.yaml: %v", err)
+		}
+
+		if err := DecryptExport(outputDirectory, decryptedDirectory, "wrong"); err == nil {
+			t.Errorf("Expected an error for a wrong key")
+		}
+		if err := DecryptExport(outputDirectory, decryptedDirectory, "secret"); err != nil {
+			t.Fatalf("Failed to decrypt export: %v", err)
+		}
+		decrypted, err := os.ReadFile(decryptedDirectory + "/Metadata.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read decrypted Metadata.yaml: %v", err)
+		}
+		if string(decrypted) != string(plain) {
+			t.Errorf("Decrypted Metadata.yaml differs from the plain export")
+		}
+		if _, err := os.Stat(decryptedDirectory + "/MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml"); err != nil {
+			t.Errorf("Expected decrypted microflow: %v", err)
+		}
+	})
+	t.Run("incremental", func(t *testing.T) {
+		outputDirectory := "./../tmp/encrypted-incremental"
+		decryptedDirectory := "./../tmp/decrypted-incremental"
+		os.RemoveAll(outputDirectory)
+		os.RemoveAll(decryptedDirectory)
+		options := ExportOptions{Mode: "basic", EncryptKey: "secret", Incremental: true, Checksums: true}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		parameters, err := os.ReadFile(outputDirectory + "/encryption.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read encryption.yaml: %v", err)
+		}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model again: %v", err)
+		}
+		again, err := os.ReadFile(outputDirectory + "/encryption.yaml")
+		if err != nil || string(again) != string(parameters) {
+			t.Errorf("Expected the encryption parameters of the first export to be kept")
+		}
+		sink, err := newEncryptingSink(NewLocalSink(outputDirectory), "secret", "yaml")
+		if err != nil {
+			t.Fatalf("Failed to create encrypting sink: %v", err)
+		}
+		if state := readExportState(sink, "yaml", noopLogger{}); len(state.Files) != 1 {
+			t.Errorf("Expected the encrypted export state to be read back. Got: %v", state.Files)
+		}
+		if _, err := os.Stat(outputDirectory + "/export-state.yaml"); !os.IsNotExist(err) {
+			t.Errorf("Expected no plain export-state.yaml")
+		}
+		// the checksums are computed over the encrypted files
+		if err := VerifyExport(outputDirectory); err != nil {
+			t.Errorf("Failed to verify encrypted export: %v", err)
+		}
+		if err := DecryptExport(outputDirectory, decryptedDirectory, "secret"); err != nil {
+			t.Fatalf("Failed to decrypt export: %v", err)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package mpr
+
+import (
+	"sort"
+	"strings"
+)
+
+// exportEntitiesSummary writes entities.yaml with every entity of the model: its attributes with their types,
+// its generalization and the associations it is the parent of, all by qualified name.
+func exportEntitiesSummary(documents []MxDocument, output Sink, format string, log Logger) error {
+	entities := make([]map[string]interface{}, 0)
+	for _, document := range documents {
+		if document.Type != "DomainModels$DomainModel" {
+			continue
+		}
+		module := getMxModuleName(document.Path)
+		associations := make(map[string][]map[string]interface{})
+		for _, relationship := range getMxRelationships(document.Attributes, module) {
+			parent, _ := relationship["Parent"].(string)
+			associations[parent] = append(associations[parent], map[string]interface{}{
+				"Name":         relationship["Name"],
+				"Target":       relationship["Child"],
+				"Multiplicity": relationship["Multiplicity"],
+			})
+		}
+		for _, entity := range getObjectList(document.Attributes["Entities"]) {
+			name := qualifiedName(module, entity["Name"])
+			summary := map[string]interface{}{
+				"Name":         name,
+				"Attributes":   getMxEntityAttributes(entity),
+				"Associations": associations[name],
+			}
+			if associations[name] == nil {
+				summary["Associations"] = make([]map[string]interface{}, 0)
+			}
+			if generalization, ok := getObject(entity["MaybeGeneralization"]); ok {
+				if parent, ok := generalization["Generalization"].(string); ok && parent != "" {
+					summary["Generalization"] = parent
+				}
+			}
+			entities = append(entities, summary)
+		}
+	}
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i]["Name"].(string) < entities[j]["Name"].(string)
+	})
+
+	log.Infof("Writing summary of %d entities", len(entities))
+	return writeFile(output, "entities."+fileExtension(format), map[string]interface{}{"Entities": entities}, format, log)
+}
+
+// getMxEntityAttributes lists the attributes of an entity with their type, e.g. String, and the details of the type
+// like the length of strings or the enumeration of enumerations
+func getMxEntityAttributes(entity map[string]interface{}) []map[string]interface{} {
+	attributes := make([]map[string]interface{}, 0)
+	for _, attribute := range getObjectList(entity["Attributes"]) {
+		summary := map[string]interface{}{"Name": attribute["Name"]}
+		if attributeType, ok := getObject(attribute["NewType"]); ok {
+			typeName, _ := attributeType["$Type"].(string)
+			typeName = strings.TrimSuffix(strings.TrimPrefix(typeName, "DomainModels$"), "AttributeType")
+			summary["Type"] = typeName
+			if length, ok := attributeType["Length"]; ok {
+				summary["Length"] = length
+			}
+			if enumeration, ok := attributeType["Enumeration"].(string); ok {
+				summary["Enumeration"] = enumeration
+			}
+		}
+		attributes = append(attributes, summary)
+	}
+	return attributes
+}
@@ -0,0 +1,90 @@
+package mpr
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// fileNameFields are the fields available in file name templates
+type fileNameFields struct {
+	Name string
+	Type string
+	Path string
+}
+
+// parseFileNameTemplate parses a file name template like {{.Type}}/{{.Name}} and checks that it produces a valid
+// relative path. An empty text returns nil, which selects the default <Name>.<Type> file names.
+func parseFileNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("filename").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file name template %s: %v", text, err)
+	}
+	sample := MxDocument{Name: "Name", Type: "Microflows$Microflow", Path: "Module/Folder"}
+	if _, err := executeFileNameTemplate(tmpl, sample, "yaml"); err != nil {
+		return nil, fmt.Errorf("invalid file name template %s: %v", text, err)
+	}
+	return tmpl, nil
+}
+
+// executeFileNameTemplate returns the file name of a document relative to its folder. The extension of the format
+// is appended, and the name may contain slashes to write the document to a sub-path.
+func executeFileNameTemplate(tmpl *template.Template, document MxDocument, format string) (string, error) {
+	var builder strings.Builder
+	err := tmpl.Execute(&builder, fileNameFields{
+		Name: sanitizeFilename(document.Name),
+		Type: document.Type,
+		Path: filepath.ToSlash(document.Path),
+	})
+	if err != nil {
+		return "", err
+	}
+	name := filepath.ToSlash(filepath.Clean(builder.String()))
+	if name == "." || name == "" || strings.HasSuffix(builder.String(), "/") || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("%q is not a valid relative file name", builder.String())
+	}
+	return name + "." + fileExtension(format), nil
+}
+
+// getRootFolderPath returns the directory the project documents and modules are written to, which is the root of
+// the output unless options.RootFolderName is set
+func getRootFolderPath(options ExportOptions) string {
+	root := sanitizeFilename(options.RootFolderName)
+	if root == "." {
+		return ""
+	}
+	return root
+}
+
+// getDocumentFiles resolves the output file of every document once, relative to the output directory and keyed by
+// its $ID, so everything that refers to document files uses the paths they are written to. The file names relative
+// to the folder of the document are returned as well.
+func getDocumentFiles(documents []MxDocument, moduleDirectories map[string]string, options ExportOptions) (map[string]string, map[string]string, error) {
+	fileNameTemplate, err := parseFileNameTemplate(options.FileNameTemplate)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileNames, err := resolveFileNames(documents, options.Format, options.OnCollision, fileNameTemplate, options.logger())
+	if err != nil {
+		return nil, nil, err
+	}
+	root := getRootFolderPath(options)
+	files := make(map[string]string, len(documents))
+	for _, document := range documents {
+		id, _ := idString(document.Attributes["$ID"])
+		document.Path = moduleDocumentPath(document.Path, moduleDirectories)
+		switch options.Layout {
+		case "flat-module":
+			files[id] = filepath.ToSlash(filepath.Join(root, getFlatModuleFileName(document, options.Format)))
+		case "by-type":
+			files[id] = filepath.ToSlash(filepath.Join(sanitizeFilename(document.Type), root, document.Path, fileNames[id]))
+		default:
+			files[id] = filepath.ToSlash(filepath.Join(root, document.Path, fileNames[id]))
+		}
+	}
+	return files, fileNames, nil
+}
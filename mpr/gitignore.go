@@ -0,0 +1,53 @@
+package mpr
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// gitIgnorePatterns are the auxiliary files of an export that do not belong in version control
+var gitIgnorePatterns = []string{
+	"/export-state.*",
+	"/checksums.*",
+	"/export.log",
+	"*.bson",
+	"*.tar.gz",
+}
+
+// writeGitIgnore adds the auxiliary files of the export to the .gitignore in the sink. Patterns already listed
+// are kept as they are, so an existing .gitignore is extended rather than replaced.
+func writeGitIgnore(output Sink) error {
+	contents, err := readSinkFile(output, ".gitignore")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error reading .gitignore: %v", err)
+	}
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(normalizeLineEndings(string(contents)), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, pattern := range gitIgnorePatterns {
+		if !existing[pattern] {
+			missing = append(missing, pattern)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	text := normalizeLineEndings(string(contents))
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	text += "# auxiliary files of mxlint exports\n" + strings.Join(missing, "\n") + "\n"
+	if err := writeSinkFile(output, ".gitignore", []byte(text)); err != nil {
+		return fmt.Errorf("error writing .gitignore: %v", err)
+	}
+	return nil
+}
+
+// normalizeLineEndings replaces Windows line endings by LF, so text files look the same on every platform
+func normalizeLineEndings(text string) string {
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
@@ -0,0 +1,47 @@
+package mpr
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteGitIgnore(t *testing.T) {
+	outputDirectory := "./../tmp/gitignore"
+	os.RemoveAll(outputDirectory)
+	if err := os.MkdirAll(outputDirectory, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(outputDirectory+"/.gitignore", []byte("node_modules\r\n/export.log"), 0644); err != nil {
+		t.Fatalf("Failed to write .gitignore: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := writeGitIgnore(NewLocalSink(outputDirectory)); err != nil {
+			t.Fatalf("Failed to write .gitignore: %v", err)
+		}
+	}
+	contents, err := os.ReadFile(outputDirectory + "/.gitignore")
+	if err != nil {
+		t.Fatalf("Failed to read .gitignore: %v", err)
+	}
+	expected := "node_modules\n/export.log\n# auxiliary files of mxlint exports\n/export-state.*\n/checksums.*\n*.bson\n*.tar.gz\n"
+	if string(contents) != expected {
+		t.Errorf("Unexpected .gitignore. Got:\n%s", contents)
+	}
+
+	t.Run("export", func(t *testing.T) {
+		exportDirectory := "./../tmp/gitignore-export"
+		os.RemoveAll(exportDirectory)
+		if err := ExportModel("./../resources/app", exportDirectory, ExportOptions{Mode: "basic", GitIgnore: true, Incremental: true}); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		contents, err := os.ReadFile(exportDirectory + "/.gitignore")
+		if err != nil {
+			t.Fatalf("Failed to read .gitignore: %v", err)
+		}
+		if !strings.Contains(string(contents), "/export-state.*\n") {
+			t.Errorf("Expected the export state to be ignored. Got:\n%s", contents)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package mpr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type graphObject struct {
+	ID       string
+	Type     string
+	Name     string
+	Document string
+	Contents map[string]interface{}
+}
+
+func exportGraphML(units []MxUnit, folderPaths map[string]string, output Sink, log Logger) error {
+	log.Infof("Building object reference graph")
+
+	objects, edges := buildObjectGraph(units, folderPaths)
+	graph := GraphMLGraph{
+		ID:          "model",
+		EdgeDefault: "directed",
+		Nodes:       make([]GraphMLNode, 0, len(objects)),
+		Edges:       edges,
+	}
+	for _, obj := range objects {
+		graph.Nodes = append(graph.Nodes, GraphMLNode{
+			ID: obj.ID,
+			Data: []GraphMLData{
+				{Key: "type", Value: obj.Type},
+				{Key: "name", Value: obj.Name},
+				{Key: "document", Value: obj.Document},
+			},
+		})
+	}
+	log.Infof("Found %d objects and %d references", len(graph.Nodes), len(graph.Edges))
+
+	graphML := GraphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []GraphMLKey{
+			{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+			{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+			{ID: "document", For: "node", AttrName: "document", AttrType: "string"},
+			{ID: "field", For: "edge", AttrName: "field", AttrType: "string"},
+		},
+		Graph: graph,
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buffer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(graphML); err != nil {
+		return fmt.Errorf("error encoding graphml: %v", err)
+	}
+	if err := writeSinkFile(output, "graph.graphml", buffer.Bytes()); err != nil {
+		return fmt.Errorf("error writing graphml file: %v", err)
+	}
+	return nil
+}
+
+// buildObjectGraph returns every object with an $ID and the references between them
+func buildObjectGraph(units []MxUnit, folderPaths map[string]string) ([]graphObject, []GraphMLEdge) {
+	objects := make([]graphObject, 0)
+	for _, unit := range units {
+		document := folderPaths[unit.ContainerID]
+		if name, ok := unit.Contents["Name"].(string); ok {
+			document = filepath.ToSlash(filepath.Join(document, name))
+		}
+		collectGraphObjects(unit.Contents, document, &objects)
+	}
+
+	index := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		index[obj.ID] = true
+	}
+
+	edges := make([]GraphMLEdge, 0)
+	for _, obj := range objects {
+		for _, key := range sortedKeys(obj.Contents) {
+			collectGraphEdges(obj.ID, key, obj.Contents[key], index, &edges)
+		}
+	}
+	return objects, edges
+}
+
+// collectGraphObjects registers every nested object that carries an $ID as a node.
+func collectGraphObjects(value interface{}, document string, objects *[]graphObject) {
+	switch v := value.(type) {
+	case bson.M:
+		collectGraphObjects(map[string]interface{}(v), document, objects)
+	case map[string]interface{}:
+		if id, ok := idString(v["$ID"]); ok {
+			obj := graphObject{
+				ID:       id,
+				Document: document,
+				Contents: v,
+			}
+			obj.Type, _ = v["$Type"].(string)
+			obj.Name, _ = v["Name"].(string)
+			*objects = append(*objects, obj)
+		}
+		for _, key := range sortedKeys(v) {
+			collectGraphObjects(v[key], document, objects)
+		}
+	case primitive.A:
+		collectGraphObjects([]interface{}(v), document, objects)
+	case []interface{}:
+		for _, item := range v {
+			collectGraphObjects(item, document, objects)
+		}
+	}
+}
+
+// collectGraphEdges adds an edge for every binary ID value below an object that points at a known object.
+// Nested objects with their own $ID are skipped as they are visited as nodes themselves.
+func collectGraphEdges(source string, field string, value interface{}, index map[string]bool, edges *[]GraphMLEdge) {
+	switch v := value.(type) {
+	case primitive.Binary, string:
+		if field == "$ID" {
+			return
+		}
+		target, _ := idString(v)
+		if index[target] {
+			*edges = append(*edges, GraphMLEdge{
+				Source: source,
+				Target: target,
+				Data:   []GraphMLData{{Key: "field", Value: field}},
+			})
+		}
+	case bson.M:
+		collectGraphEdges(source, field, map[string]interface{}(v), index, edges)
+	case map[string]interface{}:
+		if _, ok := v["$ID"]; ok {
+			return
+		}
+		for _, key := range sortedKeys(v) {
+			collectGraphEdges(source, field+"."+key, v[key], index, edges)
+		}
+	case primitive.A:
+		collectGraphEdges(source, field, []interface{}(v), index, edges)
+	case []interface{}:
+		for _, item := range v {
+			collectGraphEdges(source, field, item, index, edges)
+		}
+	}
+}
+
+func sortedKeys(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
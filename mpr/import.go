@@ -0,0 +1,264 @@
+package mpr
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportModel rebuilds an mpr file from an exported directory with Metadata.yaml and the document files.
+// This is a best-effort conversion: units keep their contents and IDs when the export was made with raw,
+// folders get new IDs and attributes that were left out of the export are lost.
+func ImportModel(inputDirectory string, outputMPRPath string, options ImportOptions) error {
+	log := options.logger()
+	if _, err := os.Stat(outputMPRPath); err == nil {
+		return fmt.Errorf("output %s already exists", outputMPRPath)
+	}
+	metadata, err := readMetadataFile(inputDirectory)
+	if err != nil {
+		return err
+	}
+	units, err := getImportUnits(inputDirectory, metadata, log)
+	if err != nil {
+		return err
+	}
+	log.Infof("Importing %d units into %s", len(units), outputMPRPath)
+	return writeMPR(outputMPRPath, metadata, units)
+}
+
+func readMetadataFile(inputDirectory string) (MxMetadata, error) {
+	var metadata MxMetadata
+	for _, format := range []string{"yaml", "json"} {
+		contents, err := os.ReadFile(filepath.Join(inputDirectory, "Metadata."+fileExtension(format)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return metadata, fmt.Errorf("error reading metadata file: %v", err)
+		}
+		if err := yaml.Unmarshal(contents, &metadata); err != nil {
+			return metadata, fmt.Errorf("error parsing metadata file: %v", err)
+		}
+		return metadata, nil
+	}
+	return metadata, fmt.Errorf("no metadata file found in %s", inputDirectory)
+}
+
+// getImportUnits reconstructs the project, module, folder and document units of an exported directory
+func getImportUnits(inputDirectory string, metadata MxMetadata, log Logger) ([]MxUnit, error) {
+	projectID := newUnitID()
+	units := []MxUnit{{
+		UnitID:          projectID,
+		ContainerID:     projectID,
+		ContainmentName: "",
+		Contents:        bson.M{"$ID": unitIDBinary(projectID), "$Type": "Projects$Project"},
+	}}
+
+	// folders are keyed by their path relative to the input directory
+	folderIDs := map[string]string{".": projectID}
+	moduleNames := make(map[string]bool)
+	for _, module := range metadata.Modules {
+		contents, _ := toBSONValue(module.Attributes).(bson.M)
+		if contents == nil {
+			contents = bson.M{"$Type": "Projects$ModuleImpl", "Name": module.Name}
+		}
+		units = append(units, MxUnit{UnitID: module.ID, ContainerID: projectID, ContainmentName: "Modules", Contents: contents})
+		folderIDs[module.Name] = module.ID
+		moduleNames[module.Name] = true
+	}
+
+	err := filepath.Walk(inputDirectory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.HasSuffix(info.Name(), ".strings") {
+			return filepath.SkipDir
+		}
+		// document files are named after their type, e.g. MyFlow.Microflows$Microflow.yaml
+		extension := filepath.Ext(info.Name())
+		if info.IsDir() || !strings.Contains(info.Name(), "$") || (extension != ".yaml" && extension != ".json") {
+			return nil
+		}
+		relativePath, err := filepath.Rel(inputDirectory, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		segments := strings.Split(filepath.ToSlash(relativePath), "/")
+		if relativePath != "." && !moduleNames[segments[0]] {
+			log.Warnf("Skipping %s outside of a known module", path)
+			return nil
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		var attributes map[string]interface{}
+		if err := yaml.Unmarshal(contents, &attributes); err != nil {
+			return fmt.Errorf("error parsing %s: %v", path, err)
+		}
+		document, _ := toBSONValue(attributes).(bson.M)
+		if document == nil {
+			log.Warnf("Skipping empty document %s", path)
+			return nil
+		}
+
+		unitID, ok := unitIDFromBinary(document["$ID"])
+		if !ok {
+			log.Debugf("Document %s has no $ID, generating a new one", path)
+			unitID = newUnitID()
+			document["$ID"] = unitIDBinary(unitID)
+		}
+		for i := range segments {
+			folderPath := strings.Join(segments[:i+1], "/")
+			if _, ok := folderIDs[folderPath]; ok {
+				continue
+			}
+			folderID := newUnitID()
+			units = append(units, MxUnit{
+				UnitID:          folderID,
+				ContainerID:     folderIDs[strings.Join(segments[:i], "/")],
+				ContainmentName: "Folders",
+				Contents:        bson.M{"$ID": unitIDBinary(folderID), "$Type": "Projects$Folder", "Name": segments[i]},
+			})
+			folderIDs[folderPath] = folderID
+		}
+		units = append(units, MxUnit{
+			UnitID:          unitID,
+			ContainerID:     folderIDs[filepath.ToSlash(relativePath)],
+			ContainmentName: getImportContainmentName(document["$Type"], relativePath),
+			Contents:        document,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// getImportContainmentName returns the containment name of a document by its type and location
+func getImportContainmentName(documentType interface{}, relativePath string) string {
+	if relativePath == "." {
+		return "ProjectDocuments"
+	}
+	if !strings.Contains(filepath.ToSlash(relativePath), "/") {
+		switch documentType {
+		case "DomainModels$DomainModel":
+			return "DomainModel"
+		case "Projects$ModuleSettings":
+			return "ModuleSettings"
+		case "Security$ModuleSecurity":
+			return "ModuleSecurity"
+		}
+	}
+	return "Documents"
+}
+
+// toBSONValue converts parsed yaml back to the BSON types of the model. Binary values are written as Data and Subtype
+// and whole numbers become int32 where they fit, like in the original units.
+func toBSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if data, ok := v["Data"].(string); ok && len(v) == 2 {
+			if subtype, ok := v["Subtype"].(float64); ok {
+				if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
+					return primitive.Binary{Subtype: byte(subtype), Data: decoded}
+				}
+			}
+		}
+		result := make(bson.M, len(v))
+		for key, item := range v {
+			result[key] = toBSONValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make(bson.A, 0, len(v))
+		for _, item := range v {
+			result = append(result, toBSONValue(item))
+		}
+		return result
+	case float64:
+		if v != math.Trunc(v) {
+			return v
+		}
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int32(v)
+		}
+		return int64(v)
+	}
+	return value
+}
+
+func unitIDFromBinary(value interface{}) (string, bool) {
+	if binary, ok := value.(primitive.Binary); ok {
+		return base64.StdEncoding.EncodeToString(binary.Data), true
+	}
+	return "", false
+}
+
+func unitIDBinary(unitID string) primitive.Binary {
+	data, _ := base64.StdEncoding.DecodeString(unitID)
+	return primitive.Binary{Subtype: 0, Data: data}
+}
+
+func newUnitID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return base64.StdEncoding.EncodeToString(id)
+}
+
+// writeMPR writes the units and metadata to a new sqlite database
+func writeMPR(MPRFilePath string, metadata MxMetadata, units []MxUnit) error {
+	db, err := sql.Open("sqlite", MPRFilePath)
+	if err != nil {
+		return fmt.Errorf("error opening database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE Unit (UnitID BLOB PRIMARY KEY, ContainerID BLOB, ContainmentName TEXT, Contents BLOB)"); err != nil {
+		return fmt.Errorf("error creating unit table: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE _MetaData (_ProductVersion TEXT, _BuildVersion TEXT)"); err != nil {
+		return fmt.Errorf("error creating metadata table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO _MetaData (_ProductVersion, _BuildVersion) VALUES (?, ?)", metadata.ProductVersion, metadata.BuildVersion); err != nil {
+		return fmt.Errorf("error writing metadata: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	for _, unit := range units {
+		unitID, err := base64.StdEncoding.DecodeString(unit.UnitID)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error decoding unit id %s: %v", unit.UnitID, err)
+		}
+		containerID, err := base64.StdEncoding.DecodeString(unit.ContainerID)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error decoding container id %s: %v", unit.ContainerID, err)
+		}
+		contents, err := bson.Marshal(unit.Contents)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error encoding unit %s: %v", unit.UnitID, err)
+		}
+		if _, err := tx.Exec("INSERT INTO Unit (UnitID, ContainerID, ContainmentName, Contents) VALUES (?, ?, ?, ?)", unitID, containerID, unit.ContainmentName, contents); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error writing unit %s: %v", unit.UnitID, err)
+		}
+	}
+	return tx.Commit()
+}
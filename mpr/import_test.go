@@ -0,0 +1,50 @@
+package mpr
+
+import (
+	"os"
+	"testing"
+)
+
+func TestImportModel(t *testing.T) {
+	t.Run("roundtrip", func(t *testing.T) {
+		exportDirectory := "./../tmp/import"
+		MPRFilePath := "./../tmp/imported.mpr"
+		os.RemoveAll(exportDirectory)
+		os.Remove(MPRFilePath)
+		if err := ExportModel("./../resources/app/App.mpr", exportDirectory, ExportOptions{Raw: true, Mode: "basic"}); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		if err := ImportModel(exportDirectory, MPRFilePath, ImportOptions{}); err != nil {
+			t.Fatalf("Failed to import model: %v", err)
+		}
+
+		originalDocuments, originalMetadata, err := ExportModelToMemory("./../resources/app/App.mpr", ExportOptions{Raw: true, Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to read original model: %v", err)
+		}
+		importedDocuments, importedMetadata, err := ExportModelToMemory(MPRFilePath, ExportOptions{Raw: true, Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to read imported model: %v", err)
+		}
+		if importedMetadata.ProductVersion != originalMetadata.ProductVersion || len(importedMetadata.Modules) != len(originalMetadata.Modules) {
+			t.Errorf("Expected metadata to be preserved. Got: %s with %d modules", importedMetadata.ProductVersion, len(importedMetadata.Modules))
+		}
+		documents := make(map[string]bool)
+		for _, document := range importedDocuments {
+			documents[document.Path+"/"+getMxDocumentFileName(document, "yaml")] = true
+		}
+		if len(importedDocuments) != len(originalDocuments) {
+			t.Errorf("Expected %d documents, got %d", len(originalDocuments), len(importedDocuments))
+		}
+		for _, document := range originalDocuments {
+			if !documents[document.Path+"/"+getMxDocumentFileName(document, "yaml")] {
+				t.Errorf("Expected %s/%s to be imported", document.Path, document.Name)
+			}
+		}
+	})
+	t.Run("existing output", func(t *testing.T) {
+		if err := ImportModel("./../tmp/import", "./../resources/app/App.mpr", ImportOptions{}); err == nil {
+			t.Errorf("Expected error when the output already exists")
+		}
+	})
+}
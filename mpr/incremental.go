@@ -0,0 +1,68 @@
+package mpr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+)
+
+// exportState holds the content hash of every exported mpr file keyed by its absolute path
+type exportState struct {
+	output Sink
+	name   string
+	format string
+	Files  map[string]string `json:"Files"`
+}
+
+// readExportState reads the state of the previous run back from the sink. Sinks that cannot be read back start
+// without state, so every file is exported.
+func readExportState(output Sink, format string, log Logger) *exportState {
+	state := &exportState{
+		output: output,
+		name:   "export-state." + fileExtension(format),
+		format: format,
+		Files:  make(map[string]string),
+	}
+	contents, err := readSinkFile(output, state.name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Warnf("Error reading export state %s, exporting all files: %v", state.name, err)
+		}
+		return state
+	}
+	if err := yaml.Unmarshal(contents, state); err != nil || state.Files == nil {
+		log.Warnf("Invalid export state %s, exporting all files", state.name)
+		state.Files = make(map[string]string)
+	}
+	return state
+}
+
+// unchanged returns whether the mpr file has the same hash as in the previous run and the key and hash to record
+func (s *exportState) unchanged(MPRFilePath string) (string, string, bool, error) {
+	key, err := filepath.Abs(MPRFilePath)
+	if err != nil {
+		return "", "", false, err
+	}
+	file, err := os.Open(MPRFilePath)
+	if err != nil {
+		return "", "", false, fmt.Errorf("error reading %s: %v", MPRFilePath, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", "", false, fmt.Errorf("error reading %s: %v", MPRFilePath, err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	return key, sum, s.Files[key] == sum, nil
+}
+
+func (s *exportState) write(log Logger) error {
+	return writeFile(s.output, s.name, map[string]interface{}{"Files": s.Files}, s.format, log)
+}
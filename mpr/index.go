@@ -0,0 +1,122 @@
+package mpr
+
+import (
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MxDocumentRef is the target of a reference to a document
+type MxDocumentRef struct {
+	Name string `yaml:"Name"`
+	Type string `yaml:"Type"`
+}
+
+// buildDocumentIndex maps the unit ID and the $ID of every document to its qualified name (Module.Document)
+func buildDocumentIndex(units []MxUnit, folderPaths map[string]string, version MxVersion) map[string]string {
+	index := make(map[string]string)
+	for id, ref := range buildDocumentRefIndex(units, folderPaths, version) {
+		index[id] = ref.Name
+	}
+	return index
+}
+
+// buildDocumentRefIndex maps the unit ID and the $ID of every document to its qualified name and type
+func buildDocumentRefIndex(units []MxUnit, folderPaths map[string]string, version MxVersion) map[string]MxDocumentRef {
+	index := make(map[string]MxDocumentRef)
+	for _, unit := range units {
+		name, ok := unit.Contents["Name"].(string)
+		if !ok || name == "" || unit.ContainmentName != containmentName("Documents", version) {
+			continue
+		}
+		module := getMxModuleName(folderPaths[unit.ContainerID])
+		if module == "" {
+			continue
+		}
+		documentType, _ := unit.Contents["$Type"].(string)
+		ref := MxDocumentRef{Name: module + "." + name, Type: documentType}
+		index[unit.UnitID] = ref
+		if id, ok := idString(unit.Contents["$ID"]); ok {
+			index[id] = ref
+		}
+	}
+	return index
+}
+
+// resolveReferences adds _<Key>Ref with the name and type of the target next to every attribute that holds the
+// ID of a document, or a list of them. The IDs themselves are kept.
+func resolveReferences(value interface{}, refIndex map[string]MxDocumentRef) {
+	switch v := value.(type) {
+	case bson.M:
+		resolveReferences(map[string]interface{}(v), refIndex)
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if key == "$ID" || strings.HasPrefix(key, "_") {
+				continue
+			}
+			if ref, ok := lookupDocumentRef(v[key], refIndex); ok {
+				v["_"+key+"Ref"] = ref
+				continue
+			}
+			if refs, ok := lookupDocumentRefs(v[key], refIndex); ok {
+				v["_"+key+"Ref"] = refs
+				continue
+			}
+			resolveReferences(v[key], refIndex)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			resolveReferences(item, refIndex)
+		}
+	case primitive.A:
+		resolveReferences([]interface{}(v), refIndex)
+	case []interface{}:
+		for _, item := range v {
+			resolveReferences(item, refIndex)
+		}
+	}
+}
+
+func lookupDocumentRef(value interface{}, refIndex map[string]MxDocumentRef) (MxDocumentRef, bool) {
+	if id, ok := idString(value); ok {
+		ref, ok := refIndex[id]
+		return ref, ok
+	}
+	return MxDocumentRef{}, false
+}
+
+// lookupDocumentRefs resolves a list of IDs, skipping the leading array type marker of BSON arrays
+func lookupDocumentRefs(value interface{}, refIndex map[string]MxDocumentRef) ([]MxDocumentRef, bool) {
+	var items []interface{}
+	switch v := value.(type) {
+	case primitive.A:
+		items = v
+	case []interface{}:
+		items = v
+	default:
+		return nil, false
+	}
+	refs := make([]MxDocumentRef, 0, len(items))
+	for i, item := range items {
+		if _, isMarker := item.(int32); isMarker && i == 0 {
+			continue
+		}
+		ref, ok := lookupDocumentRef(item, refIndex)
+		if !ok {
+			return nil, false
+		}
+		refs = append(refs, ref)
+	}
+	return refs, len(refs) > 0
+}
+
+// getMxModuleName returns the top-level folder of a document path, which is the module name
+func getMxModuleName(documentPath string) string {
+	documentPath = filepath.ToSlash(filepath.Clean(documentPath))
+	if documentPath == "." || documentPath == "" {
+		return ""
+	}
+	return strings.SplitN(documentPath, "/", 2)[0]
+}
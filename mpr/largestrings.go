@@ -0,0 +1,76 @@
+package mpr
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// limitLargeStrings truncates or externalizes string values longer than maxLength characters.
+// Externalized strings are written to a sidecar directory of the sink next to the document file and replaced by
+// a reference to the sidecar file relative to the document directory. Sidecar files hold the string as it is in
+// the model, including its line endings.
+func limitLargeStrings(value interface{}, key string, maxLength int, mode string, output Sink, sidecarDirectory string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if length <= maxLength {
+			return v, nil
+		}
+		if mode == "truncate" {
+			return fmt.Sprintf("%s... (%d characters truncated)", string([]rune(v)[:maxLength]), length-maxLength), nil
+		}
+		fileName := sanitizeKey(key) + ".txt"
+		if err := writeSinkFile(output, path.Join(sidecarDirectory, fileName), []byte(v)); err != nil {
+			return nil, fmt.Errorf("error writing sidecar file: %v", err)
+		}
+		return map[string]interface{}{
+			"$File":  path.Join(path.Base(sidecarDirectory), fileName),
+			"Length": length,
+		}, nil
+	case bson.M:
+		if err := limitLargeStringsInObject(v, key, maxLength, mode, output, sidecarDirectory); err != nil {
+			return nil, err
+		}
+	case map[string]interface{}:
+		if err := limitLargeStringsInObject(v, key, maxLength, mode, output, sidecarDirectory); err != nil {
+			return nil, err
+		}
+	case primitive.A:
+		return limitLargeStrings([]interface{}(v), key, maxLength, mode, output, sidecarDirectory)
+	case []interface{}:
+		for i, item := range v {
+			result, err := limitLargeStrings(item, listItemKey(key, i, item), maxLength, mode, output, sidecarDirectory)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = result
+		}
+	case []map[string]interface{}:
+		for i, item := range v {
+			if err := limitLargeStringsInObject(item, listItemKey(key, i, item), maxLength, mode, output, sidecarDirectory); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
+
+func limitLargeStringsInObject(object map[string]interface{}, key string, maxLength int, mode string, output Sink, sidecarDirectory string) error {
+	for k, item := range object {
+		result, err := limitLargeStrings(item, joinKey(key, k), maxLength, mode, output, sidecarDirectory)
+		if err != nil {
+			return err
+		}
+		object[k] = result
+	}
+	return nil
+}
+
+func sanitizeKey(key string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", "[", ".", "]", "", " ", "_").Replace(key)
+}
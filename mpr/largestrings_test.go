@@ -0,0 +1,49 @@
+package mpr
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLimitLargeStrings(t *testing.T) {
+	script := strings.Repeat("x", 100)
+	t.Run("truncate", func(t *testing.T) {
+		data := bson.M{"Name": "Short", "Script": script}
+		if _, err := limitLargeStrings(data, "", 10, "truncate", nil, ""); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if data["Name"] != "Short" {
+			t.Errorf("Short strings should be kept")
+		}
+		if data["Script"] != "xxxxxxxxxx... (90 characters truncated)" {
+			t.Errorf("Unexpected truncated string. Got: %v", data["Script"])
+		}
+	})
+	t.Run("externalize", func(t *testing.T) {
+		data := bson.M{"Items": []interface{}{bson.M{"Name": "Action", "Script": script}}}
+		if _, err := limitLargeStrings(data, "", 10, "externalize", NewLocalSink("./../tmp"), "Doc.strings"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		reference, ok := data["Items"].([]interface{})[0].(bson.M)["Script"].(map[string]interface{})
+		if !ok || reference["$File"] != "Doc.strings/Items.Action.Script.txt" {
+			t.Fatalf("Unexpected reference. Got: %v", reference)
+		}
+		contents, err := os.ReadFile("./../tmp/Doc.strings/Items.Action.Script.txt")
+		if err != nil || string(contents) != script {
+			t.Errorf("Expected sidecar file with the full string: %v", err)
+		}
+	})
+	t.Run("line endings", func(t *testing.T) {
+		data := bson.M{"Documentation": "first line\r\nsecond line\r\n"}
+		if _, err := limitLargeStrings(data, "", 10, "externalize", NewLocalSink("./../tmp"), "Lines.strings"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		contents, err := os.ReadFile("./../tmp/Lines.strings/Documentation.txt")
+		if err != nil || string(contents) != "first line\r\nsecond line\r\n" {
+			t.Errorf("Expected sidecar file with the original line endings. Got: %q, %v", contents, err)
+		}
+	})
+}
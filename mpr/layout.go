@@ -0,0 +1,40 @@
+package mpr
+
+import (
+	"fmt"
+	"sort"
+)
+
+// getFlatModuleFileName returns the file that holds the document in the flat-module layout.
+// Documents outside of modules, like the project settings, are written to Project.
+func getFlatModuleFileName(document MxDocument, format string) string {
+	module := getMxModuleName(document.Path)
+	if module == "" {
+		module = "Project"
+	}
+	return module + "." + fileExtension(format)
+}
+
+// writeFlatModules writes one file per module with the list of its documents, ordered by path, name and type
+func writeFlatModules(output Sink, modules map[string][]map[string]interface{}, format string, log Logger) error {
+	for fname, documents := range modules {
+		sort.Slice(documents, func(i, j int) bool {
+			for _, key := range []string{"Path", "Name", "Type"} {
+				a, b := documents[i][key].(string), documents[j][key].(string)
+				if a != b {
+					return a < b
+				}
+			}
+			return false
+		})
+		contents, err := marshal(documents, format)
+		if err != nil {
+			return fmt.Errorf("error marshaling %s: %v", fname, err)
+		}
+		log.Debugf("Writing file %s", fname)
+		if err := writeSinkFile(output, fname, contents); err != nil {
+			return fmt.Errorf("error writing file: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package mpr
+
+import ()
+
+// exportLinks writes every resolved $ID reference as a source document, source field and target document triple
+func exportLinks(units []MxUnit, folderPaths map[string]string, output Sink, format string, log Logger) error {
+	log.Infof("Resolving cross references")
+
+	objects, edges := buildObjectGraph(units, folderPaths)
+	documents := make(map[string]graphObject, len(objects))
+	for _, obj := range objects {
+		documents[obj.ID] = obj
+	}
+
+	links := make([]map[string]interface{}, 0, len(edges))
+	for _, edge := range edges {
+		source := documents[edge.Source]
+		field := edge.Data[0].Value
+		if source.Type != "" {
+			field = source.Type + "." + field
+		}
+		links = append(links, map[string]interface{}{
+			"Source": source.Document,
+			"Field":  field,
+			"Target": documents[edge.Target].Document,
+		})
+	}
+	log.Infof("Found %d links", len(links))
+
+	return writeFile(output, "links."+fileExtension(format), map[string]interface{}{"Links": links}, format, log)
+}
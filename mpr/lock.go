@@ -0,0 +1,37 @@
+package mpr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// withLockRetries runs read and retries it with exponential backoff while the database is locked, for example
+// because the model is open in Studio Pro. It gives up after LockRetries attempts or once LockTimeout has passed.
+func withLockRetries(ctx context.Context, MPRFilePath string, options ExportOptions, read func() error) error {
+	log := options.logger()
+	delay := 100 * time.Millisecond
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		err := read()
+		if err == nil || !isLockedError(err) {
+			return err
+		}
+		if attempt >= options.LockRetries || (options.LockTimeout > 0 && time.Since(start)+delay > options.LockTimeout) {
+			return fmt.Errorf("%s is locked, gave up after %d retries in %s: %v", MPRFilePath, attempt, time.Since(start).Round(time.Millisecond), err)
+		}
+		log.Warnf("%s is locked, retrying in %s", MPRFilePath, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func isLockedError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "database is locked") || strings.Contains(message, "SQLITE_BUSY")
+}
@@ -0,0 +1,60 @@
+package mpr
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockRetries(t *testing.T) {
+	if err := os.MkdirAll("./../tmp", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	contents, err := os.ReadFile("./../resources/app/App.mpr")
+	if err != nil {
+		t.Fatalf("Failed to read mpr file: %v", err)
+	}
+	MPRFilePath := "./../tmp/Locked.mpr"
+	if err := os.WriteFile(MPRFilePath, contents, 0644); err != nil {
+		t.Fatalf("Failed to write mpr file: %v", err)
+	}
+
+	// an exclusive transaction keeps other connections from reading, like Studio Pro does while saving
+	lock := func(t *testing.T) func() {
+		db, err := sql.Open("sqlite", MPRFilePath)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to connect to database: %v", err)
+		}
+		if _, err := conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+			t.Fatalf("Failed to lock database: %v", err)
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+			conn.Close()
+			db.Close()
+		}
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		unlock := lock(t)
+		defer unlock()
+		_, err := readMPRFile(context.Background(), MPRFilePath, ExportOptions{LockRetries: 2, LockTimeout: time.Second})
+		if err == nil || !strings.Contains(err.Error(), "is locked") {
+			t.Errorf("Expected locked error, got %v", err)
+		}
+	})
+	t.Run("released", func(t *testing.T) {
+		unlock := lock(t)
+		time.AfterFunc(150*time.Millisecond, unlock)
+		if _, err := readMPRFile(context.Background(), MPRFilePath, ExportOptions{LockRetries: 5}); err != nil {
+			t.Errorf("Expected read to succeed once the lock is released, got %v", err)
+		}
+	})
+}
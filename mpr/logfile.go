@@ -0,0 +1,100 @@
+package mpr
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// exportLogFile copies log entries of an export run as ndjson into export.log and passes them on to the next logger.
+// The entries are buffered and written to the sink when the log is closed.
+// Loggers derived with withLogField share the file and add their fields to every entry.
+type exportLogFile struct {
+	next   Logger
+	output *logFileOutput
+	fields logrus.Fields
+}
+
+// logFileOutput is the log shared by an export log and the loggers derived from it
+type logFileOutput struct {
+	lock      sync.Mutex
+	sink      Sink
+	buffer    bytes.Buffer
+	formatter logrus.Formatter
+}
+
+func newExportLogFile(sink Sink, next Logger) *exportLogFile {
+	return &exportLogFile{
+		next: next,
+		output: &logFileOutput{
+			sink:      sink,
+			formatter: &logrus.JSONFormatter{},
+		},
+		fields: logrus.Fields{},
+	}
+}
+
+// withLogField returns a logger that adds the field to every entry written to the export log, like the mpr file
+// or the unit an entry is about. Other loggers are returned as they are.
+func withLogField(logger Logger, key string, value string) Logger {
+	l, ok := logger.(*exportLogFile)
+	if !ok {
+		return logger
+	}
+	fields := make(logrus.Fields, len(l.fields)+1)
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	return &exportLogFile{next: l.next, output: l.output, fields: fields}
+}
+
+// Debugf is not written to the log file, like with the default log level
+func (l *exportLogFile) Debugf(format string, args ...interface{}) {
+	l.next.Debugf(format, args...)
+}
+
+func (l *exportLogFile) Infof(format string, args ...interface{}) {
+	l.write(logrus.InfoLevel, format, args...)
+	l.next.Infof(format, args...)
+}
+
+func (l *exportLogFile) Warnf(format string, args ...interface{}) {
+	l.write(logrus.WarnLevel, format, args...)
+	l.next.Warnf(format, args...)
+}
+
+func (l *exportLogFile) Errorf(format string, args ...interface{}) {
+	l.write(logrus.ErrorLevel, format, args...)
+	l.next.Errorf(format, args...)
+}
+
+func (l *exportLogFile) write(level logrus.Level, format string, args ...interface{}) {
+	entry := &logrus.Entry{
+		Data:    l.fields,
+		Time:    time.Now(),
+		Level:   level,
+		Message: fmt.Sprintf(format, args...),
+	}
+	line, err := l.output.formatter.Format(entry)
+	if err != nil {
+		return
+	}
+	// documents and mpr files are exported concurrently
+	l.output.lock.Lock()
+	defer l.output.lock.Unlock()
+	l.output.buffer.Write(line)
+}
+
+// Close writes the buffered entries to export.log in the sink
+func (l *exportLogFile) Close() error {
+	l.output.lock.Lock()
+	defer l.output.lock.Unlock()
+	if err := writeSinkFile(l.output.sink, "export.log", l.output.buffer.Bytes()); err != nil {
+		return fmt.Errorf("error writing log file: %v", err)
+	}
+	return nil
+}
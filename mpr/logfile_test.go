@@ -0,0 +1,63 @@
+package mpr
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExportLogFile(t *testing.T) {
+	t.Run("ndjson", func(t *testing.T) {
+		previous := log
+		if err := ExportModel("./../resources/app", "./../tmp/logged", ExportOptions{Mode: "advanced", LogFile: true}); err != nil {
+			t.Errorf("Failed to export model: %v", err)
+		}
+
+		file, err := os.Open("./../tmp/logged/export.log")
+		if err != nil {
+			t.Fatalf("Failed to open log file: %v", err)
+		}
+		defer file.Close()
+
+		lines, scoped := 0, 0
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("Invalid log line %q: %v", scanner.Text(), err)
+			}
+			if entry["time"] == nil || entry["level"] == nil || entry["mpr"] == nil {
+				t.Errorf("Incomplete log entry: %v", entry)
+			}
+			if entry["unit"] != nil || entry["document"] != nil {
+				scoped++
+			}
+			lines++
+		}
+		if lines == 0 {
+			t.Errorf("Expected log entries")
+		}
+		if scoped == 0 {
+			t.Errorf("Expected log entries with the unit or document they are about")
+		}
+		if log != previous {
+			t.Errorf("Expected the package logger to be left unchanged")
+		}
+	})
+
+	t.Run("skipped-units", func(t *testing.T) {
+		options := ExportOptions{Mode: "basic", LogFile: true, MaxUnitBytes: 1000}
+		if err := ExportModel("./../resources/app", "./../tmp/logged-skipped", options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		contents, err := os.ReadFile("./../tmp/logged-skipped/export.log")
+		if err != nil {
+			t.Fatalf("Failed to read log file: %v", err)
+		}
+		if !strings.Contains(string(contents), "more than the maximum of 1000") {
+			t.Errorf("Expected the skipped units to be logged to the log file")
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package mpr
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// exportManifest writes an index of every exported document with its name, type, folder path and output file
+func exportManifest(MPRFilePath string, documents []MxDocument, files map[string]string, output Sink, format string, log Logger) error {
+	entries := make([]map[string]interface{}, 0, len(documents))
+	for _, document := range documents {
+		id, _ := idString(document.Attributes["$ID"])
+		entries = append(entries, map[string]interface{}{
+			"Name": document.Name,
+			"Type": document.Type,
+			"Path": filepath.ToSlash(document.Path),
+			"File": files[id],
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i]["File"].(string) < entries[j]["File"].(string)
+	})
+
+	return writeFile(output, "manifest."+fileExtension(format), map[string]interface{}{
+		"Source":    filepath.Base(MPRFilePath),
+		"Count":     len(entries),
+		"Documents": entries,
+	}, format, log)
+}
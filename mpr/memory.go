@@ -0,0 +1,94 @@
+package mpr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExportModelToMemory parses the mpr file in the input directory and returns its documents and metadata
+// without writing anything to disk. Unless options.Raw is set, the document attributes are cleaned like
+// in a regular export.
+func ExportModelToMemory(inputDirectory string, options ExportOptions) ([]MxDocument, MxMetadata, error) {
+	MPRFilePath, err := findMPRFile(inputDirectory, options.Skip, options.logger())
+	if err != nil {
+		return nil, MxMetadata{}, err
+	}
+
+	file, err := readMPRFile(context.Background(), MPRFilePath, options)
+	if err != nil {
+		return nil, MxMetadata{}, fmt.Errorf("error reading %s: %v", MPRFilePath, err)
+	}
+	metadata := getMxMetadata(file, options)
+	units := file.Units
+	folders, err := getMxFolders(units, file.Version, options)
+	if err != nil {
+		return nil, MxMetadata{}, fmt.Errorf("error getting folders: %v", err)
+	}
+	documents, err := getMxDocuments(units, getMxFolderPaths(folders, options.logger()), file.Version, options)
+	if err != nil {
+		return nil, MxMetadata{}, fmt.Errorf("error getting documents: %v", err)
+	}
+	for i := range documents {
+		documents[i].Attributes = cleanData(documents[i].Attributes, options.Raw, options.StripKeys)
+	}
+	return documents, metadata, nil
+}
+
+// ListModel returns the modules and the untransformed documents of the mpr file at path, which may also be a
+// directory holding a single mpr file. Nothing is written to disk. Only the logger, skip patterns and lock retries
+// of the options are used.
+func ListModel(path string, options ExportOptions) ([]MxModule, []MxDocument, error) {
+	log := options.logger()
+	MPRFilePath, err := findMPRFile(path, options.Skip, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	readOptions := ExportOptions{Logger: log, LockRetries: options.LockRetries, LockTimeout: options.LockTimeout}
+	file, err := readMPRFile(context.Background(), MPRFilePath, readOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %v", MPRFilePath, err)
+	}
+	folders, err := getMxFolders(file.Units, file.Version, ExportOptions{Logger: log})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting folders: %v", err)
+	}
+	documents, err := getMxDocuments(file.Units, getMxFolderPaths(folders, log), file.Version, ExportOptions{Mode: "basic", Logger: log})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting documents: %v", err)
+	}
+	return getMxModules(file.Units, file.Version, nil, log), documents, nil
+}
+
+// findMPRFile returns the single mpr file in the input directory, or the input itself when it is an mpr file.
+// Paths matching the skip patterns are not searched.
+func findMPRFile(inputDirectory string, skip []string, log Logger) (string, error) {
+	files := make([]string, 0)
+	err := filepath.Walk(inputDirectory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return handleWalkError(inputDirectory, path, info, err, log)
+		}
+		if isSkippedPath(inputDirectory, path, skip) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".mpr") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no mpr file found in %s", inputDirectory)
+	}
+	if len(files) > 1 {
+		return "", fmt.Errorf("multiple mpr files found in %s: %s", inputDirectory, strings.Join(files, ", "))
+	}
+	return files[0], nil
+}
@@ -0,0 +1,73 @@
+package mpr
+
+import (
+	"testing"
+)
+
+func TestExportModelToMemory(t *testing.T) {
+	t.Run("app", func(t *testing.T) {
+		documents, metadata, err := ExportModelToMemory("./../resources/app", ExportOptions{Mode: "advanced"})
+		if err != nil {
+			t.Fatalf("Failed to export model to memory: %v", err)
+		}
+		if metadata.ProductVersion == "" || len(metadata.Modules) == 0 {
+			t.Errorf("Expected metadata with modules. Got: %v", metadata)
+		}
+		found := false
+		for _, document := range documents {
+			if document.Name == "MicroflowSimple" {
+				found = true
+				if _, ok := document.Attributes["MainFunction"]; !ok {
+					t.Errorf("Expected transformed microflow")
+				}
+				if _, ok := document.Attributes["$ID"]; ok {
+					t.Errorf("Expected cleaned attributes")
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected MicroflowSimple in documents")
+		}
+	})
+	t.Run("multiple-mpr-files", func(t *testing.T) {
+		if _, _, err := ExportModelToMemory("./../resources", ExportOptions{Mode: "basic"}); err == nil {
+			t.Errorf("Expected error for multiple mpr files")
+		}
+	})
+}
+
+func TestListModel(t *testing.T) {
+	t.Run("app", func(t *testing.T) {
+		modules, documents, err := ListModel("./../resources/app/App.mpr", ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to list model: %v", err)
+		}
+		found := false
+		for _, module := range modules {
+			if module.Name == "MyFirstModule" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected MyFirstModule in modules. Got: %v", modules)
+		}
+		for _, document := range documents {
+			if document.Name == "MicroflowSimple" {
+				if _, ok := document.Attributes["MainFunction"]; ok {
+					t.Errorf("Expected untransformed documents")
+				}
+				return
+			}
+		}
+		t.Errorf("Expected MicroflowSimple in documents")
+	})
+	t.Run("logger", func(t *testing.T) {
+		logger := &recordingLogger{}
+		if _, _, err := ListModel("./../resources/app/App.mpr", ExportOptions{Logger: logger}); err != nil {
+			t.Fatalf("Failed to list model: %v", err)
+		}
+		if len(logger.infos) == 0 {
+			t.Errorf("Expected the log entries to go to the logger of the options")
+		}
+	})
+}
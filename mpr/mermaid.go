@@ -0,0 +1,110 @@
+package mpr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// renderMicroflowMermaid renders the main flow of a transformed microflow as a Mermaid flowchart.
+// A microflow without activities results in a flowchart without nodes.
+func renderMicroflowMermaid(mf MxDocument) string {
+	renderer := mermaidRenderer{nodes: make(map[string]string)}
+	renderer.lines = append(renderer.lines, "flowchart TD")
+	renderer.renderFlow(getMainFlowNodes(mf.Attributes["MainFunction"]), "")
+	return strings.Join(renderer.lines, "\n") + "\n"
+}
+
+type mermaidRenderer struct {
+	lines []string
+	// nodes maps microflow object IDs to Mermaid node names
+	nodes map[string]string
+}
+
+// renderFlow renders a sequence of nodes starting from the given node, which is empty for the start of the flow
+func (r *mermaidRenderer) renderFlow(flow []map[string]interface{}, previous string) {
+	label := ""
+	for _, node := range flow {
+		attributes, _ := getObject(node["Attributes"])
+		if attributes["$Type"] == "Microflows$SequenceFlow" {
+			label = getMxFlowCaseValue(attributes)
+			continue
+		}
+		id, _ := node["ID"].(string)
+		name, seen := r.nodes[id]
+		if !seen {
+			name = fmt.Sprintf("n%d", len(r.nodes)+1)
+			r.nodes[id] = name
+			r.lines = append(r.lines, "    "+name+getMermaidShape(node, attributes))
+		}
+		if previous != "" {
+			if label != "" {
+				r.lines = append(r.lines, fmt.Sprintf("    %s -->|\"%s\"| %s", previous, escapeMermaid(label), name))
+			} else {
+				r.lines = append(r.lines, fmt.Sprintf("    %s --> %s", previous, name))
+			}
+		}
+		label = ""
+		previous = name
+		if seen {
+			// merges reached from several branches are only expanded once
+			return
+		}
+		if splits, ok := node["Splits"].([]interface{}); ok {
+			for _, split := range splits {
+				r.renderFlow(getMainFlowNodes(split), name)
+			}
+			return
+		}
+	}
+}
+
+func getMainFlowNodes(value interface{}) []map[string]interface{} {
+	if nodes, ok := value.([]map[string]interface{}); ok {
+		return nodes
+	}
+	return getObjectList(value)
+}
+
+// getMermaidShape returns the shape and label of a node based on its microflow object type
+func getMermaidShape(node map[string]interface{}, attributes map[string]interface{}) string {
+	objectType, _ := attributes["$Type"].(string)
+	switch objectType {
+	case "Microflows$StartEvent":
+		return `(("start"))`
+	case "Microflows$EndEvent":
+		return `(("end"))`
+	case "Microflows$ExclusiveMerge":
+		return `{{"merge"}}`
+	case "Microflows$ExclusiveSplit", "Microflows$InheritanceSplit":
+		caption, _ := attributes["Caption"].(string)
+		if condition, ok := getObject(attributes["SplitCondition"]); ok {
+			if expression, ok := condition["Expression"].(string); ok && expression != "" {
+				caption = expression
+			}
+		}
+		return fmt.Sprintf(`{"%s"}`, escapeMermaid(caption))
+	case "Microflows$LoopedActivity":
+		caption := "loop"
+		if loop, ok := getObject(node["Loop"]); ok {
+			if variable, ok := loop["ListVariableName"].(string); ok && variable != "" {
+				caption = "loop over $" + variable
+			}
+		}
+		return fmt.Sprintf(`[["%s"]]`, escapeMermaid(caption))
+	}
+	caption, _ := attributes["Caption"].(string)
+	if action, ok := getObject(attributes["Action"]); ok {
+		if autoGenerated, _ := attributes["AutoGenerateCaption"].(bool); autoGenerated || caption == "" {
+			actionType, _ := action["$Type"].(string)
+			caption = actionType[strings.Index(actionType, "$")+1:]
+		}
+	}
+	if caption == "" {
+		caption = objectType[strings.Index(objectType, "$")+1:]
+	}
+	return fmt.Sprintf(`["%s"]`, escapeMermaid(caption))
+}
+
+func escapeMermaid(text string) string {
+	return strings.NewReplacer(`"`, "#quot;", "|", "#124;", "\n", " ").Replace(text)
+}
@@ -0,0 +1,55 @@
+package mpr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMicroflowMermaid(t *testing.T) {
+	t.Run("split", func(t *testing.T) {
+		mf := MxDocument{Name: "Flow", Attributes: map[string]interface{}{
+			"MainFunction": []map[string]interface{}{
+				{"ID": "start", "Attributes": map[string]interface{}{"$Type": "Microflows$StartEvent"}},
+				{"ID": "f1", "Attributes": map[string]interface{}{"$Type": "Microflows$SequenceFlow"}},
+				{"ID": "split", "Attributes": map[string]interface{}{
+					"$Type":          "Microflows$ExclusiveSplit",
+					"SplitCondition": map[string]interface{}{"Expression": "$Order/Paid"},
+				}, "Splits": []interface{}{
+					[]map[string]interface{}{
+						{"ID": "f2", "Attributes": map[string]interface{}{"$Type": "Microflows$SequenceFlow", "NewCaseValue": map[string]interface{}{"Value": "true"}}},
+						{"ID": "commit", "Attributes": map[string]interface{}{
+							"$Type":               "Microflows$ActionActivity",
+							"AutoGenerateCaption": false,
+							"Caption":             "Commit \"order\"",
+							"Action":              map[string]interface{}{"$Type": "Microflows$CommitAction"},
+						}},
+						{"ID": "f3", "Attributes": map[string]interface{}{"$Type": "Microflows$SequenceFlow"}},
+						{"ID": "end", "Attributes": map[string]interface{}{"$Type": "Microflows$EndEvent"}},
+					},
+					[]map[string]interface{}{
+						{"ID": "f4", "Attributes": map[string]interface{}{"$Type": "Microflows$SequenceFlow", "NewCaseValue": map[string]interface{}{"Value": "false"}}},
+						{"ID": "end2", "Attributes": map[string]interface{}{"$Type": "Microflows$EndEvent"}},
+					},
+				}},
+			},
+		}}
+		diagram := renderMicroflowMermaid(mf)
+		for _, expected := range []string{
+			"flowchart TD",
+			`n2{"$Order/Paid"}`,
+			`n3["Commit #quot;order#quot;"]`,
+			`n2 -->|"true"| n3`,
+			`n2 -->|"false"| n5`,
+			"n3 --> n4",
+		} {
+			if !strings.Contains(diagram, expected) {
+				t.Errorf("Expected %s in diagram:\n%s", expected, diagram)
+			}
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		if diagram := renderMicroflowMermaid(MxDocument{Attributes: map[string]interface{}{}}); diagram != "flowchart TD\n" {
+			t.Errorf("Expected empty flowchart, got %s", diagram)
+		}
+	})
+}
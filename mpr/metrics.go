@@ -0,0 +1,84 @@
+package mpr
+
+import (
+	"sort"
+)
+
+// MxMicroflowMetrics are complexity measures of a microflow
+type MxMicroflowMetrics struct {
+	Activities int `yaml:"Activities"`
+	Decisions  int `yaml:"Decisions"`
+	Loops      int `yaml:"Loops"`
+	// Complexity is the cyclomatic complexity estimated as 1 plus the branches of every decision beyond the first,
+	// plus 1 for every loop
+	Complexity int `yaml:"Complexity"`
+}
+
+// getMxMicroflowMetrics counts the activities, decisions and loops of a microflow, including those inside loops.
+// It reads the contents before transformMicroflow replaces the object collection.
+func getMxMicroflowMetrics(attributes map[string]interface{}) MxMicroflowMetrics {
+	branches := make(map[string]int)
+	for _, flow := range getObjectList(attributes["Flows"]) {
+		if origin, ok := idString(flow["OriginPointer"]); ok {
+			branches[origin]++
+		}
+	}
+	metrics := MxMicroflowMetrics{Complexity: 1}
+	countMicroflowObjects(attributes, branches, &metrics)
+	return metrics
+}
+
+func countMicroflowObjects(container map[string]interface{}, branches map[string]int, metrics *MxMicroflowMetrics) {
+	collection, _ := getObject(container["ObjectCollection"])
+	for _, object := range getObjectList(collection["Objects"]) {
+		switch object["$Type"] {
+		case "Microflows$ActionActivity":
+			metrics.Activities++
+		case "Microflows$ExclusiveSplit", "Microflows$InheritanceSplit":
+			metrics.Decisions++
+			id, _ := idString(object["$ID"])
+			if branches[id] > 1 {
+				metrics.Complexity += branches[id] - 1
+			}
+		case "Microflows$LoopedActivity":
+			metrics.Loops++
+			metrics.Complexity++
+			countMicroflowObjects(object, branches, metrics)
+		}
+	}
+}
+
+// exportMicroflowMetrics writes microflow-metrics.yaml with the metrics of every microflow in the selected
+// modules, ordered by qualified name
+func exportMicroflowMetrics(units []MxUnit, folderPaths map[string]string, version MxVersion, moduleFilter []string, output Sink, format string, log Logger) error {
+	documentIndex := buildDocumentIndex(units, folderPaths, version)
+	microflows := make([]map[string]interface{}, 0)
+	for _, unit := range units {
+		if unit.Contents["$Type"] != "Microflows$Microflow" {
+			continue
+		}
+		if len(moduleFilter) > 0 && !matchesModuleFilter(getMxModuleName(folderPaths[unit.ContainerID]), moduleFilter) {
+			continue
+		}
+		name, ok := documentIndex[unit.UnitID]
+		if !ok {
+			continue
+		}
+		metrics := getMxMicroflowMetrics(unit.Contents)
+		microflows = append(microflows, map[string]interface{}{
+			"Microflow":  name,
+			"Activities": metrics.Activities,
+			"Decisions":  metrics.Decisions,
+			"Loops":      metrics.Loops,
+			"Complexity": metrics.Complexity,
+		})
+	}
+	sort.Slice(microflows, func(i, j int) bool {
+		return microflows[i]["Microflow"].(string) < microflows[j]["Microflow"].(string)
+	})
+	log.Infof("Computed metrics of %d microflows", len(microflows))
+
+	return writeFile(output, "microflow-metrics."+fileExtension(format), map[string]interface{}{
+		"Microflows": microflows,
+	}, format, log)
+}
@@ -1,32 +1,203 @@
 package mpr
 
-func transformMicroflow(mf MxDocument) MxDocument {
+import "sort"
+
+func transformMicroflow(mf MxDocument, documentIndex map[string]string, log Logger) MxDocument {
 	// Transform a microflow
 	log.Infof("Transforming microflow %s", mf.Name)
 
 	cleanedData := bsonToMap(mf.Attributes)
-	objsCollection := cleanedData["ObjectCollection"].(map[string]interface{})
-	objs := convertToMxMicroflowObjects(objsCollection["Objects"].([]interface{}))
-	flows := convertToMxMicroflowEdges(cleanedData["Flows"].([]interface{}))
+	objsCollection, _ := cleanedData["ObjectCollection"].(map[string]interface{})
+	rawObjs, _ := objsCollection["Objects"].([]interface{})
+	rawFlows, _ := cleanedData["Flows"].([]interface{})
+	objs := convertToMxMicroflowObjects(rawObjs, log)
+	flows := convertToMxMicroflowEdges(rawFlows, log)
 
-	startEvent := getMxMicroflowObjectByType(objs, "Microflows$StartEvent")
+	startEvent, ok := getMxMicroflowObjectByType(objs, "Microflows$StartEvent")
+	if !ok {
+		log.Warnf("Microflow %s has no start event; falling back to activity list", mf.Name)
+		return fallbackMicroflow(mf, objs)
+	}
+	if _, ok := getMxMicroflowObjectByType(objs, "Microflows$EndEvent"); !ok {
+		log.Warnf("Microflow %s has no end event; falling back to activity list", mf.Name)
+		return fallbackMicroflow(mf, objs)
+	}
 
 	root := MxMicroflowNode{
 		Type:       startEvent.Type,
 		ID:         startEvent.ID,
 		Attributes: startEvent.Attributes,
 	}
-	buildDAG(&root, nil, flows, objs)
+	buildDAG(&root, nil, flows, objs, log)
 	mainFlow := make([]map[string]interface{}, 0)
 	labels := make(map[string]interface{}, 0)
-	extractMainFlow(&mainFlow, &root, &labels)
+	extractMainFlow(&mainFlow, &root, &labels, log)
+	producers := make(map[string]variableProducer)
+	getVariableProducers(objs, producers, log)
+	transformLoops(mainFlow, flows, log)
+	transformMemberAssignments(mainFlow)
+	transformVariableReferences(mainFlow, producers)
+	resolveMicroflowCalls(mainFlow, documentIndex)
 	mf.Attributes["MainFunction"] = mainFlow
 	// remove ObjectCollection
 	delete(mf.Attributes, "ObjectCollection")
 	return mf
 }
 
-func extractMainFlow(mainFlow *[]map[string]interface{}, current *MxMicroflowNode, labels *map[string]interface{}) {
+// fallbackMicroflow lists the microflow objects as-is for microflows that cannot be traversed
+func fallbackMicroflow(mf MxDocument, objs []MxMicroflowObject) MxDocument {
+	// without a start or end event there is no flow to follow, so order by ID to keep the output stable
+	sorted := append([]MxMicroflowObject(nil), objs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	mainFlow := make([]map[string]interface{}, 0)
+	for _, obj := range sorted {
+		mainFlow = append(mainFlow, convertMxMicroflowNodeToMap(&MxMicroflowNode{
+			Type:       obj.Type,
+			ID:         obj.ID,
+			Attributes: obj.Attributes,
+		}))
+	}
+	mf.Attributes["MainFunction"] = mainFlow
+	delete(mf.Attributes, "ObjectCollection")
+	return mf
+}
+
+// transformLoops replaces the object collection of every loop by its loop details and its body in flow order
+func transformLoops(nodes []map[string]interface{}, flows []MxMicroflowEdge, log Logger) {
+	for _, node := range nodes {
+		if splits, ok := node["Splits"].([]interface{}); ok {
+			for _, split := range splits {
+				if subflow, ok := split.([]map[string]interface{}); ok {
+					transformLoops(subflow, flows, log)
+				}
+			}
+		}
+		if node["Type"] != "Microflows$LoopedActivity" {
+			continue
+		}
+		attributes, ok := node["Attributes"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		loop := make(map[string]interface{})
+		source, _ := attributes["LoopSource"].(map[string]interface{})
+		switch source["$Type"] {
+		case "Microflows$IterableList":
+			loop["VariableName"] = source["VariableName"]
+			loop["ListVariableName"] = source["ListVariableName"]
+		case "Microflows$WhileLoopCondition":
+			loop["WhileExpression"] = source["WhileExpression"]
+		}
+
+		collection, _ := attributes["ObjectCollection"].(map[string]interface{})
+		rawObjs, _ := collection["Objects"].([]interface{})
+		objs := convertToMxMicroflowObjects(rawObjs, log)
+		body := make([]map[string]interface{}, 0)
+		if start, ok := getMxMicroflowLoopStart(objs, flows); ok {
+			root := MxMicroflowNode{
+				Type:       start.Type,
+				ID:         start.ID,
+				Attributes: start.Attributes,
+			}
+			buildDAG(&root, nil, flows, objs, log)
+			labels := make(map[string]interface{}, 0)
+			extractMainFlow(&body, &root, &labels, log)
+			transformLoops(body, flows, log)
+		}
+		loop["Body"] = body
+		node["Loop"] = loop
+		delete(attributes, "ObjectCollection")
+	}
+}
+
+// getMxMicroflowLoopStart returns the first activity of a loop body, which is the one without incoming flows
+func getMxMicroflowLoopStart(objs []MxMicroflowObject, flows []MxMicroflowEdge) (MxMicroflowObject, bool) {
+	destinations := make(map[string]bool)
+	for _, flow := range flows {
+		destinations[flow.Destination] = true
+	}
+	var start MxMicroflowObject
+	found := false
+	for _, obj := range objs {
+		if obj.Type == "Microflows$Annotation" || destinations[obj.ID] {
+			continue
+		}
+		// several candidates means the loop body is not fully connected; prefer the lowest ID
+		if !found || obj.ID < start.ID {
+			start = obj
+			found = true
+		}
+	}
+	return start, found
+}
+
+// transformMemberAssignments lists the member to expression assignments of every change and create object activity
+func transformMemberAssignments(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if attributes, ok := v["Attributes"].(map[string]interface{}); ok && v["Type"] == "Microflows$ActionActivity" {
+			action, _ := attributes["Action"].(map[string]interface{})
+			if action["$Type"] == "Microflows$ChangeAction" || action["$Type"] == "Microflows$CreateChangeAction" {
+				assignments := make([]map[string]interface{}, 0)
+				for _, item := range getObjectList(action["Items"]) {
+					member, _ := item["Attribute"].(string)
+					if member == "" {
+						member, _ = item["Association"].(string)
+					}
+					assignments = append(assignments, map[string]interface{}{
+						"Member":    member,
+						"Operation": item["Type"],
+						"Value":     item["Value"],
+					})
+				}
+				v["Assignments"] = assignments
+			}
+		}
+		for key, item := range v {
+			if key != "Attributes" {
+				transformMemberAssignments(item)
+			}
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			transformMemberAssignments(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			transformMemberAssignments(item)
+		}
+	}
+}
+
+// resolveMicroflowCalls replaces microflow call targets that are stored as IDs by the qualified name of the callee
+func resolveMicroflowCalls(value interface{}, documentIndex map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v["$Type"] == "Microflows$MicroflowCall" {
+			if target, ok := v["Microflow"].(string); ok {
+				if qualifiedName, ok := documentIndex[target]; ok {
+					v["Microflow"] = qualifiedName
+				}
+			}
+		}
+		for _, item := range v {
+			resolveMicroflowCalls(item, documentIndex)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			resolveMicroflowCalls(item, documentIndex)
+		}
+	case []interface{}:
+		for _, item := range v {
+			resolveMicroflowCalls(item, documentIndex)
+		}
+	}
+}
+
+func extractMainFlow(mainFlow *[]map[string]interface{}, current *MxMicroflowNode, labels *map[string]interface{}, log Logger) {
 	c := convertMxMicroflowNodeToMap(current)
 	*mainFlow = append(*mainFlow, c)
 	if current.Type == "Microflows$EndEvent" {
@@ -36,7 +207,7 @@ func extractMainFlow(mainFlow *[]map[string]interface{}, current *MxMicroflowNod
 	if current.Type == "Microflows$ExclusiveMerge" {
 		id, ok := c["ID"].(string)
 		if !ok {
-			log.Warn("ID is not a string or is nil")
+			log.Warnf("ID is not a string or is nil")
 			return
 		}
 		// check if label already exists
@@ -59,20 +230,20 @@ func extractMainFlow(mainFlow *[]map[string]interface{}, current *MxMicroflowNod
 	} else if len(*children) == 1 {
 		// sequence
 		child := (*children)[0]
-		extractMainFlow(mainFlow, &child, labels)
+		extractMainFlow(mainFlow, &child, labels, log)
 	} else {
 		// split
 		splits := make([]interface{}, 0)
 		for _, child := range *children {
 			subflow := make([]map[string]interface{}, 0)
-			extractMainFlow(&subflow, &child, labels)
+			extractMainFlow(&subflow, &child, labels, log)
 			splits = append(splits, subflow)
 		}
 		c["Splits"] = splits
 	}
 }
 
-func buildDAG(current *MxMicroflowNode, parent *MxMicroflowNode, flows []MxMicroflowEdge, objects []MxMicroflowObject) {
+func buildDAG(current *MxMicroflowNode, parent *MxMicroflowNode, flows []MxMicroflowEdge, objects []MxMicroflowObject, log Logger) {
 
 	current.Parent = parent
 	children := make([]MxMicroflowNode, 0)
@@ -91,7 +262,7 @@ func buildDAG(current *MxMicroflowNode, parent *MxMicroflowNode, flows []MxMicro
 					Attributes: edge.Attributes,
 				}
 
-				buildDAG(&edgeNode, current, flows, objects)
+				buildDAG(&edgeNode, current, flows, objects, log)
 				children = append(children, edgeNode)
 			}
 		} else {
@@ -99,13 +270,18 @@ func buildDAG(current *MxMicroflowNode, parent *MxMicroflowNode, flows []MxMicro
 			return
 		}
 	case "Microflows$SequenceFlow":
-		obj := getMxMicroflowObjectByID(objects, current.Attributes["DestinationPointer"].(string))
+		destination, _ := current.Attributes["DestinationPointer"].(string)
+		obj, ok := getMxMicroflowObjectByID(objects, destination)
+		if !ok {
+			log.Warnf("Sequence flow %s points to unknown object %s; not traversing", current.ID, destination)
+			break
+		}
 		objectNode := MxMicroflowNode{
 			Type:       obj.Type,
 			ID:         obj.ID,
 			Attributes: obj.Attributes,
 		}
-		buildDAG(&objectNode, current, flows, objects)
+		buildDAG(&objectNode, current, flows, objects, log)
 		children = append(children, objectNode)
 	default:
 		edges := getMxMicroflowEdgesByOrigin(flows, current.ID)
@@ -117,7 +293,7 @@ func buildDAG(current *MxMicroflowNode, parent *MxMicroflowNode, flows []MxMicro
 				Attributes: edge.Attributes,
 			}
 
-			buildDAG(&edgeNode, current, flows, objects)
+			buildDAG(&edgeNode, current, flows, objects, log)
 			children = append(children, edgeNode)
 		}
 	}
@@ -141,49 +317,107 @@ func getMxMicroflowEdgesByOrigin(edges []MxMicroflowEdge, originId string) []MxM
 			result = append(result, edge)
 		}
 	}
+	sortMxMicroflowEdges(result)
 	return result
 }
 
-func getMxMicroflowObjectByType(objs []MxMicroflowObject, objType string) MxMicroflowObject {
+// sortMxMicroflowEdges orders the outgoing flows of an object by case value and then by ID, so the
+// branches of a split do not depend on the order in which the flows are stored
+func sortMxMicroflowEdges(edges []MxMicroflowEdge) {
+	sort.SliceStable(edges, func(i, j int) bool {
+		caseI, caseJ := getMxFlowCaseValue(edges[i].Attributes), getMxFlowCaseValue(edges[j].Attributes)
+		if caseI != caseJ {
+			return caseI < caseJ
+		}
+		return edges[i].ID < edges[j].ID
+	})
+}
+
+// getMxFlowCaseValue returns the case value of a flow leaving a split, or an empty string for other flows
+func getMxFlowCaseValue(flow map[string]interface{}) string {
+	caseValue, ok := getObject(flow["NewCaseValue"])
+	if !ok {
+		return ""
+	}
+	if value, ok := caseValue["Value"].(string); ok {
+		return value
+	}
+	if expression, ok := caseValue["Expression"].(string); ok {
+		return expression
+	}
+	return ""
+}
+
+func getMxMicroflowObjectByType(objs []MxMicroflowObject, objType string) (MxMicroflowObject, bool) {
 	for _, obj := range objs {
 		if obj.Type == objType {
-			return obj
+			return obj, true
 		}
 	}
-	panic("MPR file probably corrupted")
+	return MxMicroflowObject{}, false
 }
 
-func getMxMicroflowObjectByID(objs []MxMicroflowObject, objID string) MxMicroflowObject {
+func getMxMicroflowObjectByID(objs []MxMicroflowObject, objID string) (MxMicroflowObject, bool) {
 	for _, obj := range objs {
 		if obj.ID == objID {
-			return obj
+			return obj, true
 		}
 	}
-	panic("MPR file probably corrupted")
+	return MxMicroflowObject{}, false
 }
 
-func convertToMxMicroflowObjects(objs []interface{}) []MxMicroflowObject {
-	result := make([]MxMicroflowObject, len(objs))
+// convertToMxMicroflowObjects converts the objects of a microflow. Objects without a $Type or $ID, as left behind
+// by a broken merge, are skipped with a warning.
+func convertToMxMicroflowObjects(objs []interface{}, log Logger) []MxMicroflowObject {
+	result := make([]MxMicroflowObject, 0, len(objs))
 	for _, o := range objs {
-		castedObject := o.(map[string]interface{})
+		castedObject, ok := getObject(o)
+		if !ok {
+			log.Warnf("Skipping microflow object of type %T", o)
+			continue
+		}
+		objectType, ok := castedObject["$Type"].(string)
+		if !ok {
+			log.Warnf("Skipping microflow object without $Type")
+			continue
+		}
+		id, ok := idString(castedObject["$ID"])
+		if !ok {
+			log.Warnf("Skipping microflow object %s without $ID", objectType)
+			continue
+		}
 		result = append(result, MxMicroflowObject{
-			Type:       castedObject["$Type"].(string),
-			ID:         castedObject["$ID"].(string),
+			Type:       objectType,
+			ID:         id,
 			Attributes: castedObject,
 		})
 	}
 	return result
 }
 
-func convertToMxMicroflowEdges(flows []interface{}) []MxMicroflowEdge {
-	result := make([]MxMicroflowEdge, len(flows))
+// convertToMxMicroflowEdges converts the flows of a microflow. Flows missing their type, ID, origin or
+// destination are skipped with a warning.
+func convertToMxMicroflowEdges(flows []interface{}, log Logger) []MxMicroflowEdge {
+	result := make([]MxMicroflowEdge, 0, len(flows))
 	for _, f := range flows {
-		castedFlow := f.(map[string]interface{})
+		castedFlow, ok := getObject(f)
+		if !ok {
+			log.Warnf("Skipping microflow flow of type %T", f)
+			continue
+		}
+		flowType, _ := castedFlow["$Type"].(string)
+		id, idOK := idString(castedFlow["$ID"])
+		origin, originOK := idString(castedFlow["OriginPointer"])
+		destination, destinationOK := idString(castedFlow["DestinationPointer"])
+		if flowType == "" || !idOK || !originOK || !destinationOK {
+			log.Warnf("Skipping malformed microflow flow %s %s", flowType, id)
+			continue
+		}
 		result = append(result, MxMicroflowEdge{
-			Type:        castedFlow["$Type"].(string),
-			ID:          castedFlow["$ID"].(string),
-			Origin:      castedFlow["OriginPointer"].(string),
-			Destination: castedFlow["DestinationPointer"].(string),
+			Type:        flowType,
+			ID:          id,
+			Origin:      origin,
+			Destination: destination,
 			Attributes:  castedFlow,
 		})
 	}
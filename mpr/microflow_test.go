@@ -3,6 +3,7 @@ package mpr
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,7 +14,7 @@ import (
 
 func TestMPRMicroflow(t *testing.T) {
 	t.Run("microflow-simple", func(t *testing.T) {
-		if err := exportUnits("./../resources/app/App.mpr", "./../tmp", true, "advanced"); err != nil {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Raw: true, Mode: "advanced"}); err != nil {
 			t.Errorf("Failed to export units from MPR file")
 		}
 
@@ -38,7 +39,7 @@ func TestMPRMicroflow(t *testing.T) {
 		}
 	})
 	t.Run("microflow-with-split", func(t *testing.T) {
-		if err := exportUnits("./../resources/app/App.mpr", "./../tmp", true, "advanced"); err != nil {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Raw: true, Mode: "advanced"}); err != nil {
 			t.Errorf("Failed to export units from MPR file")
 		}
 
@@ -63,7 +64,7 @@ func TestMPRMicroflow(t *testing.T) {
 		}
 	})
 	t.Run("microflow-split-then-merge", func(t *testing.T) {
-		if err := exportUnits("./../resources/app/App.mpr", "./../tmp", true, "advanced"); err != nil {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Raw: true, Mode: "advanced"}); err != nil {
 			t.Errorf("Failed to export units from MPR file")
 		}
 
@@ -104,3 +105,344 @@ func TestMPRMicroflow(t *testing.T) {
 		}
 	})
 }
+
+func TestMPRMicroflowMalformed(t *testing.T) {
+	t.Run("microflow-without-start-event", func(t *testing.T) {
+		mf := MxDocument{
+			Name: "MicroflowBroken",
+			Type: "Microflows$Microflow",
+			Attributes: bson.M{
+				"$Type": "Microflows$Microflow",
+				"Name":  "MicroflowBroken",
+				"ObjectCollection": bson.M{
+					"$Type": "Microflows$MicroflowObjectCollection",
+					"Objects": bson.A{
+						int32(3),
+						bson.M{"$Type": "Microflows$ActionActivity", "$ID": "activity"},
+						bson.M{"$Type": "Microflows$EndEvent", "$ID": "end"},
+					},
+				},
+				"Flows": bson.A{int32(3)},
+			},
+		}
+
+		result := transformMicroflow(mf, nil, log)
+
+		sequence := result.Attributes["MainFunction"].([]map[string]interface{})
+		if len(sequence) != 2 {
+			t.Errorf("Unexpected instructions length. Got: %d", len(sequence))
+		}
+		if _, ok := result.Attributes["ObjectCollection"]; ok {
+			t.Errorf("ObjectCollection should be removed")
+		}
+	})
+}
+
+func TestMPRMicroflowMissingIDs(t *testing.T) {
+	mf := MxDocument{
+		Name: "MicroflowMerged",
+		Type: "Microflows$Microflow",
+		Attributes: bson.M{
+			"$Type": "Microflows$Microflow",
+			"Name":  "MicroflowMerged",
+			"ObjectCollection": bson.M{
+				"$Type": "Microflows$MicroflowObjectCollection",
+				"Objects": bson.A{
+					bson.M{"$Type": "Microflows$StartEvent", "$ID": "start"},
+					bson.M{"$Type": "Microflows$ActionActivity"},
+					bson.M{"$ID": "untyped"},
+					bson.M{"$Type": "Microflows$EndEvent", "$ID": "end"},
+				},
+			},
+			"Flows": bson.A{
+				bson.M{"$Type": "Microflows$SequenceFlow", "$ID": "orphan", "DestinationPointer": "end"},
+				bson.M{"$Type": "Microflows$SequenceFlow", "$ID": "flow", "OriginPointer": "start", "DestinationPointer": "end"},
+			},
+		},
+	}
+
+	result := transformMicroflow(mf, nil, log)
+
+	sequence := result.Attributes["MainFunction"].([]map[string]interface{})
+	if len(sequence) != 3 || sequence[0]["ID"] != "start" || sequence[1]["ID"] != "flow" || sequence[2]["ID"] != "end" {
+		t.Errorf("Expected the malformed object and flow to be skipped. Got: %v", sequence)
+	}
+}
+
+func TestMPRMicroflowOrdering(t *testing.T) {
+	t.Run("split-branches-by-case-value", func(t *testing.T) {
+		flows := []MxMicroflowEdge{
+			{ID: "b", Origin: "split", Destination: "yes", Attributes: map[string]interface{}{
+				"NewCaseValue": map[string]interface{}{"Value": "true"},
+			}},
+			{ID: "a", Origin: "split", Destination: "no", Attributes: map[string]interface{}{
+				"NewCaseValue": map[string]interface{}{"Value": "false"},
+			}},
+			{ID: "c", Origin: "other", Destination: "split"},
+		}
+
+		edges := getMxMicroflowEdgesByOrigin(flows, "split")
+		if len(edges) != 2 || edges[0].Destination != "no" || edges[1].Destination != "yes" {
+			t.Errorf("Unexpected branch order. Got: %v", edges)
+		}
+	})
+
+	t.Run("fallback-by-id", func(t *testing.T) {
+		mf := MxDocument{
+			Name: "MicroflowBroken",
+			Type: "Microflows$Microflow",
+			Attributes: bson.M{
+				"$Type": "Microflows$Microflow",
+				"ObjectCollection": bson.M{
+					"Objects": bson.A{
+						bson.M{"$Type": "Microflows$EndEvent", "$ID": "c"},
+						bson.M{"$Type": "Microflows$ActionActivity", "$ID": "a"},
+						bson.M{"$Type": "Microflows$ActionActivity", "$ID": "b"},
+					},
+				},
+			},
+		}
+
+		sequence := transformMicroflow(mf, nil, log).Attributes["MainFunction"].([]map[string]interface{})
+		if len(sequence) != 3 || sequence[0]["ID"] != "a" || sequence[1]["ID"] != "b" || sequence[2]["ID"] != "c" {
+			t.Errorf("Unexpected activity order. Got: %v", sequence)
+		}
+	})
+}
+
+func TestMPRMicroflowCallResolution(t *testing.T) {
+	t.Run("resolve-call-by-id", func(t *testing.T) {
+		call := map[string]interface{}{
+			"$Type":     "Microflows$MicroflowCall",
+			"Microflow": "CJwh54tYwk+hc1+bCPwjDg==",
+		}
+		mainFlow := []map[string]interface{}{
+			{"Attributes": map[string]interface{}{
+				"$Type":  "Microflows$ActionActivity",
+				"Action": map[string]interface{}{"MicroflowCall": call},
+			}},
+		}
+
+		resolveMicroflowCalls(mainFlow, map[string]string{"CJwh54tYwk+hc1+bCPwjDg==": "MyFirstModule.Callee"})
+
+		if call["Microflow"] != "MyFirstModule.Callee" {
+			t.Errorf("Unexpected callee. Got: %s", call["Microflow"])
+		}
+	})
+}
+
+func TestMPRMicroflowLoop(t *testing.T) {
+	t.Run("microflow-for-loop", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Raw: false, Mode: "advanced"}); err != nil {
+			t.Errorf("Failed to export units from MPR file")
+		}
+
+		mfFile, err := os.ReadFile("./../tmp/MyFirstModule/Folder/MicroflowForLoop.Microflows$Microflow.yaml")
+		if err != nil {
+			t.Errorf("Failed to read file: %v", err)
+		}
+		var mfObj map[string]interface{}
+		if err := yaml.Unmarshal(mfFile, &mfObj); err != nil {
+			t.Errorf("Failed to unmarshal microflow file")
+		}
+
+		var loop map[interface{}]interface{}
+		for _, item := range mfObj["MainFunction"].([]interface{}) {
+			if l, ok := item.(map[interface{}]interface{})["Loop"]; ok {
+				loop = l.(map[interface{}]interface{})
+			}
+		}
+		if loop == nil {
+			t.Fatalf("Expected loop details in main function")
+		}
+		if loop["VariableName"] != "IteratorBike" || loop["ListVariableName"] != "BikeList" {
+			t.Errorf("Unexpected loop variables. Got: %v %v", loop["VariableName"], loop["ListVariableName"])
+		}
+		if body, ok := loop["Body"].([]interface{}); !ok || len(body) != 1 {
+			t.Errorf("Unexpected loop body. Got: %v", loop["Body"])
+		}
+	})
+}
+
+func TestMPRMicroflowMemberAssignments(t *testing.T) {
+	t.Run("change-in-loop", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Raw: false, Mode: "advanced"}); err != nil {
+			t.Errorf("Failed to export units from MPR file")
+		}
+
+		mfFile, err := os.ReadFile("./../tmp/MyFirstModule/Folder/MicroflowForLoop.Microflows$Microflow.yaml")
+		if err != nil {
+			t.Errorf("Failed to read file: %v", err)
+		}
+		var mfObj map[string]interface{}
+		if err := yaml.Unmarshal(mfFile, &mfObj); err != nil {
+			t.Errorf("Failed to unmarshal microflow file")
+		}
+
+		var assignment map[interface{}]interface{}
+		for _, item := range mfObj["MainFunction"].([]interface{}) {
+			loop, ok := item.(map[interface{}]interface{})["Loop"].(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			activity := loop["Body"].([]interface{})[0].(map[interface{}]interface{})
+			assignments, _ := activity["Assignments"].([]interface{})
+			if len(assignments) == 1 {
+				assignment = assignments[0].(map[interface{}]interface{})
+			}
+		}
+		if assignment == nil {
+			t.Fatalf("Expected one member assignment in loop body")
+		}
+		if assignment["Member"] != "MyFirstModule.Bike.Name" || assignment["Value"] != "'abc'" {
+			t.Errorf("Unexpected assignment. Got: %v", assignment)
+		}
+	})
+}
+
+func TestMPRMicroflowVariableReferences(t *testing.T) {
+	t.Run("parameter-reference", func(t *testing.T) {
+		objs := []MxMicroflowObject{
+			{Type: "Microflows$MicroflowParameter", ID: "param", Attributes: map[string]interface{}{"Name": "Bike"}},
+		}
+		producers := make(map[string]variableProducer)
+		getVariableProducers(objs, producers, log)
+
+		mainFlow := []map[string]interface{}{
+			{
+				"Type": "Microflows$ExclusiveSplit",
+				"Attributes": map[string]interface{}{
+					"$Type":          "Microflows$ExclusiveSplit",
+					"SplitCondition": map[string]interface{}{"$Type": "Microflows$ExpressionSplitCondition", "Expression": "$Bike/Name != empty and $currentUser != empty"},
+				},
+			},
+		}
+		transformVariableReferences(mainFlow, producers)
+
+		references, ok := mainFlow[0]["VariableReferences"].([]map[string]interface{})
+		if !ok || len(references) != 2 {
+			t.Fatalf("Expected 2 variable references. Got: %v", mainFlow[0]["VariableReferences"])
+		}
+		if references[0]["Variable"] != "Bike" || references[0]["ProducedBy"] != "param" {
+			t.Errorf("Unexpected reference. Got: %v", references[0])
+		}
+		if _, ok := references[1]["ProducedBy"]; ok || references[1]["Variable"] != "currentUser" {
+			t.Errorf("Expected unresolved system variable. Got: %v", references[1])
+		}
+	})
+	t.Run("expressions-only", func(t *testing.T) {
+		mainFlow := []map[string]interface{}{
+			{
+				"Type": "Microflows$ActionActivity",
+				"Attributes": map[string]interface{}{
+					"$Type":         "Microflows$ActionActivity",
+					"Documentation": "Converts $Amount to $USD",
+					"Caption":       "Price in $USD",
+					"Action": map[string]interface{}{
+						"$Type": "Microflows$ChangeVariableAction",
+						"Value": "'costs $USD' + toString($Amount) + 'it''s $EUR'",
+						"ParameterMappings": []interface{}{
+							map[string]interface{}{"Argument": "$Rate"},
+						},
+					},
+				},
+			},
+		}
+		transformVariableReferences(mainFlow, map[string]variableProducer{})
+
+		references, _ := mainFlow[0]["VariableReferences"].([]map[string]interface{})
+		variables := make([]string, 0, len(references))
+		for _, reference := range references {
+			variables = append(variables, reference["Variable"].(string))
+		}
+		if strings.Join(variables, ",") != "Amount,Rate" {
+			t.Errorf("Expected only the variables of expressions. Got: %v", variables)
+		}
+	})
+}
+
+func TestMPRMicroflowMetrics(t *testing.T) {
+	t.Run("nested-loop", func(t *testing.T) {
+		attributes := map[string]interface{}{
+			"ObjectCollection": map[string]interface{}{"Objects": []interface{}{
+				map[string]interface{}{"$Type": "Microflows$StartEvent", "$ID": "start"},
+				map[string]interface{}{"$Type": "Microflows$ExclusiveSplit", "$ID": "split"},
+				map[string]interface{}{"$Type": "Microflows$LoopedActivity", "$ID": "loop", "ObjectCollection": map[string]interface{}{
+					"Objects": []interface{}{
+						map[string]interface{}{"$Type": "Microflows$ActionActivity", "$ID": "inner"},
+						map[string]interface{}{"$Type": "Microflows$InheritanceSplit", "$ID": "inherit"},
+					},
+				}},
+				map[string]interface{}{"$Type": "Microflows$ActionActivity", "$ID": "outer"},
+			}},
+			"Flows": []interface{}{
+				map[string]interface{}{"OriginPointer": "start", "DestinationPointer": "split"},
+				map[string]interface{}{"OriginPointer": "split", "DestinationPointer": "loop"},
+				map[string]interface{}{"OriginPointer": "split", "DestinationPointer": "outer"},
+				map[string]interface{}{"OriginPointer": "inherit", "DestinationPointer": "a"},
+				map[string]interface{}{"OriginPointer": "inherit", "DestinationPointer": "b"},
+				map[string]interface{}{"OriginPointer": "inherit", "DestinationPointer": "c"},
+			},
+		}
+		metrics := getMxMicroflowMetrics(attributes)
+		expected := MxMicroflowMetrics{Activities: 2, Decisions: 2, Loops: 1, Complexity: 1 + 1 + 2 + 1}
+		if metrics != expected {
+			t.Errorf("Unexpected metrics. Expected %+v, got %+v", expected, metrics)
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		outputDirectory := "./../tmp/microflow-metrics"
+		os.RemoveAll(outputDirectory)
+		options := ExportOptions{Mode: "advanced", MicroflowMetrics: true, EmbedMetrics: true, Modules: []string{"MyFirstModule"}}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		contents, err := os.ReadFile(outputDirectory + "/microflow-metrics.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read microflow metrics: %v", err)
+		}
+		var result struct {
+			Microflows []struct {
+				Microflow  string `yaml:"Microflow"`
+				Activities int    `yaml:"Activities"`
+				Decisions  int    `yaml:"Decisions"`
+				Loops      int    `yaml:"Loops"`
+				Complexity int    `yaml:"Complexity"`
+			} `yaml:"Microflows"`
+		}
+		if err := yaml.Unmarshal(contents, &result); err != nil {
+			t.Fatalf("Failed to parse microflow metrics: %v", err)
+		}
+		found := false
+		for _, microflow := range result.Microflows {
+			if microflow.Microflow == "MyFirstModule.MicroflowForLoop" {
+				found = true
+				if microflow.Activities != 3 || microflow.Decisions != 0 || microflow.Loops != 1 || microflow.Complexity != 2 {
+					t.Errorf("Unexpected metrics of MicroflowForLoop: %+v", microflow)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected metrics of MyFirstModule.MicroflowForLoop")
+		}
+
+		contents, err = os.ReadFile(outputDirectory + "/MyFirstModule/Folder/MicroflowSplit.Microflows$Microflow.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read microflow: %v", err)
+		}
+		var microflow map[string]interface{}
+		if err := yaml.Unmarshal(contents, &microflow); err != nil {
+			t.Fatalf("Failed to parse microflow: %v", err)
+		}
+		if _, ok := microflow["Metrics"]; !ok {
+			t.Errorf("Expected Metrics in the microflow")
+		}
+	})
+
+	t.Run("basic-mode", func(t *testing.T) {
+		if err := ExportModel("./../resources/app", "./../tmp/microflow-metrics", ExportOptions{Mode: "basic", MicroflowMetrics: true}); err == nil {
+			t.Errorf("Expected an error in basic mode")
+		}
+	})
+}
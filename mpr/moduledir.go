@@ -0,0 +1,78 @@
+package mpr
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// moduleDirFields are the fields available in module directory templates
+type moduleDirFields struct {
+	Name       string
+	Version    string
+	Source     string
+	Attributes map[string]interface{}
+}
+
+// parseModuleDirTemplate parses a module directory template like {{.Name}}-{{.Version}}. An empty text returns
+// nil, which keeps the module name as directory.
+func parseModuleDirTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("moduledir").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid module directory template %s: %v", text, err)
+	}
+	return tmpl, nil
+}
+
+// getModuleDirectories maps the module names to the directory their files are written to. A module falls back
+// to its name when the template references a missing attribute or produces an empty name.
+func getModuleDirectories(modules []MxModule, text string, log Logger) (map[string]string, error) {
+	tmpl, err := parseModuleDirTemplate(text)
+	if err != nil || tmpl == nil {
+		return nil, err
+	}
+	directories := make(map[string]string, len(modules))
+	modulesByDirectory := make(map[string]string, len(modules))
+	for _, module := range modules {
+		var builder strings.Builder
+		err := tmpl.Execute(&builder, moduleDirFields{
+			Name:       module.Name,
+			Version:    module.Version,
+			Source:     module.Source,
+			Attributes: module.Attributes,
+		})
+		directory := sanitizeFilename(strings.TrimSpace(builder.String()))
+		if err != nil || directory == "" || directory == "." || directory == ".." {
+			log.Debugf("Using the name as directory of module %s: %v", module.Name, err)
+			directory = module.Name
+		}
+		if other, ok := modulesByDirectory[directory]; ok && other != module.Name {
+			return nil, fmt.Errorf("modules %s and %s resolve to the same directory %s", other, module.Name, directory)
+		}
+		modulesByDirectory[directory] = module.Name
+		directories[module.Name] = directory
+	}
+	return directories, nil
+}
+
+// getModuleDirectory returns the directory of a module, which is its name unless a template is used
+func getModuleDirectory(module string, directories map[string]string) string {
+	if directory, ok := directories[module]; ok {
+		return directory
+	}
+	return module
+}
+
+// moduleDocumentPath replaces the module at the start of a document path by the directory of the module
+func moduleDocumentPath(documentPath string, directories map[string]string) string {
+	module := getMxModuleName(documentPath)
+	if module == "" || len(directories) == 0 {
+		return documentPath
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(filepath.Clean(documentPath)), module), "/")
+	return filepath.Join(getModuleDirectory(module, directories), filepath.FromSlash(rest))
+}
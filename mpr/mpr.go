@@ -1,12 +1,20 @@
 package mpr
 
 import (
+	"context"
 	"database/sql"
-	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/ghodss/yaml"
 	"go.mongodb.org/mongo-driver/bson"
@@ -14,104 +22,706 @@ import (
 	_ "github.com/glebarez/go-sqlite"
 )
 
-func ExportModel(inputDirectory string, outputDirectory string, raw bool, mode string) error {
+func ExportModel(inputDirectory string, outputDirectory string, options ExportOptions) error {
+	return ExportModelContext(context.Background(), inputDirectory, outputDirectory, options)
+}
+
+// ExportModelContext is like ExportModel but stops with the context error as soon as the context is cancelled
+func ExportModelContext(ctx context.Context, inputDirectory string, outputDirectory string, options ExportOptions) error {
+	if options.Format != "" && options.Format != "yaml" && options.Format != "json" && options.Format != "xml" && options.Format != "properties" {
+		return fmt.Errorf("invalid format %s", options.Format)
+	}
+	if options.MaxUnitBytes < 0 {
+		return fmt.Errorf("invalid maximum unit size %d", options.MaxUnitBytes)
+	}
+	if options.IDEncoding != "" && options.IDEncoding != "base64" && options.IDEncoding != "base64url" && options.IDEncoding != "hex" {
+		return fmt.Errorf("invalid id encoding %s", options.IDEncoding)
+	}
+	if options.NormalizeIDs && options.IDEncoding != "" && options.IDEncoding != "base64" {
+		return fmt.Errorf("id encoding %s cannot be combined with normalized ids", options.IDEncoding)
+	}
+	if options.LargeStrings != "" && options.LargeStrings != "truncate" && options.LargeStrings != "externalize" {
+		return fmt.Errorf("invalid large strings handling %s", options.LargeStrings)
+	}
+	if options.Layout != "" && options.Layout != "tree" && options.Layout != "flat-module" && options.Layout != "by-type" {
+		return fmt.Errorf("invalid layout %s", options.Layout)
+	}
+	if options.EmitDiagrams && options.Mode != "advanced" {
+		return fmt.Errorf("diagrams can only be emitted in advanced mode")
+	}
+	if (options.MicroflowMetrics || options.EmbedMetrics) && options.Mode != "advanced" {
+		return fmt.Errorf("microflow metrics can only be computed in advanced mode")
+	}
+	if (options.Format == "xml" || options.Format == "properties") && options.ValidateRoundtrip {
+		return fmt.Errorf("round-trip validation is not supported for %s", options.Format)
+	}
+	if _, err := regexp.Compile(options.NameFilter); err != nil {
+		return fmt.Errorf("invalid name filter %s: %v", options.NameFilter, err)
+	}
+	if _, err := compileRedactPatterns(options.Redact); err != nil {
+		return err
+	}
+	if _, _, err := getTimestampFormat(options); err != nil {
+		return err
+	}
+	if len(options.Redact) > 0 && (options.Links || options.GraphML) {
+		// the references are collected from the units, so redacted references would still show up
+		return fmt.Errorf("links and graphml cannot be combined with redaction")
+	}
+	if options.EmitBSON {
+		// the sidecars hold the units as stored in the mpr file, with every value and key. The default strip keys
+		// only remove editor noise, other keys may be stripped to hide them.
+		hidden := len(options.Redact) > 0
+		for _, pattern := range options.StripKeys {
+			hidden = hidden || !Contains(DefaultStripKeys, pattern)
+		}
+		if hidden {
+			return fmt.Errorf("bson sidecars cannot be combined with redaction or stripped keys")
+		}
+	}
+	if _, err := parseFileNameTemplate(options.FileNameTemplate); err != nil {
+		return err
+	}
+	if _, err := parseModuleDirTemplate(options.ModuleDirTemplate); err != nil {
+		return err
+	}
+	for _, pattern := range options.Skip {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid skip pattern %s: %v", pattern, err)
+		}
+	}
+	for _, pattern := range options.StripKeys {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid strip key %s: %v", pattern, err)
+		}
+	}
+	if options.Archive && options.Sink != nil && !options.DryRun {
+		return fmt.Errorf("archives cannot be written to a sink")
+	}
+	if options.Archive && options.Incremental && !options.DryRun {
+		// the archive is written from scratch, the files of skipped mpr files would be missing
+		return fmt.Errorf("incremental exports cannot be written to an archive")
+	}
+	if options.SplitModules {
+		return exportModules(ctx, inputDirectory, outputDirectory, options)
+	}
+	return exportOutput(ctx, inputDirectory, outputDirectory, options)
+}
+
+// exportOutput exports into an archive at the output path, the sink of the options or the output directory
+func exportOutput(ctx context.Context, inputDirectory string, outputDirectory string, options ExportOptions) error {
+	if options.Archive && !options.DryRun {
+		return exportArchive(ctx, inputDirectory, outputDirectory, options)
+	}
+	output := options.Sink
+	if output == nil && strings.HasPrefix(outputDirectory, "s3://") {
+		sink, err := NewS3Sink(outputDirectory)
+		if err != nil {
+			return err
+		}
+		output = sink
+	} else if output == nil {
+		output = NewLocalSink(outputDirectory)
+	}
+	return exportToSink(ctx, inputDirectory, output, options)
+}
+
+// exportToSink exports the model into the sink. Every file is written to the sink directly, nothing is staged.
+func exportToSink(ctx context.Context, inputDirectory string, output Sink, options ExportOptions) error {
+	var checksums *checksumSink
+	if options.Checksums && !options.DryRun {
+		checksums = newChecksumSink(output)
+		output = checksums
+	}
+	// .gitignore is written as it is, so git can still read it from an encrypted export
+	plain := output
+	if options.EncryptKey != "" && !options.DryRun {
+		encrypted, err := newEncryptingSink(output, options.EncryptKey, options.Format)
+		if err != nil {
+			return err
+		}
+		output = encrypted
+	}
+	if err := exportModel(ctx, inputDirectory, output, options); err != nil {
+		return err
+	}
+	if options.GitIgnore && !options.DryRun {
+		if err := writeGitIgnore(plain); err != nil {
+			return err
+		}
+	}
+	if checksums != nil {
+		return checksums.writeChecksums(options.Format, options.Incremental, options.logger())
+	}
+	return nil
+}
+
+// exportModel exports the mpr file or all mpr files in the input directory into the sink
+func exportModel(ctx context.Context, inputDirectory string, output Sink, options ExportOptions) (err error) {
+	if options.LogFile {
+		logFile := newExportLogFile(output, options.logger())
+		defer func() {
+			if closeErr := logFile.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		options.Logger = logFile
+	}
+	log := options.logger()
+
+	input, err := os.Stat(inputDirectory)
+	if err != nil {
+		return fmt.Errorf("error reading input %s: %v", inputDirectory, err)
+	}
+	if input.Mode().IsRegular() && strings.HasSuffix(input.Name(), ".mpr") {
+		options.Logger = withLogField(log, "mpr", inputDirectory)
+		log := options.logger()
+		var state *exportState
+		var key, hash string
+		if options.Incremental {
+			state = readExportState(output, options.Format, log)
+			var unchanged bool
+			key, hash, unchanged, err = state.unchanged(inputDirectory)
+			if err != nil {
+				return err
+			}
+			if unchanged {
+				log.Infof("Skipping unchanged %s", inputDirectory)
+				return nil
+			}
+		}
+		if _, err := exportMPR(ctx, inputDirectory, output, options); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error exporting %s: %v", inputDirectory, err)
+		}
+		if state != nil && !options.DryRun {
+			state.Files[key] = hash
+			return state.write(log)
+		}
+		return nil
+	}
+	if !input.IsDir() {
+		return fmt.Errorf("input %s is neither a directory nor an mpr file", inputDirectory)
+	}
+
+	MPRFiles, err := findMPRFiles(ctx, inputDirectory, options.Skip, log)
+	if err != nil {
+		return err
+	}
+
+	var exportErrors []error
+	metadata := make(map[string]interface{})
+	mergedMetadataFile := "Metadata." + fileExtension(options.Format)
+	merged := len(MPRFiles) > 1 && options.MergeMetadata
+	var state *exportState
+	var previousMetadata map[string]interface{}
+	if options.Incremental {
+		state = readExportState(output, stateFormat(options.Format), log)
+		if merged && stateFormat(options.Format) != options.Format {
+			log.Warnf("The previous merged metadata cannot be read from %s, only exported mpr files are listed", options.Format)
+		} else if merged {
+			// skipped files keep their entry of the previous merged metadata
+			if contents, err := readSinkFile(output, mergedMetadataFile); err == nil {
+				yaml.Unmarshal(contents, &previousMetadata)
+			}
+		}
+	}
+	// merged exports share the output directory, so only files with their own subdirectory are exported concurrently
+	fileWorkers := options.FileWorkers
+	if fileWorkers <= 0 || merged {
+		fileWorkers = 1
+	}
+	if progress := options.Progress; progress != nil && fileWorkers > 1 {
+		// the documents of concurrently exported files are reported one at a time
+		var progressLock sync.Mutex
+		options.Progress = func(done, total int) {
+			progressLock.Lock()
+			defer progressLock.Unlock()
+			progress(done, total)
+		}
+	}
+	fileCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lock sync.Mutex
+	exportFile := func(path string) error {
+		if fileCtx.Err() != nil {
+			return nil
+		}
+		options := options
+		options.Logger = withLogField(log, "mpr", path)
+		log := options.logger()
+		// the mpr files are identified by their path relative to the input directory, without extension
+		source, err := filepath.Rel(inputDirectory, strings.TrimSuffix(path, ".mpr"))
+		if err != nil {
+			return err
+		}
+		fileOutput := output
+		if len(MPRFiles) > 1 && !merged {
+			fileOutput = newSubSink(output, filepath.ToSlash(source))
+		} else if merged {
+			// the metadata of every file is replaced by the merged metadata, so it is not written at all
+			fileOutput = skipFileSink{Sink: output, name: mergedMetadataFile}
+		}
+		var key, hash string
+		if state != nil {
+			var unchanged bool
+			lock.Lock()
+			key, hash, unchanged, err = state.unchanged(path)
+			lock.Unlock()
+			if err != nil {
+				return err
+			}
+			previous, found := previousMetadata[filepath.ToSlash(source)]
+			if unchanged && (found || !merged) {
+				log.Infof("Skipping unchanged %s", path)
+				lock.Lock()
+				metadata[filepath.ToSlash(source)] = previous
+				lock.Unlock()
+				return nil
+			}
+		}
+		stats, err := exportMPR(fileCtx, path, fileOutput, options)
+		if err != nil {
+			if fileCtx.Err() != nil {
+				return nil
+			}
+			if !options.ContinueOnError {
+				return fmt.Errorf("error exporting %s: %v", path, err)
+			}
+			log.Errorf("Error exporting %s: %v", path, err)
+			lock.Lock()
+			exportErrors = append(exportErrors, fmt.Errorf("error exporting %s: %v", path, err))
+			lock.Unlock()
+			return nil
+		}
+		lock.Lock()
+		metadata[filepath.ToSlash(source)] = stats.Metadata
+		if state != nil {
+			state.Files[key] = hash
+		}
+		lock.Unlock()
+		return nil
+	}
+	err = runWorkers(fileWorkers, MPRFiles, func(path string) error {
+		err := exportFile(path)
+		if err != nil {
+			// stop the other exports like a sequential export stops at the first failure
+			cancel()
+		}
+		return err
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	if merged && !options.DryRun {
+		// the metadata of all mpr files, keyed by their path relative to the input directory
+		if err := writeFile(output, mergedMetadataFile, metadata, options.Format, log); err != nil {
+			exportErrors = append(exportErrors, fmt.Errorf("error writing merged metadata: %v", err))
+		}
+	}
+	if state != nil && !options.DryRun {
+		if err := state.write(log); err != nil {
+			exportErrors = append(exportErrors, fmt.Errorf("error writing export state: %v", err))
+		}
+	}
+	return errors.Join(exportErrors...)
+}
+
+// findMPRFiles returns the mpr files below the input directory, except those in skipped paths
+func findMPRFiles(ctx context.Context, inputDirectory string, skip []string, log Logger) ([]string, error) {
+	MPRFiles := make([]string, 0)
 	err := filepath.Walk(inputDirectory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
+			return handleWalkError(inputDirectory, path, info, err, log)
+		}
+		if err := ctx.Err(); err != nil {
 			return err
 		}
-		if strings.Contains(path, ".mendix-cache") {
-			log.Debugf("Skipping system managed file %s", path)
+		if isSkippedPath(inputDirectory, path, skip) {
+			log.Debugf("Skipping %s", path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if !info.IsDir() && strings.HasSuffix(info.Name(), ".mpr") {
-			exportMPR(path, outputDirectory, raw, mode)
+			MPRFiles = append(MPRFiles, path)
 		}
 		return nil
 	})
-	return err
+	return MPRFiles, err
 }
 
-func exportMetadata(MPRFilePath string, outputDirectory string) error {
+// handleWalkError skips files and directories below the input directory that cannot be accessed or disappeared
+// during the walk, so the accessible mpr files are still exported. Other errors and errors on the input
+// directory itself stop the walk.
+func handleWalkError(inputDirectory string, path string, info os.FileInfo, err error, log Logger) error {
+	if path == inputDirectory || (!errors.Is(err, fs.ErrPermission) && !errors.Is(err, fs.ErrNotExist)) {
+		return err
+	}
+	log.Warnf("Skipping %s: %v", path, err)
+	if info != nil && info.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// DefaultSkipPaths are the names of system managed files and directories that are not searched for mpr files
+// unless ExportOptions.Skip is set
+var DefaultSkipPaths = []string{".mendix-cache"}
+
+// isSkippedPath reports whether a component of the path below the input directory matches one of the patterns.
+// Nil patterns mean DefaultSkipPaths.
+func isSkippedPath(inputDirectory string, filePath string, patterns []string) bool {
+	if patterns == nil {
+		patterns = DefaultSkipPaths
+	}
+	relativePath, err := filepath.Rel(inputDirectory, filePath)
+	if err != nil || relativePath == "." {
+		return false
+	}
+	for _, component := range strings.Split(filepath.ToSlash(relativePath), "/") {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, component); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseMPR returns the units of an mpr file with their decoded BSON contents, without any folder or document
+// transformation. Use it to build custom exporters on top of the model.
+func ParseMPR(path string) ([]MxUnit, error) {
+	file, err := readMPRFile(context.Background(), path, ExportOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return file.Units, nil
+}
 
+// readMPRFile reads the versions and units of an mpr file using a single database connection
+func readMPRFile(ctx context.Context, MPRFilePath string, options ExportOptions) (mprFile, error) {
+	if err := checkSQLiteHeader(MPRFilePath); err != nil {
+		return mprFile{}, err
+	}
 	db, err := sql.Open("sqlite", MPRFilePath)
 	if err != nil {
-		return err
+		return mprFile{}, fmt.Errorf("error opening database: %v", err)
 	}
 	defer db.Close()
 
-	rows, err := db.Query("SELECT _ProductVersion, _BuildVersion FROM _MetaData")
+	var productVersion, buildVersion string
+	var units []MxUnit
+	var skippedUnits int
+	err = withLockRetries(ctx, MPRFilePath, options, func() error {
+		if err := checkMPRSchema(ctx, db, MPRFilePath); err != nil {
+			return err
+		}
+		var err error
+		productVersion, buildVersion, err = getMxProductVersion(ctx, db, options.logger())
+		if err != nil {
+			return err
+		}
+		// the version is logged once it is known for certain, an unknown version uses the latest names
+		version, _ := parseProductVersion(productVersion)
+		units, skippedUnits, err = getMxUnits(ctx, db, version, options)
+		if err != nil {
+			return fmt.Errorf("error getting units: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return mprFile{}, err
+	}
+	if options.NormalizeIDs {
+		normalizeUnitIDs(units)
+	}
+	version := getMxVersion(productVersion, options.logger())
+	if err := resolveDuplicateModules(units, version, options.OnDuplicateModule, options.logger()); err != nil {
+		return mprFile{}, err
+	}
+	return mprFile{
+		Path:           MPRFilePath,
+		ProductVersion: productVersion,
+		BuildVersion:   buildVersion,
+		Version:        version,
+		Units:          units,
+		SkippedUnits:   skippedUnits,
+	}, nil
+}
+
+// checkSQLiteHeader reports files that are not SQLite databases, like corrupt or truncated mpr files
+func checkSQLiteHeader(MPRFilePath string) error {
+	file, err := os.Open(MPRFilePath)
 	if err != nil {
-		return fmt.Errorf("error querying units: %v", err)
+		return fmt.Errorf("error opening %s: %v", MPRFilePath, err)
 	}
+	defer file.Close()
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(file, header); err != nil || string(header) != "SQLite format 3\x00" {
+		return fmt.Errorf("%s is not a valid mpr file: it is not a SQLite database, the file may be corrupt", MPRFilePath)
+	}
+	return nil
+}
 
-	log.Debugf("Exporting metadata")
+// checkMPRSchema reports SQLite databases without the tables of an mpr file, like models of older Mendix versions
+func checkMPRSchema(ctx context.Context, db *sql.DB, MPRFilePath string) error {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table'")
+	if err != nil {
+		return fmt.Errorf("error reading the schema of %s: %v", MPRFilePath, err)
+	}
 	defer rows.Close()
-
-	if !rows.Next() {
-		return fmt.Errorf("no metadata found")
+	tables := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("error reading the schema of %s: %v", MPRFilePath, err)
+		}
+		tables[name] = true
+	}
+	missing := make([]string, 0)
+	for _, table := range []string{"Unit", "_MetaData"} {
+		if !tables[table] {
+			missing = append(missing, table)
+		}
 	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s has an unexpected schema: missing tables %s, it may have been saved by an unsupported Mendix version", MPRFilePath, strings.Join(missing, ", "))
+	}
+	return nil
+}
 
-	var productVersion, buildVersion string
-	if err := rows.Scan(&productVersion, &buildVersion); err != nil {
-		return fmt.Errorf("error scanning metadata: %v", err)
+// getMxMetadata returns the metadata with all modules. The exports of split modules list only their module.
+func getMxMetadata(file mprFile, options ExportOptions) MxMetadata {
+	modules := getMxModules(file.Units, file.Version, options.Modules, options.logger())
+	if options.SplitModules {
+		modules = exportedModules(modules)
+	}
+	return MxMetadata{
+		ProductVersion: file.ProductVersion,
+		BuildVersion:   file.BuildVersion,
+		Modules:        modules,
 	}
+}
 
-	units, err := getMxUnits(MPRFilePath)
+// getMxProductVersion returns the versions of the first row of _MetaData. mpr files have a single row; when
+// there are more, the first row wins and the others are logged.
+func getMxProductVersion(ctx context.Context, db *sql.DB, log Logger) (string, string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT _ProductVersion, _BuildVersion FROM _MetaData")
 	if err != nil {
-		return fmt.Errorf("error getting units: %v", err)
+		return "", "", fmt.Errorf("error querying units: %v", err)
 	}
-	modules := getMxModules(units)
 
-	// create metadata object
-	metadataObj := MxMetadata{
-		ProductVersion: productVersion,
-		BuildVersion:   buildVersion,
-		Modules:        modules,
+	log.Debugf("Exporting metadata")
+	defer rows.Close()
+
+	var productVersion, buildVersion string
+	count := 0
+	for rows.Next() {
+		var rowProductVersion, rowBuildVersion string
+		if err := rows.Scan(&rowProductVersion, &rowBuildVersion); err != nil {
+			return "", "", fmt.Errorf("error scanning metadata: %v", err)
+		}
+		count++
+		if count == 1 {
+			productVersion, buildVersion = rowProductVersion, rowBuildVersion
+			continue
+		}
+		log.Warnf("Ignoring metadata row %d with product version %s and build version %s, using %s and %s of the first row", count, rowProductVersion, rowBuildVersion, productVersion, buildVersion)
+	}
+	if err := rows.Err(); err != nil {
+		return "", "", fmt.Errorf("error reading metadata: %v", err)
+	}
+	if count == 0 {
+		return "", "", fmt.Errorf("no metadata found")
 	}
+	return productVersion, buildVersion, nil
+}
+
+func exportMetadata(file mprFile, output Sink, options ExportOptions) error {
+	log := options.logger()
+	metadataObj := getMxMetadata(file, options)
+	modules := metadataObj.Modules
 
 	// write metadata to file
-	metadataYAML, err := yaml.Marshal(metadataObj)
+	metadataYAML, err := marshal(metadataObj, options.Format)
 	if err != nil {
 		return fmt.Errorf("error marshaling metadata: %v", err)
 	}
-
-	if _, err := os.Stat(outputDirectory); os.IsNotExist(err) {
-		if err := os.MkdirAll(outputDirectory, 0755); err != nil {
-			return fmt.Errorf("error creating directory: %v", err)
+	if options.ValidateRoundtrip {
+		if err := validateRoundtrip(metadataYAML, metadataObj, options.Format); err != nil {
+			return fmt.Errorf("error validating metadata: %v", err)
 		}
 	}
-	metadataFileName := filepath.Join(outputDirectory, "Metadata.yaml")
 
-	if err := os.WriteFile(metadataFileName, metadataYAML, 0644); err != nil {
+	metadataFileName := "Metadata." + fileExtension(options.Format)
+	if options.DryRun {
+		log.Infof("Would write %s (%d bytes)", metadataFileName, len(metadataYAML))
+		return nil
+	}
+
+	if err := writeSinkFile(output, metadataFileName, metadataYAML); err != nil {
 		return fmt.Errorf("error writing metadata file: %v", err)
 	}
 
+	moduleDirectories, err := getModuleDirectories(modules, options.ModuleDirTemplate, log)
+	if err != nil {
+		return err
+	}
+	if err := exportModuleFiles(exportedModules(modules), moduleDirectories, newSubSink(output, getRootFolderPath(options)), options.Format, log); err != nil {
+		return fmt.Errorf("error writing module files: %v", err)
+	}
+
 	return nil
 
 }
 
-func getMxModules(units []MxUnit) []MxModule {
+// getMxModules lists all modules; those matching the module filter are marked as exported
+func getMxModules(units []MxUnit, version MxVersion, moduleFilter []string, log Logger) []MxModule {
+	// module settings carry the version and jar dependencies of their module
+	settings := make(map[string]map[string]interface{})
+	for _, unit := range units {
+		if unit.ContainmentName == containmentName("ModuleSettings", version) {
+			settings[unit.ContainerID] = unit.Contents
+		}
+	}
+
 	modules := make([]MxModule, 0)
 	for _, unit := range units {
-		if unit.ContainmentName == "Modules" {
+		if unit.ContainmentName == containmentName("Modules", version) {
+			name := getUnitName(unit, log)
 			myModule := MxModule{
-				Name:       unit.Contents["Name"].(string),
-				ID:         unit.UnitID,
-				Attributes: unit.Contents,
+				Name:         name,
+				ID:           unit.UnitID,
+				Source:       "custom",
+				Exported:     matchesModuleFilter(name, moduleFilter),
+				Dependencies: getMxModuleDependencies(settings[unit.UnitID]),
+				Attributes:   unit.Contents,
+			}
+			if version, ok := settings[unit.UnitID]["Version"].(string); ok {
+				myModule.Version = version
+			}
+			if fromAppStore, ok := unit.Contents["FromAppStore"].(bool); ok && fromAppStore {
+				myModule.Source = "marketplace"
+				if version, ok := unit.Contents["AppStoreVersion"].(string); ok && version != "" {
+					myModule.Version = version
+				}
 			}
 			modules = append(modules, myModule)
 		}
 	}
+	// units come in the order of the database, sort for stable output
+	sort.SliceStable(modules, func(i, j int) bool {
+		if modules[i].Name != modules[j].Name {
+			return modules[i].Name < modules[j].Name
+		}
+		return modules[i].ID < modules[j].ID
+	})
 	return modules
 }
 
-func getMxFolders(units []MxUnit) ([]MxFolder, error) {
+func exportedModules(modules []MxModule) []MxModule {
+	result := make([]MxModule, 0, len(modules))
+	for _, module := range modules {
+		if module.Exported {
+			result = append(result, module)
+		}
+	}
+	return result
+}
+
+// matchesModuleFilter reports whether a module name matches one of the patterns, ignoring case.
+// A pattern may end with * to match a prefix. An empty filter matches every module.
+func matchesModuleFilter(module string, moduleFilter []string) bool {
+	if len(moduleFilter) == 0 {
+		return true
+	}
+	for _, pattern := range moduleFilter {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if len(module) >= len(prefix) && strings.EqualFold(module[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(module, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTypeFilter reports whether a document type matches one of the patterns.
+// A pattern is either a full type like Microflows$Microflow or its prefix like Microflows.
+func matchesTypeFilter(documentType string, typeFilter []string) bool {
+	for _, pattern := range typeFilter {
+		if documentType == pattern || strings.HasPrefix(documentType, pattern+"$") {
+			return true
+		}
+	}
+	return false
+}
+
+func getMxModuleDependencies(settings map[string]interface{}) []MxModuleDependency {
+	dependencies := make([]MxModuleDependency, 0)
+	jars, ok := settings["JarDependencies"].(bson.A)
+	if !ok {
+		return dependencies
+	}
+	for _, jar := range jars {
+		jarMap, ok := jar.(bson.M)
+		if !ok {
+			continue
+		}
+		dependency := MxModuleDependency{}
+		dependency.GroupId, _ = jarMap["GroupId"].(string)
+		dependency.ArtifactId, _ = jarMap["ArtifactId"].(string)
+		dependency.Version, _ = jarMap["Version"].(string)
+		dependencies = append(dependencies, dependency)
+	}
+	return dependencies
+}
+
+func exportModuleFiles(modules []MxModule, moduleDirectories map[string]string, output Sink, format string, log Logger) error {
+	for _, module := range modules {
+		directory := filepath.ToSlash(getModuleDirectory(module.Name, moduleDirectories))
+		moduleInfo := map[string]interface{}{
+			"Name":         module.Name,
+			"Version":      module.Version,
+			"Source":       module.Source,
+			"Dependencies": module.Dependencies,
+		}
+		if err := writeFile(output, path.Join(directory, "Module."+fileExtension(format)), moduleInfo, format, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getUnitName returns the name of a module or folder unit, falling back to its unit ID when the name is missing
+func getUnitName(unit MxUnit, log Logger) string {
+	if name, ok := unit.Contents["Name"].(string); ok {
+		return name
+	}
+	log.Warnf("Unit %s has no valid name, using its ID instead", unit.UnitID)
+	return sanitizeFilename(unit.UnitID)
+}
+
+// getMxFolders returns the folders and modules of the model. The project itself is the unnamed root folder, so
+// folder paths start with the module. Folders whose parent is missing are reported, or fail the export with
+// options.StrictFolders.
+func getMxFolders(units []MxUnit, version MxVersion, options ExportOptions) ([]MxFolder, error) {
+	log := options.logger()
 	var folders []MxFolder
+	folderTypes := folderContainmentNames(version)
 	for _, unit := range units {
-		if unit.ContainmentName == "Folders" || unit.ContainmentName == "Modules" {
+		if Contains(folderTypes, unit.ContainmentName) {
 			log.Debugf("Unit: %v", unit)
 			myFolder := MxFolder{
-				Name:       unit.Contents["Name"].(string),
+				Name:       getUnitName(unit, log),
 				ID:         unit.UnitID,
 				ParentID:   unit.ContainerID,
 				Attributes: unit.Contents,
@@ -120,7 +730,7 @@ func getMxFolders(units []MxUnit) ([]MxFolder, error) {
 			folders = append(folders, myFolder)
 		} else if unit.ContainmentName == "" {
 			myFolder := MxFolder{
-				Name:       ".",
+				Name:       "",
 				ID:         unit.UnitID,
 				ParentID:   unit.ContainerID,
 				Attributes: unit.Contents,
@@ -137,167 +747,546 @@ func getMxFolders(units []MxUnit) ([]MxFolder, error) {
 	}
 
 	// Set up the parent references.
+	var unresolved []string
 	for i, folder := range folders {
 		if parent, exists := folderMap[folder.ParentID]; exists && folder.ParentID != folder.ID {
 			folders[i].Parent = parent
+		} else if folder.ParentID != folder.ID && folder.Attributes["$Type"] != "Projects$Project" {
+			log.Warnf("Parent %s of folder %s (%s) not found, its documents are exported at a shallower path", folder.ParentID, folder.Name, folder.ID)
+			unresolved = append(unresolved, folder.ID)
 		}
 	}
+	if len(unresolved) > 0 && options.StrictFolders {
+		return nil, fmt.Errorf("parent not found for folders %s", strings.Join(unresolved, ", "))
+	}
 
 	return folders, nil
 }
 
-func getMxDocumentPathRecursive(folder MxFolder, depth int) string {
-	if depth == 0 {
-		return ""
-	}
-	if folder.Parent == nil {
-		return folder.Name
-	} else {
-		return filepath.Join(getMxDocumentPathRecursive(*folder.Parent, depth-1), folder.Name)
+// getMxFolderPaths resolves the path of every folder once, so documents can look up their path by container ID.
+// Folder cycles are reported to the log.
+func getMxFolderPaths(folders []MxFolder, log Logger) map[string]string {
+	paths := make(map[string]string, len(folders))
+	for _, folder := range folders {
+		getMxFolderPath(folder, paths, nil, log)
 	}
+	return paths
 }
 
-func getMxDocumentPath(containerID string, folders []MxFolder) string {
-	for _, folder := range folders {
-		if folder.ID == containerID {
-			return getMxDocumentPathRecursive(folder, 10)
+// getMxFolderPath resolves the full path of a folder of any depth. The chain of visited folder IDs is used to
+// stop at cycles, in which case the path up to the cycle is returned.
+func getMxFolderPath(folder MxFolder, paths map[string]string, chain []string, log Logger) string {
+	if path, ok := paths[folder.ID]; ok {
+		return path
+	}
+	for _, id := range chain {
+		if id == folder.ID {
+			log.Warnf("Folder cycle detected: %s", strings.Join(append(chain, folder.ID), " -> "))
+			return ""
 		}
 	}
-	return ""
+	if folder.Parent == nil {
+		path := sanitizeFilename(folder.Name)
+		if path == "." {
+			// a root named . would otherwise show up as a leading segment of document paths
+			path = ""
+		}
+		paths[folder.ID] = path
+		return path
+	}
+	path := filepath.Join(getMxFolderPath(*folder.Parent, paths, append(chain, folder.ID), log), sanitizeFilename(folder.Name))
+	paths[folder.ID] = path
+	return path
 }
 
-func getMxDocuments(units []MxUnit, folders []MxFolder, mode string) ([]MxDocument, error) {
+// ErrSinceNotSupported is returned when documents are filtered by modification time. Neither the Unit table nor
+// the contents of units hold a modification timestamp; use ExportModelDiff or an incremental export instead.
+var ErrSinceNotSupported = errors.New("filtering by modification time is not supported: the mpr schema does not record when units were changed")
+
+func getMxDocuments(units []MxUnit, folderPaths map[string]string, version MxVersion, options ExportOptions) ([]MxDocument, error) {
+	if !options.Since.IsZero() {
+		return nil, ErrSinceNotSupported
+	}
+	log := options.logger()
 	var documents []MxDocument
-	documentTypes := []string{"ProjectDocuments", "DomainModel", "ModuleSettings", "ModuleSecurity", "Documents"}
+	mode, moduleFilter, excludeTypes := options.Mode, options.Modules, options.ExcludeTypes
+	documentTypes := getDocumentContainments(version, options)
+	documentIndex := buildDocumentIndex(units, folderPaths, version)
+	var refIndex map[string]MxDocumentRef
+	if mode == "advanced" && options.ResolveReferences {
+		refIndex = buildDocumentRefIndex(units, folderPaths, version)
+	}
+	skipped := make(map[string]bool)
+	folderTypes := folderContainmentNames(version)
+	nameFilter, err := regexp.Compile(options.NameFilter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name filter %s: %v", options.NameFilter, err)
+	}
+	redactPatterns, err := compileRedactPatterns(options.Redact)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, unit := range units {
-		if Contains(documentTypes, unit.ContainmentName) {
+		if !ContainsFold(documentTypes, unit.ContainmentName) && !Contains(folderTypes, unit.ContainmentName) && unit.ContainmentName != "" {
+			skipped[unit.ContainmentName] = true
+		}
+		// the casing of containment names differs between versions of the modeler
+		if ContainsFold(documentTypes, unit.ContainmentName) {
+			log := withLogField(log, "unit", unit.UnitID)
 			log.Debugf("Unit: %v", unit)
 			var name = ""
 			if unit.Contents["Name"] != nil {
-				name = unit.Contents["Name"].(string)
+				var ok bool
+				if name, ok = unit.Contents["Name"].(string); !ok {
+					log.Warnf("Unit %s has a name of type %T, exporting it without name", unit.UnitID, unit.Contents["Name"])
+				}
+			}
+			documentType, ok := unit.Contents["$Type"].(string)
+			if !ok {
+				log.Warnf("Skipping unit %s without a valid $Type", unit.UnitID)
+				continue
 			}
 
 			myDocument := MxDocument{
 				Name:       name,
-				Type:       unit.Contents["$Type"].(string),
-				Path:       getMxDocumentPath(unit.ContainerID, folders),
+				Type:       documentType,
+				Path:       folderPaths[unit.ContainerID],
 				Attributes: unit.Contents,
+				unitID:     unit.rawUnitID,
+			}
+			if len(moduleFilter) > 0 && !matchesModuleFilter(getMxModuleName(myDocument.Path), moduleFilter) {
+				log.Debugf("Skipping %s outside of selected modules", myDocument.Name)
+				continue
+			}
+			if matchesTypeFilter(myDocument.Type, excludeTypes) {
+				log.Debugf("Skipping %s of excluded type %s", myDocument.Name, myDocument.Type)
+				continue
+			}
+			if !nameFilter.MatchString(myDocument.Name) {
+				log.Debugf("Skipping %s not matching the name filter", myDocument.Name)
+				continue
+			}
+
+			if options.IncludeIDs {
+				// the unit and container ID as stored in the Unit table, to trace files back to their rows
+				myDocument.Attributes["_UnitID"] = unit.UnitID
+				myDocument.Attributes["_ContainerID"] = unit.ContainerID
 			}
 
-			if mode == "advanced" && unit.Contents["$Type"] == "Microflows$Microflow" {
-				myDocument = transformMicroflow(myDocument)
+			if mode == "advanced" {
+				var metrics MxMicroflowMetrics
+				if options.EmbedMetrics && myDocument.Type == "Microflows$Microflow" {
+					metrics = getMxMicroflowMetrics(myDocument.Attributes)
+				}
+				myDocument = applyTransforms(myDocument, documentIndex, log)
+				if options.EmbedMetrics && myDocument.Type == "Microflows$Microflow" {
+					myDocument.Attributes["Metrics"] = metrics
+				}
+			}
+			if refIndex != nil && (myDocument.Type == "Microflows$Microflow" || myDocument.Type == "Forms$Page") {
+				resolveReferences(myDocument.Attributes, refIndex)
+			}
+			if len(redactPatterns) > 0 {
+				// redacted once here, so every output built from the documents hides the values
+				redactValues(myDocument.Attributes, "", redactPatterns, false)
 			}
 			documents = append(documents, myDocument)
 		}
 	}
+	if len(skipped) > 0 {
+		log.Infof("Containment names in the model that are not exported: %s", strings.Join(sortedBoolKeys(skipped), ", "))
+	}
+	sortMxDocuments(documents)
 	log.Infof("Found %d documents", len(documents))
 	return documents, nil
 }
 
-func getMxUnits(MPRFilePath string) ([]MxUnit, error) {
-	db, err := sql.Open("sqlite", MPRFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %v", err)
-	}
-	defer db.Close()
+// sortMxDocuments orders documents by path, type, name and ID, so aggregated files and the resolution of
+// file name collisions do not depend on the order of the units in the database
+func sortMxDocuments(documents []MxDocument) {
+	sort.SliceStable(documents, func(i, j int) bool {
+		a, b := documents[i], documents[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		idA, _ := idString(a.Attributes["$ID"])
+		idB, _ := idString(b.Attributes["$ID"])
+		return idA < idB
+	})
+}
 
-	rows, err := db.Query("SELECT UnitID, ContainerID, ContainmentName, Contents FROM Unit")
+// getMxUnits reads and decodes the units. In metadata-only exports only the module units are read, so the BSON
+// of the other units is never decoded. Units whose BSON cannot be decoded or is larger than MaxUnitBytes are
+// logged and skipped unless the export is strict; the number of skipped units is returned.
+func getMxUnits(ctx context.Context, db *sql.DB, version MxVersion, options ExportOptions) ([]MxUnit, int, error) {
+	log := options.logger()
+	var containmentNames []string
+	if options.MetadataOnly {
+		containmentNames = metadataContainmentNames(version)
+	}
+	query := "SELECT UnitID, ContainerID, ContainmentName, Contents FROM Unit"
+	args := make([]interface{}, 0, len(containmentNames))
+	if len(containmentNames) > 0 {
+		query += " WHERE ContainmentName IN (?" + strings.Repeat(", ?", len(containmentNames)-1) + ")"
+		for _, name := range containmentNames {
+			args = append(args, name)
+		}
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("error querying units: %v", err)
+		return nil, 0, fmt.Errorf("error querying units: %v", err)
 	}
 	defer rows.Close()
 
 	units := make([]MxUnit, 0)
+	skipped := 0
 
 	for rows.Next() {
 		var containmentName string
 		var unitID, containerID, contents []byte
 		if err := rows.Scan(&unitID, &containerID, &containmentName, &contents); err != nil {
-			return nil, fmt.Errorf("error scanning unit: %v", err)
+			return nil, 0, fmt.Errorf("error scanning unit: %v", err)
+		}
+
+		if options.MaxUnitBytes > 0 && len(contents) > options.MaxUnitBytes {
+			id := encodeUnitID(unitID, options.IDEncoding)
+			if options.Strict {
+				return nil, 0, fmt.Errorf("unit %s in %s has %d bytes, more than the maximum of %d", id, containmentName, len(contents), options.MaxUnitBytes)
+			}
+			log.Warnf("Skipping unit %s in %s of %d bytes, more than the maximum of %d", id, containmentName, len(contents), options.MaxUnitBytes)
+			skipped++
+			continue
 		}
 
 		var result bson.M
 
 		err := bson.Unmarshal(contents, &result)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing unit: %v", err)
+			id := encodeUnitID(unitID, options.IDEncoding)
+			if options.Strict {
+				return nil, 0, fmt.Errorf("error parsing unit %s: %v", id, err)
+			}
+			log.Warnf("Skipping unit %s in %s that cannot be decoded: %v", id, containmentName, err)
+			skipped++
+			continue
 		}
 
 		// create unit object
 		myUnit := MxUnit{
-			UnitID:          base64.StdEncoding.EncodeToString(unitID),
-			ContainerID:     base64.StdEncoding.EncodeToString(containerID),
+			UnitID:          encodeUnitID(unitID, options.IDEncoding),
+			ContainerID:     encodeUnitID(containerID, options.IDEncoding),
 			ContainmentName: containmentName,
 			Contents:        result,
+			rawUnitID:       unitID,
+		}
+		if options.EmitBSON {
+			myUnit.RawContents = contents
 		}
 
 		units = append(units, myUnit)
 	}
-	return units, nil
+	return units, skipped, nil
 }
 
-func exportUnits(MPRFilePath string, outputDirectory string, raw bool, mode string) error {
-
-	units, err := getMxUnits(MPRFilePath)
-	if err != nil {
-		return fmt.Errorf("error getting units: %v", err)
-	}
-	folders, err := getMxFolders(units)
+func exportUnits(ctx context.Context, file mprFile, output Sink, options ExportOptions, stats *ExportStats) error {
+	log := options.logger()
+	units := file.Units
+	folders, err := getMxFolders(units, file.Version, options)
 	if err != nil {
 		return fmt.Errorf("error getting folders: %v", err)
 	}
-	documents, err := getMxDocuments(units, folders, mode)
+	folderPaths := getMxFolderPaths(folders, log)
+	if options.GraphML && !options.DryRun {
+		// build the graph before transformations alter the unit contents
+		if err := exportGraphML(units, folderPaths, output, log); err != nil {
+			return fmt.Errorf("error exporting graphml: %v", err)
+		}
+	}
+	if options.EmitTree && !options.DryRun {
+		if err := exportTree(folders, output, options.Format, log); err != nil {
+			return fmt.Errorf("error exporting tree: %v", err)
+		}
+	}
+	if options.MicroflowMetrics && !options.DryRun {
+		// the metrics are computed before transformations replace the object collection of microflows
+		if err := exportMicroflowMetrics(units, folderPaths, file.Version, options.Modules, output, options.Format, log); err != nil {
+			return fmt.Errorf("error exporting microflow metrics: %v", err)
+		}
+	}
+	if options.Links && !options.DryRun {
+		if err := exportLinks(units, folderPaths, output, options.Format, log); err != nil {
+			return fmt.Errorf("error exporting links: %v", err)
+		}
+	}
+	documents, err := getMxDocuments(units, folderPaths, file.Version, options)
 	if err != nil {
 		return fmt.Errorf("error getting documents: %v", err)
 	}
-	for _, document := range documents {
-		// write document
-		directory := filepath.Join(outputDirectory, document.Path)
-		// ensure directory exists
-		if _, err := os.Stat(directory); os.IsNotExist(err) {
-			if err := os.MkdirAll(directory, 0755); err != nil {
-				return fmt.Errorf("error creating directory: %v", err)
+	*stats = getExportStats(file, folders, folderPaths, documents, options)
+	if orphans := getOrphanedDocuments(units, folders, documents); len(orphans) > 0 {
+		for _, orphan := range orphans {
+			log.Warnf("Document %s is not attached to any module", orphan)
+		}
+		if options.Strict {
+			return fmt.Errorf("%d documents are not attached to any module", len(orphans))
+		}
+	}
+	if options.Mode == "schema-stats" {
+		if options.DryRun {
+			log.Infof("Would write schema-stats.%s", fileExtension(options.Format))
+			return nil
+		}
+		return exportSchemaStats(units, output, options.Format, log)
+	}
+	moduleDirectories, err := getModuleDirectories(getMxModules(units, file.Version, options.Modules, log), options.ModuleDirTemplate, log)
+	if err != nil {
+		return err
+	}
+	// output files relative to the output directory by $ID
+	files, fileNames, err := getDocumentFiles(documents, moduleDirectories, options)
+	if err != nil {
+		return err
+	}
+	if options.DryRun {
+		return dryRunDocuments(documents, files, options)
+	}
+	if options.Translations {
+		if err := exportTranslations(documents, files, output, options, log); err != nil {
+			return fmt.Errorf("error exporting translations: %v", err)
+		}
+	}
+	if options.LanguageTexts {
+		if err := exportLanguageTexts(documents, files, output, options, log); err != nil {
+			return fmt.Errorf("error exporting texts: %v", err)
+		}
+	}
+	if options.Mode == "headers" {
+		return exportCatalog(documents, files, output, options.Format, log)
+	}
+	location, layout, err := getTimestampFormat(options)
+	if err != nil {
+		return err
+	}
+	// the original BSON of the units by $ID, which is kept by the transformations of the documents
+	rawContents := make(map[string][]byte)
+	if options.EmitBSON {
+		for _, unit := range units {
+			id, _ := idString(unit.Contents["$ID"])
+			rawContents[id] = unit.RawContents
+		}
+	}
+	var defaults typeDefaults
+	if options.Delta {
+		defaults = inferTypeDefaults(documents, options.Raw, options.StripKeys)
+		// the left out attributes are restored from the defaults with restoreTypeDefaults
+		if err := writeFile(output, "defaults."+fileExtension(options.Format), map[string]interface{}{"Types": defaults}, options.Format, log); err != nil {
+			return fmt.Errorf("error writing defaults: %v", err)
+		}
+	}
+	manifest := make(map[string]string)
+	var manifestLock sync.Mutex
+	flatModules := make(map[string][]map[string]interface{})
+	var flatModulesLock sync.Mutex
+	writeDocument := func(document MxDocument) error {
+		id, _ := idString(document.Attributes["$ID"])
+		log := withLogField(log, "document", files[id])
+		directory := path.Dir(files[id])
+		// file names from a template may hold a sub-path, which is part of the directory
+		fname := filepath.Base(fileNames[id])
+		attributes := cleanData(document.Attributes, options.Raw, options.StripKeys)
+		if options.Delta {
+			attributes = removeTypeDefaults(attributes, defaults).(bson.M)
+		}
+		if len(options.CaptionLanguages) > 0 {
+			attributes = resolveCaptions(attributes, options.CaptionLanguages).(bson.M)
+		}
+		if !options.Raw {
+			// raw attributes are the unit contents themselves and are written as they are in the model
+			formatTimestamps(attributes, location, layout)
+		}
+		if options.MaxStringLength > 0 {
+			sidecarDirectory := path.Join(directory, strings.TrimSuffix(fname, "."+fileExtension(options.Format))+".strings")
+			if _, err := limitLargeStrings(attributes, "", options.MaxStringLength, options.LargeStrings, output, sidecarDirectory); err != nil {
+				return fmt.Errorf("error limiting large strings: %v", err)
 			}
 		}
-		fname := fmt.Sprintf("%s.%s.yaml", document.Name, document.Type)
-		if document.Name == "" {
-			fname = fmt.Sprintf("%s.yaml", document.Type)
+		if options.ContentStore != "" {
+			hash, err := writeObject(options.ContentStore, attributes, options.Format, log)
+			if err != nil {
+				return fmt.Errorf("error storing document: %v", err)
+			}
+			manifestLock.Lock()
+			manifest[filepath.ToSlash(filepath.Join(getRootFolderPath(options), document.Path, fileNames[id]))] = hash
+			manifestLock.Unlock()
+			return nil
 		}
-		attributes := cleanData(document.Attributes, raw)
-		err = writeFile(filepath.Join(directory, fname), attributes)
-		if err != nil {
+		if options.Layout == "flat-module" {
+			flatModulesLock.Lock()
+			flatModules[files[id]] = append(flatModules[files[id]], map[string]interface{}{
+				"Name":       document.Name,
+				"Type":       document.Type,
+				"Path":       filepath.ToSlash(document.Path),
+				"Attributes": attributes,
+			})
+			flatModulesLock.Unlock()
+			return nil
+		}
+		if err := writeDocumentFile(output, path.Join(directory, fname), attributes, options.Format, options.MaxFileBytes, options.ValidateRoundtrip, log); err != nil {
 			log.Errorf("Error writing file: %v", err)
 			return err
 		}
+		if options.EmitBSON {
+			sidecar := path.Join(directory, strings.TrimSuffix(fname, "."+fileExtension(options.Format))+".bson")
+			if err := writeSinkFile(output, sidecar, rawContents[id]); err != nil {
+				return fmt.Errorf("error writing bson: %v", err)
+			}
+		}
+		if options.EmitDiagrams && document.Type == "Microflows$Microflow" {
+			diagram := path.Join(directory, strings.TrimSuffix(fname, "."+fileExtension(options.Format))+".mmd")
+			if err := writeSinkFile(output, diagram, []byte(renderMicroflowMermaid(document))); err != nil {
+				return fmt.Errorf("error writing diagram: %v", err)
+			}
+		}
+		return nil
+	}
+	done := 0
+	var progressLock sync.Mutex
+	reportDocument := func(document MxDocument) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := writeDocument(document)
+		if options.Progress != nil {
+			progressLock.Lock()
+			done++
+			options.Progress(done, len(documents))
+			progressLock.Unlock()
+		}
+		return err
+	}
+	if err := runWorkers(options.Workers, documents, reportDocument); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	if options.Layout == "flat-module" && options.ContentStore == "" {
+		if err := writeFlatModules(output, flatModules, options.Format, log); err != nil {
+			return err
+		}
+	}
+
+	// with a content store the manifest maps the documents to their stored objects instead
+	if options.ContentStore != "" {
+		if err := writeManifest(output, manifest, options.Format, log); err != nil {
+			return fmt.Errorf("error writing manifest: %v", err)
+		}
+	} else if err := exportManifest(file.Path, documents, files, output, options.Format, log); err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+	if options.EntitiesSummary {
+		if err := exportEntitiesSummary(documents, output, options.Format, log); err != nil {
+			return fmt.Errorf("error exporting entities summary: %v", err)
+		}
+	}
+	if options.PublicAPI {
+		if err := exportPublicAPI(documents, moduleDirectories, newSubSink(output, getRootFolderPath(options)), options.Format, log); err != nil {
+			return fmt.Errorf("error exporting public api: %v", err)
+		}
+	}
+	if options.CodeOwners != "" {
+		if err := exportCodeOwners(documents, files, options.CodeOwners, output); err != nil {
+			return fmt.Errorf("error exporting codeowners: %v", err)
+		}
 	}
 
 	return nil
 
 }
 
-func writeFile(filepath string, contents map[string]interface{}) error {
-	log.Debugf("Writing file %s", filepath)
-	yamlstring, err := yaml.Marshal(contents)
+func getMxDocumentFileName(document MxDocument, format string) string {
+	if document.Name == "" {
+		return fmt.Sprintf("%s.%s", document.Type, fileExtension(format))
+	}
+	return fmt.Sprintf("%s.%s.%s", sanitizeFilename(document.Name), document.Type, fileExtension(format))
+}
+
+// fileExtension returns the extension of files written in the given format. yaml is the default
+func fileExtension(format string) string {
+	if format == "json" || format == "xml" || format == "properties" {
+		return format
+	}
+	return "yaml"
+}
+
+// stateFormat returns the format of files that are read back, like checksums and the export state. xml and
+// properties exports write them in yaml.
+func stateFormat(format string) string {
+	if format == "xml" || format == "properties" {
+		return "yaml"
+	}
+	return format
+}
+
+// marshal serializes the contents in the given format. All formats go through encoding/json, which writes
+// map keys in sorted order at every level, so repeated exports of the same model produce identical files.
+func marshal(contents interface{}, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(contents, "", "  ")
+	case "xml":
+		return marshalXML(contents)
+	case "properties":
+		return marshalProperties(contents)
+	}
+	return yaml.Marshal(contents)
+}
+
+func writeFile(output Sink, name string, contents map[string]interface{}, format string, log Logger) error {
+	log.Debugf("Writing file %s", name)
+	yamlstring, err := marshal(contents, format)
 	if err != nil {
 		return fmt.Errorf("error marshaling: %v", err)
 	}
 
-	if err := os.WriteFile(filepath, yamlstring, 0644); err != nil {
+	if err := writeSinkFile(output, name, yamlstring); err != nil {
 		return fmt.Errorf("error writing file: %v", err)
 	}
 	return nil
 }
 
-func exportMPR(MPRFilePath string, outputDirectory string, raw bool, mode string) error {
-	log.Infof("Exporting %s to %s", MPRFilePath, outputDirectory)
-	if err := exportMetadata(MPRFilePath, outputDirectory); err != nil {
-		return fmt.Errorf("error exporting metadata: %v", err)
+func exportMPR(ctx context.Context, MPRFilePath string, output Sink, options ExportOptions) (ExportStats, error) {
+	log := options.logger()
+	log.Infof("Exporting %s", MPRFilePath)
+	var stats ExportStats
+	file, err := readMPRFile(ctx, MPRFilePath, options)
+	if err != nil {
+		return stats, fmt.Errorf("error reading %s: %v", MPRFilePath, err)
+	}
+	if err := exportMetadata(file, output, options); err != nil {
+		return stats, fmt.Errorf("error exporting metadata: %v", err)
+	}
+	if options.MetadataOnly {
+		stats.Metadata = getMxMetadata(file, options)
+		stats.Modules = len(exportedModules(stats.Metadata.Modules))
+		stats.SkippedUnits = file.SkippedUnits
+		logExportStats(MPRFilePath, stats, log)
+		log.Infof("Completed metadata of %s", MPRFilePath)
+		return stats, nil
 	}
 
-	if err := exportUnits(MPRFilePath, outputDirectory, raw, mode); err != nil {
-		return fmt.Errorf("error exporting units: %v", err)
+	if err := exportUnits(ctx, file, output, options, &stats); err != nil {
+		return stats, fmt.Errorf("error exporting units: %v", err)
 	}
+	stats.Metadata = getMxMetadata(file, options)
+	logExportStats(MPRFilePath, stats, log)
 	log.Infof("Completed %s", MPRFilePath)
-	return nil
+	return stats, nil
 }
@@ -2,16 +2,53 @@
 package mpr
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"gopkg.in/yaml.v2"
 )
 
+// exportTestMetadata reads the mpr file and exports its metadata like exportMPR does
+func exportTestMetadata(t *testing.T, MPRFilePath string, outputDirectory string, options ExportOptions) error {
+	t.Helper()
+	file, err := readMPRFile(context.Background(), MPRFilePath, options)
+	if err != nil {
+		return err
+	}
+	return exportMetadata(file, NewLocalSink(outputDirectory), options)
+}
+
+// exportTestUnits reads the mpr file and exports its units like exportMPR does
+func exportTestUnits(t *testing.T, MPRFilePath string, outputDirectory string, options ExportOptions) error {
+	t.Helper()
+	file, err := readMPRFile(context.Background(), MPRFilePath, options)
+	if err != nil {
+		return err
+	}
+	return exportUnits(context.Background(), file, NewLocalSink(outputDirectory), options, &ExportStats{})
+}
+
 // TestAdd tests the Add function to ensure it returns correct results.
 func TestMPRMetadata(t *testing.T) {
 	t.Run("single-mpr", func(t *testing.T) {
-		if err := exportMetadata("./../resources/full-app-v2.mpr", "./../tmp"); err != nil {
+		if err := exportTestMetadata(t, "./../resources/full-app-v2.mpr", "./../tmp", ExportOptions{}); err != nil {
 			t.Errorf("Failed to export metadata from MPR file")
 		}
 
@@ -31,12 +68,1854 @@ func TestMPRMetadata(t *testing.T) {
 			t.Errorf("ProductVersion is incorrect. Expected: %s, Got: %s", expectedProductVersion, metadataObj.ProductVersion)
 		}
 	})
+	t.Run("module-versions", func(t *testing.T) {
+		file, err := readMPRFile(context.Background(), "./../resources/app/App.mpr", ExportOptions{})
+		if err != nil {
+			t.Errorf("Failed to get units from MPR file")
+		}
+		for _, module := range getMxModules(file.Units, file.Version, nil, log) {
+			if module.Name == "CommunityCommons" && (module.Source != "marketplace" || module.Version != "10.9.0") {
+				t.Errorf("Unexpected module info for %s. Got: %s %s", module.Name, module.Source, module.Version)
+			}
+			if module.Name == "MyFirstModule" && module.Source != "custom" {
+				t.Errorf("Unexpected module source for %s. Got: %s", module.Name, module.Source)
+			}
+		}
+	})
+	t.Run("split-modules", func(t *testing.T) {
+		os.RemoveAll("./../tmp/split")
+		if err := ExportModel("./../resources/app", "./../tmp/split", ExportOptions{Mode: "basic", SplitModules: true}); err != nil {
+			t.Fatalf("Failed to export modules: %v", err)
+		}
+		if _, err := os.Stat("./../tmp/split/MyFirstModule/MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml"); err != nil {
+			t.Errorf("Expected the documents of the module in its export: %v", err)
+		}
+		entries, err := os.ReadDir("./../tmp/split/MyFirstModule")
+		if err != nil {
+			t.Fatalf("Failed to read module export: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && entry.Name() != "MyFirstModule" {
+				t.Errorf("Expected only the module in its export. Got: %s", entry.Name())
+			}
+		}
+		if _, err := os.Stat("./../tmp/split/Administration/Administration/Module.yaml"); err != nil {
+			t.Errorf("Expected an export of every module: %v", err)
+		}
+		metadataFile, err := os.ReadFile("./../tmp/split/MyFirstModule/Metadata.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read module metadata file: %v", err)
+		}
+		var metadataObj MxMetadata
+		if err := yaml.Unmarshal(metadataFile, &metadataObj); err != nil {
+			t.Fatalf("Failed to unmarshal module metadata file")
+		}
+		if len(metadataObj.Modules) != 1 || metadataObj.Modules[0].Name != "MyFirstModule" {
+			t.Errorf("Expected metadata scoped to MyFirstModule. Got: %v", metadataObj.Modules)
+		}
+	})
+	t.Run("split-modules-archive", func(t *testing.T) {
+		os.RemoveAll("./../tmp/split-archive")
+		options := ExportOptions{Mode: "basic", SplitModules: true, Archive: true, Modules: []string{"MyFirstModule", "Administration"}}
+		if err := ExportModel("./../resources/app", "./../tmp/split-archive", options); err != nil {
+			t.Fatalf("Failed to export modules: %v", err)
+		}
+		entries, err := os.ReadDir("./../tmp/split-archive")
+		if err != nil {
+			t.Fatalf("Failed to read output directory: %v", err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if strings.Join(names, ",") != "Administration.tar.gz,MyFirstModule.tar.gz" {
+			t.Errorf("Expected an archive per module. Got: %v", names)
+		}
+	})
 }
 
 func TestMPRUnits(t *testing.T) {
 	t.Run("single-mpr", func(t *testing.T) {
-		if err := exportUnits("./../resources/full-app-v2.mpr", "./../tmp", false, "basic"); err != nil {
+		if err := exportTestUnits(t, "./../resources/full-app-v2.mpr", "./../tmp", ExportOptions{Raw: false, Mode: "basic"}); err != nil {
+			t.Errorf("Failed to export units from MPR file")
+		}
+	})
+}
+
+func TestMPRJSONFormat(t *testing.T) {
+	t.Run("single-mpr", func(t *testing.T) {
+		if _, err := exportMPR(context.Background(), "./../resources/app/App.mpr", NewLocalSink("./../tmp/json"), ExportOptions{Mode: "advanced", Format: "json"}); err != nil {
+			t.Errorf("Failed to export MPR file: %v", err)
+		}
+		metadataFile, err := os.ReadFile("./../tmp/json/Metadata.json")
+		if err != nil {
+			t.Fatalf("Failed to read metadata file: %v", err)
+		}
+		var metadataObj MxMetadata
+		if err := json.Unmarshal(metadataFile, &metadataObj); err != nil {
+			t.Errorf("Failed to unmarshal metadata file: %v", err)
+		}
+		mfFile, err := os.ReadFile("./../tmp/json/MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.json")
+		if err != nil {
+			t.Fatalf("Failed to read microflow file: %v", err)
+		}
+		var mfObj map[string]interface{}
+		if err := json.Unmarshal(mfFile, &mfObj); err != nil {
+			t.Errorf("Failed to unmarshal microflow file: %v", err)
+		}
+		if mfObj["Name"] != "MicroflowSimple" {
+			t.Errorf("Unexpected name. Got: %v", mfObj["Name"])
+		}
+	})
+}
+
+func TestMPRKeyOrdering(t *testing.T) {
+	contents := bson.M{
+		"Name":  "Flow",
+		"$Type": "Microflows$Microflow",
+		"Objects": bson.A{
+			int32(3),
+			bson.M{"Zeta": 1, "Alpha": bson.M{"B": true, "A": false}},
+		},
+	}
+	t.Run("yaml", func(t *testing.T) {
+		expected := "$Type: Microflows$Microflow\nName: Flow\nObjects:\n- 3\n- Alpha:\n    A: false\n    B: true\n  Zeta: 1\n"
+		for i := 0; i < 10; i++ {
+			output, err := marshal(contents, "yaml")
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+			if string(output) != expected {
+				t.Fatalf("Expected sorted keys, got:\n%s", output)
+			}
+		}
+	})
+	t.Run("export", func(t *testing.T) {
+		for _, directory := range []string{"./../tmp/ordering-1", "./../tmp/ordering-2"} {
+			if err := exportTestUnits(t, "./../resources/app/App.mpr", directory, ExportOptions{Mode: "advanced"}); err != nil {
+				t.Fatalf("Failed to export units from MPR file: %v", err)
+			}
+		}
+		fileName := "MyFirstModule/Folder/MicroflowComplexSplit.Microflows$Microflow.yaml"
+		first, err := os.ReadFile(filepath.Join("./../tmp/ordering-1", fileName))
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		second, err := os.ReadFile(filepath.Join("./../tmp/ordering-2", fileName))
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(first) != string(second) {
+			t.Errorf("Expected identical output for repeated exports")
+		}
+	})
+}
+
+func TestMPRProgress(t *testing.T) {
+	t.Run("every document", func(t *testing.T) {
+		calls, last, total := 0, 0, 0
+		options := ExportOptions{Mode: "basic", Workers: 4, Progress: func(done, count int) {
+			calls++
+			last, total = done, count
+		}}
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp/progress", options); err != nil {
+			t.Fatalf("Failed to export units from MPR file: %v", err)
+		}
+		if total == 0 || calls != total || last != total {
+			t.Errorf("Expected a call per document ending at %d, got %d calls ending at %d", total, calls, last)
+		}
+	})
+}
+
+func TestMPRGraphML(t *testing.T) {
+	t.Run("single-mpr", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Mode: "basic", GraphML: true}); err != nil {
 			t.Errorf("Failed to export units from MPR file")
 		}
+
+		graphFile, err := os.ReadFile("./../tmp/graph.graphml")
+		if err != nil {
+			t.Errorf("Failed to read graphml file: %v", err)
+		}
+		var graph GraphML
+		if err := xml.Unmarshal(graphFile, &graph); err != nil {
+			t.Errorf("Failed to unmarshal graphml file: %v", err)
+		}
+		if len(graph.Graph.Nodes) == 0 {
+			t.Errorf("Expected nodes in graph")
+		}
+		if len(graph.Graph.Edges) == 0 {
+			t.Errorf("Expected edges in graph")
+		}
+	})
+}
+
+func TestMPRDelta(t *testing.T) {
+	t.Run("remove-type-defaults", func(t *testing.T) {
+		documents := []MxDocument{
+			{Name: "A", Attributes: bson.M{"$Type": "Test$Doc", "Name": "A", "Excluded": false}},
+			{Name: "B", Attributes: bson.M{"$Type": "Test$Doc", "Name": "B", "Excluded": false}},
+			{Name: "C", Attributes: bson.M{"$Type": "Test$Doc", "Name": "C", "Excluded": true}},
+		}
+		defaults := inferTypeDefaults(documents, true, nil)
+
+		result := removeTypeDefaults(cleanData(documents[0].Attributes, true, nil), defaults).(bson.M)
+		if _, ok := result["Excluded"]; ok {
+			t.Errorf("Default attribute should be removed")
+		}
+		if result["Name"] != "A" {
+			t.Errorf("Name should be kept. Got: %v", result["Name"])
+		}
+
+		result = removeTypeDefaults(cleanData(documents[2].Attributes, true, nil), defaults).(bson.M)
+		if result["Excluded"] != true {
+			t.Errorf("Non-default attribute should be kept")
+		}
+	})
+
+	t.Run("main-function", func(t *testing.T) {
+		node := func(name string, disabled bool) map[string]interface{} {
+			return map[string]interface{}{"$Type": "Microflows$ActionActivity", "Caption": name, "Disabled": disabled}
+		}
+		documents := []MxDocument{
+			{Name: "A", Attributes: bson.M{"$Type": "Microflows$Microflow", "Name": "A", "MainFunction": []map[string]interface{}{node("a1", false), node("a2", false)}}},
+			{Name: "B", Attributes: bson.M{"$Type": "Microflows$Microflow", "Name": "B", "MainFunction": []map[string]interface{}{node("b1", true)}}},
+		}
+		defaults := inferTypeDefaults(documents, true, nil)
+
+		result := removeTypeDefaults(cleanData(documents[0].Attributes, true, nil), defaults).(bson.M)
+		nodes, ok := result["MainFunction"].([]map[string]interface{})
+		if !ok || len(nodes) != 2 {
+			t.Fatalf("Expected the main function to be kept. Got: %v", result["MainFunction"])
+		}
+		if _, ok := nodes[0]["Disabled"]; ok {
+			t.Errorf("Default attribute of a main function node should be removed")
+		}
+		if nodes[0]["Caption"] != "a1" {
+			t.Errorf("Caption should be kept. Got: %v", nodes[0]["Caption"])
+		}
+
+		result = removeTypeDefaults(cleanData(documents[1].Attributes, true, nil), defaults).(bson.M)
+		nodes = result["MainFunction"].([]map[string]interface{})
+		if nodes[0]["Disabled"] != true {
+			t.Errorf("Non-default attribute of a main function node should be kept")
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		documents := []MxDocument{
+			{Name: "A", Attributes: bson.M{"$Type": "Test$Doc", "Name": "A", "Excluded": false, "Items": bson.A{bson.M{"$Type": "Test$Item", "Width": int32(1)}}}},
+			{Name: "B", Attributes: bson.M{"$Type": "Test$Doc", "Name": "B", "Excluded": false, "Items": bson.A{bson.M{"$Type": "Test$Item", "Width": int32(1)}}}},
+			{Name: "C", Attributes: bson.M{"$Type": "Test$Doc", "Name": "C", "Excluded": true, "Optional": "x"}},
+		}
+		defaults := inferTypeDefaults(documents, true, nil)
+		if _, ok := defaults["Test$Doc"]["Optional"]; ok {
+			t.Errorf("Expected no default for an attribute that not every object has")
+		}
+		for _, document := range documents {
+			expected := cleanData(document.Attributes, true, nil)
+			result := removeTypeDefaults(expected, defaults)
+			restoreTypeDefaults(result, defaults)
+			if fmt.Sprint(result) != fmt.Sprint(expected) {
+				t.Errorf("Expected the restored document to equal the original. Got: %v, expected %v", result, expected)
+			}
+		}
+	})
+
+	t.Run("export-round-trip", func(t *testing.T) {
+		os.RemoveAll("./../tmp/delta")
+		os.RemoveAll("./../tmp/delta-full")
+		if err := ExportModel("./../resources/app", "./../tmp/delta", ExportOptions{Mode: "basic", Format: "json", Delta: true}); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		if err := ExportModel("./../resources/app", "./../tmp/delta-full", ExportOptions{Mode: "basic", Format: "json"}); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		var table struct {
+			Types map[string]map[string]interface{}
+		}
+		contents, err := os.ReadFile("./../tmp/delta/defaults.json")
+		if err != nil {
+			t.Fatalf("Failed to read defaults: %v", err)
+		}
+		if err := json.Unmarshal(contents, &table); err != nil || len(table.Types) == 0 {
+			t.Fatalf("Expected defaults per type: %v", err)
+		}
+		compared := 0
+		err = filepath.Walk("./../tmp/delta-full", func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.Contains(info.Name(), "$") {
+				return err
+			}
+			relativePath, _ := filepath.Rel("./../tmp/delta-full", path)
+			var full, delta map[string]interface{}
+			if err := readJSONFile(path, &full); err != nil {
+				return err
+			}
+			if err := readJSONFile(filepath.Join("./../tmp/delta", relativePath), &delta); err != nil {
+				return err
+			}
+			restoreTypeDefaults(delta, table.Types)
+			if fmt.Sprint(delta) != fmt.Sprint(full) {
+				t.Errorf("Expected %s to be restored from the defaults", relativePath)
+			}
+			compared++
+			return nil
+		})
+		if err != nil || compared == 0 {
+			t.Errorf("Expected documents to compare: %v", err)
+		}
+	})
+}
+
+func readJSONFile(path string, value interface{}) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, value)
+}
+
+func TestMPRContentStore(t *testing.T) {
+	t.Run("manifest", func(t *testing.T) {
+		options := ExportOptions{Mode: "basic", ContentStore: "./../tmp/objects"}
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp/snapshot-1", options); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp/snapshot-2", options); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+
+		var manifests [2]map[string]string
+		for i, snapshot := range []string{"snapshot-1", "snapshot-2"} {
+			manifestFile, err := os.ReadFile("./../tmp/" + snapshot + "/manifest.yaml")
+			if err != nil {
+				t.Fatalf("Failed to read manifest file: %v", err)
+			}
+			if err := yaml.Unmarshal(manifestFile, &manifests[i]); err != nil {
+				t.Fatalf("Failed to unmarshal manifest file: %v", err)
+			}
+		}
+
+		hash, ok := manifests[0]["MyFirstModule/DomainModels$DomainModel.yaml"]
+		if !ok {
+			t.Fatalf("Expected domain model in manifest")
+		}
+		if manifests[1]["MyFirstModule/DomainModels$DomainModel.yaml"] != hash {
+			t.Errorf("Expected unchanged document to have the same hash")
+		}
+		if _, err := os.Stat("./../tmp/objects/" + hash[:2] + "/" + hash[2:]); err != nil {
+			t.Errorf("Expected object to be stored: %v", err)
+		}
+	})
+}
+
+func TestMPRCatalog(t *testing.T) {
+	t.Run("headers", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp/catalog", ExportOptions{Mode: "headers"}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		catalogFile, err := os.ReadFile("./../tmp/catalog/Catalog.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read catalog file: %v", err)
+		}
+		var catalog struct {
+			Documents []map[string]string `yaml:"Documents"`
+		}
+		if err := yaml.Unmarshal(catalogFile, &catalog); err != nil {
+			t.Fatalf("Failed to unmarshal catalog file: %v", err)
+		}
+		found := false
+		for _, record := range catalog.Documents {
+			if record["Name"] == "MyFirstModule.MicroflowSimple" {
+				found = record["Type"] == "Microflows$Microflow" && record["Path"] == "MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml"
+			}
+		}
+		if !found {
+			t.Errorf("Expected catalog record for MyFirstModule.MicroflowSimple")
+		}
+		if _, err := os.Stat("./../tmp/catalog/MyFirstModule"); !os.IsNotExist(err) {
+			t.Errorf("Expected no document files in headers mode")
+		}
+	})
+}
+
+func TestMPRManifest(t *testing.T) {
+	t.Run("documents", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp/manifest", ExportOptions{Mode: "basic"}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		manifestFile, err := os.ReadFile("./../tmp/manifest/manifest.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read manifest file: %v", err)
+		}
+		var manifest struct {
+			Source    string              `yaml:"Source"`
+			Count     int                 `yaml:"Count"`
+			Documents []map[string]string `yaml:"Documents"`
+		}
+		if err := yaml.Unmarshal(manifestFile, &manifest); err != nil {
+			t.Fatalf("Failed to unmarshal manifest file: %v", err)
+		}
+		if manifest.Source != "App.mpr" {
+			t.Errorf("Expected source App.mpr, got %s", manifest.Source)
+		}
+		if manifest.Count == 0 || manifest.Count != len(manifest.Documents) {
+			t.Errorf("Expected count to match %d documents, got %d", len(manifest.Documents), manifest.Count)
+		}
+		found := false
+		for _, entry := range manifest.Documents {
+			if entry["Name"] == "MicroflowSimple" {
+				found = entry["Path"] == "MyFirstModule/Folder" && entry["File"] == "MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml"
+			}
+		}
+		if !found {
+			t.Errorf("Expected manifest entry for MicroflowSimple")
+		}
+	})
+}
+
+func TestMPRFlatModuleLayout(t *testing.T) {
+	t.Run("flat-module", func(t *testing.T) {
+		outputDirectory := "./../tmp/flat"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", Layout: "flat-module"}); err != nil {
+			t.Fatalf("Failed to export units from MPR file: %v", err)
+		}
+		moduleFile, err := os.ReadFile(filepath.Join(outputDirectory, "MyFirstModule.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read module file: %v", err)
+		}
+		var documents []map[string]interface{}
+		if err := yaml.Unmarshal(moduleFile, &documents); err != nil {
+			t.Fatalf("Failed to unmarshal module file: %v", err)
+		}
+		found := false
+		for _, document := range documents {
+			if document["Name"] == "MicroflowSimple" {
+				found = document["Path"] == "MyFirstModule/Folder" && document["Attributes"] != nil
+			}
+		}
+		if !found {
+			t.Errorf("Expected MicroflowSimple with its path in the module file")
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule/Folder")); !os.IsNotExist(err) {
+			t.Errorf("Expected no per document files")
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "Project.yaml")); err != nil {
+			t.Errorf("Expected project documents to be written: %v", err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		outputDirectory := "./../tmp/flat-invalid"
+		os.RemoveAll(outputDirectory)
+		invalid := map[string]ExportOptions{
+			"layout":        {Mode: "basic", Layout: "nested"},
+			"large strings": {Mode: "basic", LargeStrings: "drop"},
+			"diagrams":      {Mode: "basic", EmitDiagrams: true},
+		}
+		for name, options := range invalid {
+			if err := ExportModel("./../resources/app", outputDirectory, options); err == nil {
+				t.Errorf("Expected error for invalid %s", name)
+			}
+		}
+		// the options are rejected before any mpr file is read
+		if _, err := os.Stat(outputDirectory); !os.IsNotExist(err) {
+			t.Errorf("Expected no output for invalid options")
+		}
+	})
+}
+
+func TestMPRByTypeLayout(t *testing.T) {
+	t.Run("by-type", func(t *testing.T) {
+		outputDirectory := "./../tmp/by-type"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", Layout: "by-type"}); err != nil {
+			t.Fatalf("Failed to export units from MPR file: %v", err)
+		}
+		for _, path := range []string{
+			"Microflows$Microflow/MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml",
+			"DomainModels$DomainModel/MyFirstModule/DomainModels$DomainModel.yaml",
+			"Security$ProjectSecurity/Security$ProjectSecurity.yaml",
+		} {
+			if _, err := os.Stat(filepath.Join(outputDirectory, path)); err != nil {
+				t.Errorf("Expected %s to be written: %v", path, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule/Folder")); !os.IsNotExist(err) {
+			t.Errorf("Expected no tree layout")
+		}
+	})
+}
+
+func TestMPRLinks(t *testing.T) {
+	t.Run("single-mpr", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Mode: "basic", Links: true}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		linksFile, err := os.ReadFile("./../tmp/links.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read links file: %v", err)
+		}
+		var links struct {
+			Links []map[string]string `yaml:"Links"`
+		}
+		if err := yaml.Unmarshal(linksFile, &links); err != nil {
+			t.Fatalf("Failed to unmarshal links file: %v", err)
+		}
+		if len(links.Links) == 0 {
+			t.Fatalf("Expected links")
+		}
+		for _, link := range links.Links {
+			if link["Source"] == "" || link["Field"] == "" || link["Target"] == "" {
+				t.Errorf("Incomplete link: %v", link)
+				break
+			}
+		}
+	})
+}
+
+func TestMPRPublicAPI(t *testing.T) {
+	t.Run("community-commons", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Mode: "basic", PublicAPI: true}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		apiFile, err := os.ReadFile("./../tmp/CommunityCommons/PublicAPI.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read public api file: %v", err)
+		}
+		var api struct {
+			JavaActions []string `yaml:"JavaActions"`
+			Entities    []string `yaml:"Entities"`
+		}
+		if err := yaml.Unmarshal(apiFile, &api); err != nil {
+			t.Fatalf("Failed to unmarshal public api file: %v", err)
+		}
+		found := false
+		for _, javaAction := range api.JavaActions {
+			found = found || javaAction == "CommunityCommons.YearsBetween"
+		}
+		if !found {
+			t.Errorf("Expected CommunityCommons.YearsBetween in public api. Got: %v", api.JavaActions)
+		}
+		if len(api.Entities) == 0 {
+			t.Errorf("Expected entities of source module in public api")
+		}
+	})
+}
+
+func TestMPRModuleFilter(t *testing.T) {
+	t.Run("match", func(t *testing.T) {
+		filter := []string{"myfirstmodule", "Atlas_*"}
+		for module, expected := range map[string]bool{"MyFirstModule": true, "Atlas_Core": true, "atlas_web_content": true, "Administration": false, "": false} {
+			if matchesModuleFilter(module, filter) != expected {
+				t.Errorf("Unexpected match for %s", module)
+			}
+		}
+		if !matchesModuleFilter("Administration", nil) {
+			t.Errorf("Empty filter should match every module")
+		}
+	})
+	t.Run("export", func(t *testing.T) {
+		options := ExportOptions{Mode: "basic", Modules: []string{"MyFirst*"}}
+		if _, err := exportMPR(context.Background(), "./../resources/app/App.mpr", NewLocalSink("./../tmp/filtered"), options); err != nil {
+			t.Errorf("Failed to export MPR file: %v", err)
+		}
+		if _, err := os.Stat("./../tmp/filtered/MyFirstModule/DomainModels$DomainModel.yaml"); err != nil {
+			t.Errorf("Expected selected module to be exported: %v", err)
+		}
+		if _, err := os.Stat("./../tmp/filtered/Administration"); !os.IsNotExist(err) {
+			t.Errorf("Expected other modules to be skipped")
+		}
+		metadataFile, err := os.ReadFile("./../tmp/filtered/Metadata.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read metadata file: %v", err)
+		}
+		var metadataObj MxMetadata
+		if err := yaml.Unmarshal(metadataFile, &metadataObj); err != nil {
+			t.Fatalf("Failed to unmarshal metadata file")
+		}
+		for _, module := range metadataObj.Modules {
+			if module.Exported != (module.Name == "MyFirstModule") {
+				t.Errorf("Unexpected exported flag for %s", module.Name)
+			}
+		}
+	})
+}
+
+func TestMPRSanitizeFilename(t *testing.T) {
+	t.Run("names", func(t *testing.T) {
+		for name, expected := range map[string]string{"ACT_Save": "ACT_Save", "Orders/Lines": "Orders_Lines", `a\b:c`: "a_b_c", "tab\there": "tab_here", "..": "__", ".": "."} {
+			if sanitized := sanitizeFilename(name); sanitized != expected {
+				t.Errorf("Expected %s for %q, got %s", expected, name, sanitized)
+			}
+		}
+	})
+	t.Run("document and folder", func(t *testing.T) {
+		root := MxFolder{ID: "root", Name: "."}
+		module := MxFolder{ID: "module", Name: "MyFirstModule", Parent: &root}
+		folder := MxFolder{ID: "folder", Name: "In/Out", Parent: &module}
+		paths := getMxFolderPaths([]MxFolder{root, module, folder}, log)
+		if paths["folder"] != filepath.Join("MyFirstModule", "In_Out") {
+			t.Errorf("Expected sanitized folder path, got %s", paths["folder"])
+		}
+		if paths["root"] != "" {
+			t.Errorf("Expected root folder to be elided, got %s", paths["root"])
+		}
+		document := MxDocument{Name: "Import/Export", Type: "Microflows$Microflow"}
+		if fname := getMxDocumentFileName(document, "yaml"); fname != "Import_Export.Microflows$Microflow.yaml" {
+			t.Errorf("Expected sanitized file name, got %s", fname)
+		}
+	})
+}
+
+func TestMPRExcludeTypes(t *testing.T) {
+	t.Run("match", func(t *testing.T) {
+		filter := []string{"Projects$ModuleSettings", "Microflows"}
+		for documentType, expected := range map[string]bool{"Projects$ModuleSettings": true, "Microflows$Microflow": true, "Microflows$Rule": true, "MicroflowsX$Microflow": false, "Forms$Page": false} {
+			if matchesTypeFilter(documentType, filter) != expected {
+				t.Errorf("Unexpected match for %s", documentType)
+			}
+		}
+	})
+	t.Run("export", func(t *testing.T) {
+		outputDirectory := "./../tmp/exclude-types"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", ExcludeTypes: []string{"Projects$ModuleSettings"}}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule/Projects$ModuleSettings.yaml")); !os.IsNotExist(err) {
+			t.Errorf("Expected module settings to be skipped")
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule/DomainModels$DomainModel.yaml")); err != nil {
+			t.Errorf("Expected domain model to be exported: %v", err)
+		}
+	})
+}
+
+func TestMPRNameFilter(t *testing.T) {
+	t.Run("export", func(t *testing.T) {
+		outputDirectory := "./../tmp/name-filter"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", NameFilter: "^Microflow"}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml")); err != nil {
+			t.Errorf("Expected matching microflow to be exported: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule/DomainModels$DomainModel.yaml")); !os.IsNotExist(err) {
+			t.Errorf("Expected domain model to be skipped")
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		outputDirectory := "./../tmp/name-filter-invalid"
+		os.RemoveAll(outputDirectory)
+		if err := ExportModel("./../resources/app", outputDirectory, ExportOptions{Mode: "basic", NameFilter: "("}); err == nil {
+			t.Errorf("Expected error for invalid name filter")
+		}
+		if _, err := os.Stat(outputDirectory); !os.IsNotExist(err) {
+			t.Errorf("Expected nothing to be written")
+		}
+	})
+}
+
+func TestMPRIncludeContainments(t *testing.T) {
+	file, err := readMPRFile(context.Background(), "./../resources/app/App.mpr", ExportOptions{})
+	if err != nil {
+		t.Fatalf("Failed to read MPR file: %v", err)
+	}
+	folders, err := getMxFolders(file.Units, file.Version, ExportOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get folders: %v", err)
+	}
+	t.Run("augment", func(t *testing.T) {
+		defaults, err := getMxDocuments(file.Units, getMxFolderPaths(folders, log), file.Version, ExportOptions{Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to get documents: %v", err)
+		}
+		documents, err := getMxDocuments(file.Units, getMxFolderPaths(folders, log), file.Version, ExportOptions{Mode: "basic", IncludeContainments: []string{"ProjectConversion"}})
+		if err != nil {
+			t.Fatalf("Failed to get documents: %v", err)
+		}
+		if len(documents) <= len(defaults) {
+			t.Errorf("Expected more than %d documents, got %d", len(defaults), len(documents))
+		}
+	})
+	t.Run("replace", func(t *testing.T) {
+		documents, err := getMxDocuments(file.Units, getMxFolderPaths(folders, log), file.Version, ExportOptions{Mode: "basic", IncludeContainments: []string{"DomainModel"}, ReplaceContainments: true})
+		if err != nil {
+			t.Fatalf("Failed to get documents: %v", err)
+		}
+		if len(documents) == 0 {
+			t.Errorf("Expected domain models to be exported")
+		}
+		for _, document := range documents {
+			if document.Type != "DomainModels$DomainModel" {
+				t.Errorf("Expected only domain models, got %s", document.Type)
+			}
+		}
+	})
+}
+
+func TestMPRFolderPaths(t *testing.T) {
+	t.Run("deep-hierarchy", func(t *testing.T) {
+		folders := make([]MxFolder, 12)
+		for i := range folders {
+			folders[i] = MxFolder{ID: fmt.Sprintf("f%d", i), Name: fmt.Sprintf("Level%d", i)}
+		}
+		for i := 1; i < len(folders); i++ {
+			folders[i].Parent = &folders[i-1]
+		}
+		paths := getMxFolderPaths(folders, log)
+		expected := filepath.Join("Level0", "Level1", "Level2", "Level3", "Level4", "Level5", "Level6", "Level7", "Level8", "Level9", "Level10", "Level11")
+		if paths["f11"] != expected {
+			t.Errorf("Unexpected path. Expected: %s, Got: %s", expected, paths["f11"])
+		}
+	})
+	t.Run("cycle", func(t *testing.T) {
+		folders := []MxFolder{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}}
+		folders[0].Parent = &folders[1]
+		folders[1].Parent = &folders[0]
+		paths := getMxFolderPaths(folders, log)
+		if paths["a"] != filepath.Join("B", "A") {
+			t.Errorf("Unexpected path for folder in cycle. Got: %s", paths["a"])
+		}
+	})
+	t.Run("unresolved-parent", func(t *testing.T) {
+		units := []MxUnit{
+			{UnitID: "root", ContainerID: "root", ContainmentName: "", Contents: map[string]interface{}{"$Type": "Projects$Project"}},
+			{UnitID: "module", ContainerID: "root", ContainmentName: "Modules", Contents: map[string]interface{}{"$Type": "Projects$ModuleImpl", "Name": "MyFirstModule"}},
+			{UnitID: "folder", ContainerID: "missing", ContainmentName: "Folders", Contents: map[string]interface{}{"$Type": "Projects$Folder", "Name": "Orphan"}},
+		}
+		if _, err := getMxFolders(units, MxVersion{}, ExportOptions{}); err != nil {
+			t.Errorf("Expected only a warning, got %v", err)
+		}
+		if _, err := getMxFolders(units, MxVersion{}, ExportOptions{StrictFolders: true}); err == nil || !strings.Contains(err.Error(), "folder") {
+			t.Errorf("Expected error for unresolved parent, got %v", err)
+		}
+		if _, err := getMxFolders(units[:2], MxVersion{}, ExportOptions{StrictFolders: true}); err != nil {
+			t.Errorf("Expected resolved folders to pass, got %v", err)
+		}
+	})
+	t.Run("root-folder-name", func(t *testing.T) {
+		outputDirectory := "./../tmp/root-folder-name"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", RootFolderName: "project"}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "project/MyFirstModule/DomainModels$DomainModel.yaml")); err != nil {
+			t.Errorf("Expected documents below the root folder: %v", err)
+		}
+	})
+	t.Run("root-folder-name-modules", func(t *testing.T) {
+		outputDirectory := "./../tmp/root-folder-name-modules"
+		os.RemoveAll(outputDirectory)
+		options := ExportOptions{Mode: "basic", RootFolderName: "App", Modules: []string{"MyFirstModule", "Administration"}, PublicAPI: true}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		for _, name := range []string{
+			"App/MyFirstModule/DomainModels$DomainModel.yaml",
+			"App/MyFirstModule/Module.yaml",
+			"App/MyFirstModule/PublicAPI.yaml",
+			"App/Administration/PublicAPI.yaml",
+		} {
+			if _, err := os.Stat(filepath.Join(outputDirectory, name)); err != nil {
+				t.Errorf("Expected %s: %v", name, err)
+			}
+		}
+		for _, name := range []string{"App/PublicAPI.yaml", "MyFirstModule", "App/Atlas_Core"} {
+			if _, err := os.Stat(filepath.Join(outputDirectory, name)); err == nil {
+				t.Errorf("Expected no %s", name)
+			}
+		}
+		manifest, err := os.ReadFile(filepath.Join(outputDirectory, "manifest.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read manifest: %v", err)
+		}
+		if !strings.Contains(string(manifest), "File: App/MyFirstModule/DomainModels$DomainModel.yaml") {
+			t.Errorf("Expected the manifest to list the files below the root folder")
+		}
+	})
+}
+
+func TestMPRMultipleFiles(t *testing.T) {
+	data, err := os.ReadFile("./../resources/app/App.mpr")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	for _, app := range []string{"shop", "crm"} {
+		if err := os.MkdirAll("./../tmp/apps/"+app, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile("./../tmp/apps/"+app+"/App.mpr", data, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	t.Run("per-file-subdir", func(t *testing.T) {
+		os.RemoveAll("./../tmp/apps-export")
+		if err := ExportModel("./../tmp/apps", "./../tmp/apps-export", ExportOptions{Mode: "basic"}); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		for _, app := range []string{"shop", "crm"} {
+			if _, err := os.Stat("./../tmp/apps-export/" + app + "/App/Metadata.yaml"); err != nil {
+				t.Errorf("Expected metadata of %s: %v", app, err)
+			}
+		}
+	})
+	t.Run("file-workers", func(t *testing.T) {
+		os.RemoveAll("./../tmp/apps-concurrent")
+		options := ExportOptions{Mode: "basic", FileWorkers: 2, Incremental: true}
+		if err := ExportModel("./../tmp/apps", "./../tmp/apps-concurrent", options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		for _, app := range []string{"shop", "crm"} {
+			if _, err := os.Stat("./../tmp/apps-concurrent/" + app + "/App/MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml"); err != nil {
+				t.Errorf("Expected documents of %s: %v", app, err)
+			}
+		}
+		state := readExportState(NewLocalSink("./../tmp/apps-concurrent"), "yaml", log)
+		if len(state.Files) != 2 {
+			t.Errorf("Expected both files in the export state. Got: %v", state.Files)
+		}
+	})
+	t.Run("file-workers-progress", func(t *testing.T) {
+		// the callback is not synchronized, the export reports one document at a time
+		calls, completed, running := 0, 0, false
+		options := ExportOptions{Mode: "basic", FileWorkers: 2, Workers: 4, Progress: func(done, total int) {
+			if running {
+				t.Errorf("Expected progress calls not to overlap")
+			}
+			running = true
+			calls++
+			if done == total {
+				completed++
+			}
+			time.Sleep(time.Microsecond)
+			running = false
+		}}
+		if err := ExportModel("./../tmp/apps", "./../tmp/apps-progress", options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		if completed != 2 || calls == 0 {
+			t.Errorf("Expected progress of both files. Got %d calls completing %d files", calls, completed)
+		}
+	})
+	t.Run("merge-metadata-archive", func(t *testing.T) {
+		archivePath := "./../tmp/apps-merged.tar.gz"
+		options := ExportOptions{Mode: "basic", Archive: true, MergeMetadata: true}
+		if err := ExportModel("./../tmp/apps", archivePath, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		file, err := os.Open(archivePath)
+		if err != nil {
+			t.Fatalf("Failed to open archive: %v", err)
+		}
+		defer file.Close()
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		metadata := 0
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Failed to read archive: %v", err)
+			}
+			if header.Name == "Metadata.yaml" {
+				metadata++
+			}
+		}
+		if metadata != 1 {
+			t.Errorf("Expected the merged metadata once in archive. Got: %d", metadata)
+		}
+	})
+	t.Run("merge-metadata", func(t *testing.T) {
+		os.RemoveAll("./../tmp/apps-merged")
+		if err := ExportModel("./../tmp/apps", "./../tmp/apps-merged", ExportOptions{Mode: "basic", MergeMetadata: true}); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		metadataFile, err := os.ReadFile("./../tmp/apps-merged/Metadata.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read metadata file: %v", err)
+		}
+		var metadata map[string]MxMetadata
+		if err := yaml.Unmarshal(metadataFile, &metadata); err != nil {
+			t.Fatalf("Failed to unmarshal metadata file: %v", err)
+		}
+		for _, app := range []string{"shop/App", "crm/App"} {
+			if metadata[app].ProductVersion == "" {
+				t.Errorf("Expected metadata of %s", app)
+			}
+		}
+	})
+}
+
+func TestMPRExportErrors(t *testing.T) {
+	if err := os.MkdirAll("./../tmp/broken/b", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile("./../tmp/broken/a.mpr", []byte("not a database"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	data, err := os.ReadFile("./../resources/app/App.mpr")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if err := os.WriteFile("./../tmp/broken/b/App.mpr", data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	t.Run("abort", func(t *testing.T) {
+		os.RemoveAll("./../tmp/broken-abort")
+		if err := ExportModel("./../tmp/broken", "./../tmp/broken-abort", ExportOptions{Mode: "basic"}); err == nil {
+			t.Errorf("Expected error for corrupt mpr file")
+		}
+		if _, err := os.Stat("./../tmp/broken-abort/b/App/MyFirstModule"); !os.IsNotExist(err) {
+			t.Errorf("Expected export to stop at the corrupt file")
+		}
+	})
+	t.Run("continue-on-error", func(t *testing.T) {
+		if err := ExportModel("./../tmp/broken", "./../tmp/broken-continue", ExportOptions{Mode: "basic", ContinueOnError: true}); err == nil {
+			t.Errorf("Expected aggregate error for corrupt mpr file")
+		}
+		if _, err := os.Stat("./../tmp/broken-continue/b/App/MyFirstModule"); err != nil {
+			t.Errorf("Expected remaining mpr files to be exported: %v", err)
+		}
+	})
+	t.Run("file-workers", func(t *testing.T) {
+		err := ExportModel("./../tmp/broken", "./../tmp/broken-concurrent", ExportOptions{Mode: "basic", ContinueOnError: true, FileWorkers: 2})
+		if err == nil || !strings.Contains(err.Error(), "a.mpr") {
+			t.Errorf("Expected aggregate error for corrupt mpr file, got %v", err)
+		}
+		if _, err := os.Stat("./../tmp/broken-concurrent/b/App/MyFirstModule"); err != nil {
+			t.Errorf("Expected remaining mpr files to be exported: %v", err)
+		}
+	})
+}
+
+func TestMPRMalformedUnits(t *testing.T) {
+	units := []MxUnit{
+		{UnitID: "root", ContainerID: "root", ContainmentName: "", Contents: map[string]interface{}{}},
+		{UnitID: "mod/ule", ContainerID: "root", ContainmentName: "Modules", Contents: map[string]interface{}{"Name": nil}},
+		{UnitID: "folder", ContainerID: "mod/ule", ContainmentName: "Folders", Contents: map[string]interface{}{"Name": int32(1)}},
+		{UnitID: "flow", ContainerID: "folder", ContainmentName: "Documents", Contents: map[string]interface{}{"$Type": "Microflows$Microflow", "Name": true}},
+		{UnitID: "untyped", ContainerID: "folder", ContainmentName: "Documents", Contents: map[string]interface{}{"Name": "Untyped"}},
+	}
+	t.Run("modules", func(t *testing.T) {
+		modules := getMxModules(units, MxVersion{}, nil, log)
+		if len(modules) != 1 || modules[0].Name != "mod_ule" {
+			t.Errorf("Expected module named after its ID, got %v", modules)
+		}
+	})
+	t.Run("documents", func(t *testing.T) {
+		folders, err := getMxFolders(units, MxVersion{}, ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get folders: %v", err)
+		}
+		documents, err := getMxDocuments(units, getMxFolderPaths(folders, log), MxVersion{}, ExportOptions{Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to get documents: %v", err)
+		}
+		if len(documents) != 1 || documents[0].Name != "" || documents[0].Path != filepath.Join("mod_ule", "folder") {
+			t.Errorf("Expected only the typed document without name, got %v", documents)
+		}
+	})
+}
+
+func TestMPRExportStats(t *testing.T) {
+	t.Run("counts", func(t *testing.T) {
+		stats, err := exportMPR(context.Background(), "./../resources/app/App.mpr", NewLocalSink("./../tmp/stats"), ExportOptions{Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to export MPR file: %v", err)
+		}
+		if stats.Modules == 0 || stats.Folders == 0 || stats.Documents == 0 {
+			t.Errorf("Expected modules, folders and documents to be counted. Got: %+v", stats)
+		}
+		total := 0
+		for _, count := range stats.DocumentTypes {
+			total += count
+		}
+		if total != stats.Documents || stats.DocumentTypes["Microflows$Microflow"] == 0 {
+			t.Errorf("Expected documents by type to add up to %d, got %d", stats.Documents, total)
+		}
+		if len(stats.Warnings) != 0 {
+			t.Errorf("Expected no warnings, got %v", stats.Warnings)
+		}
+	})
+	t.Run("warnings", func(t *testing.T) {
+		file := mprFile{Units: []MxUnit{
+			{UnitID: "root", ContainerID: "root", ContainmentName: "", Contents: map[string]interface{}{}},
+			{UnitID: "flow", ContainerID: "missing", ContainmentName: "Documents", Contents: map[string]interface{}{"$Type": "Microflows$Microflow"}},
+		}}
+		folders, err := getMxFolders(file.Units, file.Version, ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get folders: %v", err)
+		}
+		stats := getExportStats(file, folders, getMxFolderPaths(folders, log), nil, ExportOptions{})
+		if len(stats.Warnings) != 2 {
+			t.Errorf("Expected unresolved folder and missing name warnings, got %v", stats.Warnings)
+		}
+	})
+}
+
+func TestMPRCancel(t *testing.T) {
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := ExportModelContext(ctx, "./../resources/app", "./../tmp/cancelled", ExportOptions{Mode: "basic"})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+	t.Run("cancelled during export", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		options := ExportOptions{Mode: "basic", Workers: 1, Progress: func(done, total int) {
+			if done == 10 {
+				cancel()
+			}
+		}}
+		file, err := readMPRFile(context.Background(), "./../resources/app/App.mpr", options)
+		if err != nil {
+			t.Fatalf("Failed to read MPR file: %v", err)
+		}
+		if err := exportUnits(ctx, file, NewLocalSink("./../tmp/cancelled"), options, &ExportStats{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestMPRInvalidFiles(t *testing.T) {
+	if err := os.MkdirAll("./../tmp", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	t.Run("not sqlite", func(t *testing.T) {
+		if err := os.WriteFile("./../tmp/corrupt.mpr", []byte("not a database"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		_, err := readMPRFile(context.Background(), "./../tmp/corrupt.mpr", ExportOptions{})
+		if err == nil || !strings.Contains(err.Error(), "not a SQLite database") {
+			t.Errorf("Expected not a SQLite database error, got %v", err)
+		}
+	})
+	t.Run("unexpected schema", func(t *testing.T) {
+		os.Remove("./../tmp/schema.mpr")
+		db, err := sql.Open("sqlite", "./../tmp/schema.mpr")
+		if err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		if _, err := db.Exec("CREATE TABLE Unit (UnitID BLOB)"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		db.Close()
+		_, err = readMPRFile(context.Background(), "./../tmp/schema.mpr", ExportOptions{})
+		if err == nil || !strings.Contains(err.Error(), "missing tables _MetaData") {
+			t.Errorf("Expected missing tables error, got %v", err)
+		}
+	})
+}
+
+func TestMPRDryRun(t *testing.T) {
+	t.Run("nothing is written", func(t *testing.T) {
+		outputDirectory := "./../tmp/dry-run"
+		os.RemoveAll(outputDirectory)
+		if err := ExportModel("./../resources/app", outputDirectory, ExportOptions{Mode: "advanced", DryRun: true}); err != nil {
+			t.Errorf("Failed to export model: %v", err)
+		}
+		if _, err := os.Stat(outputDirectory); !os.IsNotExist(err) {
+			t.Errorf("Expected output directory not to be created")
+		}
+	})
+	t.Run("layout", func(t *testing.T) {
+		logger := &recordingLogger{}
+		options := ExportOptions{Mode: "basic", DryRun: true, Layout: "by-type", ModuleDirTemplate: "{{.Name}}-{{.Source}}", Logger: logger}
+		if err := ExportModel("./../resources/app", "./../tmp/dry-run-layout", options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		expected := "Would write Microflows$Microflow/MyFirstModule-custom/Folder/MicroflowSimple.Microflows$Microflow.yaml"
+		if !strings.Contains(strings.Join(logger.infos, "\n"), expected) {
+			t.Errorf("Expected the file of the layout and module directory. Got: %v", logger.infos)
+		}
+	})
+}
+
+// recordingLogger keeps the info messages it receives
+type recordingLogger struct {
+	noopLogger
+	lock  sync.Mutex
+	infos []string
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.infos = append(l.infos, fmt.Sprintf(format, args...))
+}
+
+func TestMPRSingleFileInput(t *testing.T) {
+	t.Run("mpr file", func(t *testing.T) {
+		outputDirectory := "./../tmp/single-file"
+		os.RemoveAll(outputDirectory)
+		if err := ExportModel("./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic"}); err != nil {
+			t.Errorf("Failed to export model: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "Metadata.yaml")); err != nil {
+			t.Errorf("Expected metadata to be exported: %v", err)
+		}
+	})
+	t.Run("other file", func(t *testing.T) {
+		if err := os.MkdirAll("./../tmp", 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile("./../tmp/App.txt", []byte("not a model"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := ExportModel("./../tmp/App.txt", "./../tmp/single-file-invalid", ExportOptions{Mode: "basic"}); err == nil {
+			t.Errorf("Expected error for input that is not an mpr file")
+		}
+	})
+}
+
+func TestMPRArchive(t *testing.T) {
+	t.Run("tar.gz", func(t *testing.T) {
+		archivePath := "./../tmp/archive/modelsource.tar.gz"
+		os.RemoveAll(filepath.Dir(archivePath))
+		if err := ExportModel("./../resources/app/App.mpr", archivePath, ExportOptions{Mode: "basic", Archive: true}); err != nil {
+			t.Errorf("Failed to export model: %v", err)
+		}
+		file, err := os.Open(archivePath)
+		if err != nil {
+			t.Fatalf("Failed to open archive: %v", err)
+		}
+		defer file.Close()
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		names := make(map[string]bool)
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Failed to read archive: %v", err)
+			}
+			if names[header.Name] {
+				t.Errorf("Expected %s once in archive", header.Name)
+			}
+			names[header.Name] = true
+		}
+		for _, name := range []string{"Metadata.yaml", "MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml"} {
+			if !names[name] {
+				t.Errorf("Expected %s in archive", name)
+			}
+		}
+		entries, err := os.ReadDir(filepath.Dir(archivePath))
+		if err != nil || len(entries) != 1 {
+			t.Errorf("Expected only the archive in its directory. Got: %v", entries)
+		}
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		options := ExportOptions{Mode: "basic", Archive: true, Incremental: true}
+		if err := ExportModel("./../resources/app/App.mpr", "./../tmp/archive-incremental.tar.gz", options); err == nil {
+			t.Errorf("Expected error for incremental archive")
+		}
+	})
+}
+
+func TestMPRIncremental(t *testing.T) {
+	t.Run("unchanged files are skipped", func(t *testing.T) {
+		outputDirectory := "./../tmp/incremental"
+		os.RemoveAll(outputDirectory)
+		options := ExportOptions{Mode: "basic", Incremental: true}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "export-state.yaml")); err != nil {
+			t.Fatalf("Expected export state to be written: %v", err)
+		}
+		metadataFile := filepath.Join(outputDirectory, "Metadata.yaml")
+		if err := os.Remove(metadataFile); err != nil {
+			t.Fatalf("Failed to remove metadata file: %v", err)
+		}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		if _, err := os.Stat(metadataFile); !os.IsNotExist(err) {
+			t.Errorf("Expected unchanged mpr file to be skipped")
+		}
+		options.Incremental = false
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		if _, err := os.Stat(metadataFile); err != nil {
+			t.Errorf("Expected full export without incremental: %v", err)
+		}
+	})
+}
+
+func TestMPREntitiesSummary(t *testing.T) {
+	t.Run("entities", func(t *testing.T) {
+		outputDirectory := "./../tmp/entities-summary"
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", EntitiesSummary: true}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		summaryFile, err := os.ReadFile(filepath.Join(outputDirectory, "entities.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read entities summary: %v", err)
+		}
+		var summary struct {
+			Entities []struct {
+				Name           string              `yaml:"Name"`
+				Generalization string              `yaml:"Generalization"`
+				Attributes     []map[string]string `yaml:"Attributes"`
+			} `yaml:"Entities"`
+		}
+		if err := yaml.Unmarshal(summaryFile, &summary); err != nil {
+			t.Fatalf("Failed to unmarshal entities summary: %v", err)
+		}
+		found := false
+		for _, entity := range summary.Entities {
+			if entity.Name == "MyFirstModule.Photo" {
+				found = entity.Generalization == "System.Image"
+			}
+		}
+		if !found {
+			t.Errorf("Expected MyFirstModule.Photo with its generalization")
+		}
+	})
+}
+
+func TestMPRTree(t *testing.T) {
+	t.Run("folders", func(t *testing.T) {
+		outputDirectory := "./../tmp/tree"
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", EmitTree: true}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		treeFile, err := os.ReadFile(filepath.Join(outputDirectory, "tree.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read tree: %v", err)
+		}
+		type node struct {
+			Name    string `yaml:"Name"`
+			Module  bool   `yaml:"Module"`
+			Folders []node `yaml:"Folders"`
+		}
+		var tree struct {
+			Folders    []node `yaml:"Folders"`
+			Unresolved []node `yaml:"Unresolved"`
+		}
+		if err := yaml.Unmarshal(treeFile, &tree); err != nil {
+			t.Fatalf("Failed to unmarshal tree: %v", err)
+		}
+		found := false
+		for _, module := range tree.Folders {
+			if module.Name == "MyFirstModule" && module.Module {
+				for _, folder := range module.Folders {
+					found = found || folder.Name == "Folder"
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected MyFirstModule/Folder in tree")
+		}
+		if len(tree.Unresolved) != 0 {
+			t.Errorf("Expected all folders to be resolved, got %v", tree.Unresolved)
+		}
+	})
+}
+
+func TestMPRIncludeIDs(t *testing.T) {
+	t.Run("ids", func(t *testing.T) {
+		outputDirectory := "./../tmp/include-ids"
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", IncludeIDs: true}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		mfFile, err := os.ReadFile(filepath.Join(outputDirectory, "MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read microflow file: %v", err)
+		}
+		var mfObj map[string]interface{}
+		if err := yaml.Unmarshal(mfFile, &mfObj); err != nil {
+			t.Fatalf("Failed to unmarshal microflow file: %v", err)
+		}
+		for _, key := range []string{"_UnitID", "_ContainerID"} {
+			if id, ok := mfObj[key].(string); !ok || id == "" {
+				t.Errorf("Expected %s in document. Got: %v", key, mfObj[key])
+			}
+		}
+	})
+}
+
+func TestMPRDocumentOrder(t *testing.T) {
+	t.Run("sorted", func(t *testing.T) {
+		file, err := readMPRFile(context.Background(), "./../resources/app/App.mpr", ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to read MPR file: %v", err)
+		}
+		modules := getMxModules(file.Units, file.Version, nil, log)
+		for i := 1; i < len(modules); i++ {
+			if modules[i-1].Name > modules[i].Name {
+				t.Errorf("Expected modules sorted by name, got %s before %s", modules[i-1].Name, modules[i].Name)
+			}
+		}
+		// reversing the units must not change the order of the documents
+		reversed := make([]MxUnit, len(file.Units))
+		for i, unit := range file.Units {
+			reversed[len(file.Units)-1-i] = unit
+		}
+		folders, err := getMxFolders(file.Units, file.Version, ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get folders: %v", err)
+		}
+		documents, err := getMxDocuments(file.Units, getMxFolderPaths(folders, log), file.Version, ExportOptions{Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to get documents: %v", err)
+		}
+		reversedDocuments, err := getMxDocuments(reversed, getMxFolderPaths(folders, log), file.Version, ExportOptions{Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to get documents: %v", err)
+		}
+		for i := range documents {
+			if documents[i].Path != reversedDocuments[i].Path || documents[i].Name != reversedDocuments[i].Name || documents[i].Type != reversedDocuments[i].Type {
+				t.Fatalf("Expected the same order of documents, got %s/%s and %s/%s", documents[i].Path, documents[i].Name, reversedDocuments[i].Path, reversedDocuments[i].Name)
+			}
+		}
+	})
+}
+
+func TestMPRMaxFileBytes(t *testing.T) {
+	t.Run("split-large-documents", func(t *testing.T) {
+		outputDirectory := "./../tmp/max-file-bytes"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", MaxFileBytes: 2000}); err != nil {
+			t.Fatalf("Failed to export units from MPR file: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule/Folder/EnumerationStatus.Enumerations$Enumeration.yaml")); err != nil {
+			t.Errorf("Expected small document to be written as a single file: %v", err)
+		}
+
+		directory := filepath.Join(outputDirectory, "MyFirstModule/DomainModels$DomainModel")
+		indexFile, err := os.ReadFile(filepath.Join(directory, "index.yaml"))
+		if err != nil {
+			t.Fatalf("Expected index of the split domain model: %v", err)
+		}
+		var index struct {
+			Parts []struct {
+				File string   `yaml:"File"`
+				Keys []string `yaml:"Keys"`
+			} `yaml:"Parts"`
+		}
+		if err := yaml.Unmarshal(indexFile, &index); err != nil {
+			t.Fatalf("Failed to unmarshal index: %v", err)
+		}
+		if len(index.Parts) < 2 {
+			t.Fatalf("Expected multiple parts, got %d", len(index.Parts))
+		}
+		keys := make(map[string]bool)
+		for _, part := range index.Parts {
+			if _, err := os.Stat(filepath.Join(directory, part.File)); err != nil {
+				t.Errorf("Expected part %s to be written: %v", part.File, err)
+			}
+			for _, key := range part.Keys {
+				keys[key] = true
+			}
+		}
+		if !keys["$Type"] || !keys["Entities"] {
+			t.Errorf("Expected all attributes in the parts, got %v", keys)
+		}
+		if _, err := os.Stat(directory + ".yaml"); !os.IsNotExist(err) {
+			t.Errorf("Expected no single file for the split domain model")
+		}
+	})
+}
+
+func TestMPREmitBSON(t *testing.T) {
+	t.Run("bson-sidecar", func(t *testing.T) {
+		outputDirectory := "./../tmp/emit-bson"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "advanced", EmitBSON: true}); err != nil {
+			t.Fatalf("Failed to export units from MPR file: %v", err)
+		}
+		contents, err := os.ReadFile(filepath.Join(outputDirectory, "MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.bson"))
+		if err != nil {
+			t.Fatalf("Expected bson next to the microflow: %v", err)
+		}
+		var document bson.M
+		if err := bson.Unmarshal(contents, &document); err != nil {
+			t.Fatalf("Failed to unmarshal bson: %v", err)
+		}
+		if document["Name"] != "MicroflowSimple" {
+			t.Errorf("Unexpected name. Got: %v", document["Name"])
+		}
+		if _, ok := document["ObjectCollection"]; !ok {
+			t.Errorf("Expected the untransformed microflow")
+		}
+	})
+
+	t.Run("redacted", func(t *testing.T) {
+		for _, options := range []ExportOptions{
+			{Mode: "basic", EmitBSON: true, Redact: []string{"Password"}},
+			{Mode: "basic", EmitBSON: true, StripKeys: []string{"Documentation"}},
+		} {
+			if err := ExportModel("./../resources/app", "./../tmp/emit-bson-redacted", options); err == nil {
+				t.Errorf("Expected error for bson sidecars of %v", options)
+			}
+		}
+		options := ExportOptions{Mode: "basic", EmitBSON: true, StripKeys: DefaultStripKeys}
+		if err := ExportModel("./../resources/app", "./../tmp/emit-bson-default-strip-keys", options); err != nil {
+			t.Errorf("Failed to export with the default strip keys: %v", err)
+		}
+	})
+}
+
+func TestParseMPR(t *testing.T) {
+	t.Run("app", func(t *testing.T) {
+		units, err := ParseMPR("./../resources/app/App.mpr")
+		if err != nil {
+			t.Fatalf("Failed to parse MPR file: %v", err)
+		}
+		projects := 0
+		for _, unit := range units {
+			if unit.UnitID == "" || unit.Contents["$Type"] == nil {
+				t.Errorf("Expected unit with ID and contents. Got: %v", unit)
+			}
+			if unit.Contents["$Type"] == "Projects$Project" {
+				projects++
+			}
+		}
+		if projects != 1 {
+			t.Errorf("Expected a single project unit, got %d", projects)
+		}
+	})
+	t.Run("invalid-file", func(t *testing.T) {
+		if _, err := ParseMPR("./../resources/lint-results.png"); err == nil {
+			t.Errorf("Expected error for a file that is not an mpr file")
+		}
+	})
+}
+
+func TestMPRMultipleMetadataRows(t *testing.T) {
+	t.Run("first-row-wins", func(t *testing.T) {
+		if err := os.MkdirAll("./../tmp", 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		contents, err := os.ReadFile("./../resources/app/App.mpr")
+		if err != nil {
+			t.Fatalf("Failed to read mpr file: %v", err)
+		}
+		MPRFilePath := "./../tmp/MultipleMetadata.mpr"
+		if err := os.WriteFile(MPRFilePath, contents, 0644); err != nil {
+			t.Fatalf("Failed to write mpr file: %v", err)
+		}
+		db, err := sql.Open("sqlite", MPRFilePath)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		_, err = db.Exec("INSERT INTO _MetaData (_ProductVersion, _BuildVersion) VALUES ('99.0.0.1', '99.0.0.1')")
+		db.Close()
+		if err != nil {
+			t.Fatalf("Failed to insert metadata row: %v", err)
+		}
+
+		file, err := readMPRFile(context.Background(), MPRFilePath, ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to read mpr file: %v", err)
+		}
+		if file.ProductVersion != "10.12.2.41995" || file.BuildVersion != "10.12.2.41995" {
+			t.Errorf("Expected the versions of the first row. Got: %s %s", file.ProductVersion, file.BuildVersion)
+		}
+	})
+}
+
+func TestMPRFileNameTemplate(t *testing.T) {
+	t.Run("type-directory", func(t *testing.T) {
+		outputDirectory := "./../tmp/filename-template"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", FileNameTemplate: "{{.Type}}/{{.Name}}"}); err != nil {
+			t.Fatalf("Failed to export units from MPR file: %v", err)
+		}
+		for _, path := range []string{
+			"MyFirstModule/Folder/Microflows$Microflow/MicroflowSimple.yaml",
+			"MyFirstModule/DomainModels$DomainModel.yaml",
+		} {
+			if _, err := os.Stat(filepath.Join(outputDirectory, path)); err != nil {
+				t.Errorf("Expected %s to be written: %v", path, err)
+			}
+		}
+	})
+	t.Run("invalid-template", func(t *testing.T) {
+		if err := ExportModel("./../resources/app", "./../tmp/filename-template", ExportOptions{Mode: "basic", FileNameTemplate: "{{.Unknown}}"}); err == nil {
+			t.Errorf("Expected error for invalid template")
+		}
+	})
+}
+
+func TestMPRSince(t *testing.T) {
+	t.Run("not-supported", func(t *testing.T) {
+		err := ExportModel("./../resources/app", "./../tmp/since", ExportOptions{Mode: "basic", Since: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)})
+		if err == nil || !strings.Contains(err.Error(), ErrSinceNotSupported.Error()) {
+			t.Errorf("Expected unsupported error, got %v", err)
+		}
+	})
+}
+
+func TestMPRSchemaStats(t *testing.T) {
+	t.Run("schema-stats", func(t *testing.T) {
+		outputDirectory := "./../tmp/schema-stats"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "schema-stats"}); err != nil {
+			t.Fatalf("Failed to export units from MPR file: %v", err)
+		}
+		contents, err := os.ReadFile(filepath.Join(outputDirectory, "schema-stats.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read schema stats: %v", err)
+		}
+		var stats struct {
+			Units    int `yaml:"Units"`
+			KeyPaths map[string]struct {
+				Count int            `yaml:"Count"`
+				Types map[string]int `yaml:"Types"`
+			} `yaml:"KeyPaths"`
+		}
+		if err := yaml.Unmarshal(contents, &stats); err != nil {
+			t.Fatalf("Failed to unmarshal schema stats: %v", err)
+		}
+		name := stats.KeyPaths["Microflows$Microflow.Name"]
+		if name.Count == 0 || name.Types["string"] != name.Count {
+			t.Errorf("Unexpected stats of microflow names. Got: %v", name)
+		}
+		if stats.KeyPaths["Microflows$Microflow.ObjectCollection.Objects[].$Type"].Count == 0 {
+			t.Errorf("Expected key paths of list items")
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule")); !os.IsNotExist(err) {
+			t.Errorf("Expected no documents to be written")
+		}
+	})
+}
+
+func TestHandleWalkError(t *testing.T) {
+	directory, err := os.Stat("./../resources")
+	if err != nil {
+		t.Fatalf("Failed to stat directory: %v", err)
+	}
+	denied := &os.PathError{Op: "open", Path: "./../resources/private", Err: fs.ErrPermission}
+
+	t.Run("unreadable-directory", func(t *testing.T) {
+		if err := handleWalkError("./..", "./../resources/private", directory, denied, log); err != filepath.SkipDir {
+			t.Errorf("Expected the directory to be skipped, got %v", err)
+		}
+	})
+	t.Run("unreadable-file", func(t *testing.T) {
+		if err := handleWalkError("./..", "./../resources/private/App.mpr", nil, denied, log); err != nil {
+			t.Errorf("Expected the file to be skipped, got %v", err)
+		}
+	})
+	t.Run("input-directory", func(t *testing.T) {
+		if err := handleWalkError("./../resources/private", "./../resources/private", directory, denied, log); err != denied {
+			t.Errorf("Expected errors on the input directory to stop the walk, got %v", err)
+		}
+	})
+	t.Run("other-errors", func(t *testing.T) {
+		failure := errors.New("input/output error")
+		if err := handleWalkError("./..", "./../resources/private", directory, failure, log); err != failure {
+			t.Errorf("Expected other errors to stop the walk, got %v", err)
+		}
+	})
+}
+
+func TestSkipPaths(t *testing.T) {
+	t.Run("components", func(t *testing.T) {
+		cases := []struct {
+			path     string
+			patterns []string
+			skipped  bool
+		}{
+			{"/projects/app/.mendix-cache/App.mpr", nil, true},
+			{"/projects/app/.mendix-cache-backup/App.mpr", nil, false},
+			{"/projects/app/old.mendix-cache/App.mpr", nil, false},
+			{"/projects/app/backup-1/App.mpr", []string{"backup*"}, true},
+			{"/projects/app/.mendix-cache/App.mpr", []string{"backup*"}, false},
+		}
+		for _, c := range cases {
+			if skipped := isSkippedPath("/projects/app", c.path, c.patterns); skipped != c.skipped {
+				t.Errorf("Expected %s skipped=%v with %v", c.path, c.skipped, c.patterns)
+			}
+		}
+		if isSkippedPath("/home/user/.mendix-cache/app", "/home/user/.mendix-cache/app/App.mpr", nil) {
+			t.Errorf("Expected components above the input directory to be ignored")
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		inputDirectory := "./../tmp/skip-input"
+		outputDirectory := "./../tmp/skip-output"
+		os.RemoveAll(inputDirectory)
+		os.RemoveAll(outputDirectory)
+		contents, err := os.ReadFile("./../resources/app/App.mpr")
+		if err != nil {
+			t.Fatalf("Failed to read mpr file: %v", err)
+		}
+		for _, directory := range []string{".mendix-cache", ".mendix-cache-backup", "archive", "current"} {
+			if err := os.MkdirAll(filepath.Join(inputDirectory, directory), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(inputDirectory, directory, "App.mpr"), contents, 0644); err != nil {
+				t.Fatalf("Failed to write mpr file: %v", err)
+			}
+		}
+
+		options := ExportOptions{Mode: "basic", Skip: []string{".mendix-cache", "archive"}}
+		if err := ExportModel(inputDirectory, outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		for _, directory := range []string{".mendix-cache-backup", "current"} {
+			if _, err := os.Stat(filepath.Join(outputDirectory, directory)); err != nil {
+				t.Errorf("Expected %s to be exported: %v", directory, err)
+			}
+		}
+		for _, directory := range []string{".mendix-cache", "archive"} {
+			if _, err := os.Stat(filepath.Join(outputDirectory, directory)); !os.IsNotExist(err) {
+				t.Errorf("Expected %s to be skipped", directory)
+			}
+		}
+
+		if err := ExportModel(inputDirectory, outputDirectory, ExportOptions{Mode: "basic", Skip: []string{"["}}); err == nil {
+			t.Errorf("Expected an error for an invalid pattern")
+		}
+	})
+}
+
+func TestMPRMetadataOnly(t *testing.T) {
+	fullDirectory := "./../tmp/metadata-full"
+	outputDirectory := "./../tmp/metadata-only"
+	os.RemoveAll(fullDirectory)
+	os.RemoveAll(outputDirectory)
+	if err := ExportModel("./../resources/app", fullDirectory, ExportOptions{Mode: "basic"}); err != nil {
+		t.Fatalf("Failed to export model: %v", err)
+	}
+	if err := ExportModel("./../resources/app", outputDirectory, ExportOptions{Mode: "basic", MetadataOnly: true}); err != nil {
+		t.Fatalf("Failed to export metadata: %v", err)
+	}
+
+	// only Metadata.yaml and the Module.yaml files are written, the same as in a full export
+	count := 0
+	err := filepath.Walk(outputDirectory, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		count++
+		name, _ := filepath.Rel(outputDirectory, path)
+		if name != "Metadata.yaml" && filepath.Base(name) != "Module.yaml" {
+			t.Errorf("Unexpected file %s", name)
+			return nil
+		}
+		expected, err := os.ReadFile(filepath.Join(fullDirectory, name))
+		if err != nil {
+			return err
+		}
+		actual, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if string(actual) != string(expected) {
+			t.Errorf("Expected %s to match the full export", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to compare output: %v", err)
+	}
+	if count < 2 {
+		t.Errorf("Expected metadata and module files, got %d files", count)
+	}
+}
+
+func TestModuleDirTemplate(t *testing.T) {
+	modules := []MxModule{
+		{Name: "Administration", Version: "4.1.0", Attributes: map[string]interface{}{"AppStoreVersion": "4.1.0"}},
+		{Name: "MyFirstModule", Attributes: map[string]interface{}{}},
+	}
+
+	t.Run("attributes", func(t *testing.T) {
+		directories, err := getModuleDirectories(modules, "{{.Name}}-{{.Attributes.AppStoreVersion}}", log)
+		if err != nil {
+			t.Fatalf("Failed to get module directories: %v", err)
+		}
+		if directories["Administration"] != "Administration-4.1.0" {
+			t.Errorf("Unexpected directory. Got: %s", directories["Administration"])
+		}
+		if directories["MyFirstModule"] != "MyFirstModule" {
+			t.Errorf("Expected the name for a missing attribute. Got: %s", directories["MyFirstModule"])
+		}
+		if path := moduleDocumentPath("Administration/Pages", directories); path != filepath.Join("Administration-4.1.0", "Pages") {
+			t.Errorf("Unexpected document path. Got: %s", path)
+		}
+	})
+
+	t.Run("same-directory", func(t *testing.T) {
+		if _, err := getModuleDirectories(modules, "modules", log); err == nil {
+			t.Errorf("Expected an error when modules share a directory")
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		outputDirectory := "./../tmp/module-dir-template"
+		os.RemoveAll(outputDirectory)
+		options := ExportOptions{Mode: "basic", ModuleDirTemplate: "{{.Name}}{{with .Version}}-{{.}}{{end}}"}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		for _, path := range []string{
+			"Administration-4.1.0/Module.yaml",
+			"Administration-4.1.0/DomainModels$DomainModel.yaml",
+			"MyFirstModule-1.0.0/Folder/MicroflowSimple.Microflows$Microflow.yaml",
+		} {
+			if _, err := os.Stat(filepath.Join(outputDirectory, path)); err != nil {
+				t.Errorf("Expected %s: %v", path, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "Administration")); !os.IsNotExist(err) {
+			t.Errorf("Expected no directory named after the module")
+		}
+	})
+}
+
+func TestMPRCorruptUnit(t *testing.T) {
+	if err := os.MkdirAll("./../tmp", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	contents, err := os.ReadFile("./../resources/app/App.mpr")
+	if err != nil {
+		t.Fatalf("Failed to read mpr file: %v", err)
+	}
+	MPRFilePath := "./../tmp/CorruptUnit.mpr"
+	if err := os.WriteFile(MPRFilePath, contents, 0644); err != nil {
+		t.Fatalf("Failed to write mpr file: %v", err)
+	}
+	original, err := readMPRFile(context.Background(), MPRFilePath, ExportOptions{})
+	if err != nil {
+		t.Fatalf("Failed to read mpr file: %v", err)
+	}
+	db, err := sql.Open("sqlite", MPRFilePath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec("UPDATE Unit SET Contents = X'DEADBEEF' WHERE UnitID = (SELECT UnitID FROM Unit WHERE ContainmentName = 'Documents' LIMIT 1)")
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to corrupt unit: %v", err)
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		file, err := readMPRFile(context.Background(), MPRFilePath, ExportOptions{})
+		if err != nil {
+			t.Fatalf("Expected the corrupt unit to be skipped, got %v", err)
+		}
+		if file.SkippedUnits != 1 || len(file.Units) != len(original.Units)-1 {
+			t.Errorf("Expected 1 skipped unit and the others read. Got %d skipped and %d of %d units", file.SkippedUnits, len(file.Units), len(original.Units))
+		}
+		stats, err := exportMPR(context.Background(), MPRFilePath, NewLocalSink("./../tmp/corrupt-unit"), ExportOptions{Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to export mpr file: %v", err)
+		}
+		if stats.SkippedUnits != 1 {
+			t.Errorf("Expected 1 skipped unit in the stats. Got: %d", stats.SkippedUnits)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		if _, err := readMPRFile(context.Background(), MPRFilePath, ExportOptions{Strict: true}); err == nil {
+			t.Errorf("Expected an error in strict mode")
+		}
+	})
+}
+
+func TestMPRIDEncoding(t *testing.T) {
+	original, err := readMPRFile(context.Background(), "./../resources/app/App.mpr", ExportOptions{})
+	if err != nil {
+		t.Fatalf("Failed to read mpr file: %v", err)
+	}
+	encodings := map[string]func(string) ([]byte, error){
+		"base64url": base64.RawURLEncoding.DecodeString,
+		"hex":       hex.DecodeString,
+	}
+	for encoding, decode := range encodings {
+		t.Run(encoding, func(t *testing.T) {
+			file, err := readMPRFile(context.Background(), "./../resources/app/App.mpr", ExportOptions{IDEncoding: encoding})
+			if err != nil {
+				t.Fatalf("Failed to read mpr file: %v", err)
+			}
+			if len(file.Units) != len(original.Units) {
+				t.Fatalf("Expected %d units, got %d", len(original.Units), len(file.Units))
+			}
+			for i, unit := range file.Units {
+				for _, id := range [][2]string{{unit.UnitID, original.Units[i].UnitID}, {unit.ContainerID, original.Units[i].ContainerID}} {
+					if strings.ContainsAny(id[0], "+/=") {
+						t.Errorf("Expected %s to be safe in file names", id[0])
+					}
+					decoded, err := decode(id[0])
+					if err != nil {
+						t.Fatalf("Failed to decode %s: %v", id[0], err)
+					}
+					if base64.StdEncoding.EncodeToString(decoded) != id[1] {
+						t.Errorf("Expected %s to encode %s", id[0], id[1])
+					}
+				}
+			}
+		})
+	}
+
+	if err := ExportModel("./../resources/app", "./../tmp/id-encoding", ExportOptions{Mode: "basic", IDEncoding: "base32"}); err == nil {
+		t.Errorf("Expected an error for an invalid id encoding")
+	}
+	if err := ExportModel("./../resources/app", "./../tmp/id-encoding", ExportOptions{Mode: "basic", IDEncoding: "hex", NormalizeIDs: true}); err == nil {
+		t.Errorf("Expected an error for hex ids with normalized ids")
+	}
+}
+
+func TestMPRMaxUnitBytes(t *testing.T) {
+	const maxUnitBytes = 10000
+	db, err := sql.Open("sqlite", "./../resources/app/App.mpr")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	var total, large int
+	err = db.QueryRow("SELECT COUNT(*), SUM(length(Contents) > ?) FROM Unit", maxUnitBytes).Scan(&total, &large)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to count units: %v", err)
+	}
+	if large == 0 || large == total {
+		t.Fatalf("Expected some units larger than %d bytes, got %d of %d", maxUnitBytes, large, total)
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		file, err := readMPRFile(context.Background(), "./../resources/app/App.mpr", ExportOptions{MaxUnitBytes: maxUnitBytes})
+		if err != nil {
+			t.Fatalf("Expected the large units to be skipped, got %v", err)
+		}
+		if file.SkippedUnits != large || len(file.Units) != total-large {
+			t.Errorf("Expected %d skipped units and %d read. Got %d skipped and %d read", large, total-large, file.SkippedUnits, len(file.Units))
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		if _, err := readMPRFile(context.Background(), "./../resources/app/App.mpr", ExportOptions{MaxUnitBytes: maxUnitBytes, Strict: true}); err == nil {
+			t.Errorf("Expected an error in strict mode")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := ExportModel("./../resources/app", "./../tmp/max-unit-bytes", ExportOptions{Mode: "basic", MaxUnitBytes: -1}); err == nil {
+			t.Errorf("Expected an error for a negative maximum")
+		}
 	})
 }
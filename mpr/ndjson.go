@@ -0,0 +1,35 @@
+package mpr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// ExportModelNDJSON writes the mpr file in the input directory to w as newline-delimited JSON without touching
+// the filesystem. The first line holds the metadata with Kind metadata, followed by a line with Kind document
+// for every document. The documents are selected and cleaned as in ExportModelToMemory.
+func ExportModelNDJSON(inputDirectory string, w io.Writer, options ExportOptions) error {
+	documents, metadata, err := ExportModelToMemory(inputDirectory, options)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(map[string]interface{}{"Kind": "metadata", "Metadata": metadata}); err != nil {
+		return fmt.Errorf("error writing metadata: %v", err)
+	}
+	for _, document := range documents {
+		line := map[string]interface{}{
+			"Kind":       "document",
+			"Name":       document.Name,
+			"Type":       document.Type,
+			"Path":       filepath.ToSlash(document.Path),
+			"Attributes": document.Attributes,
+		}
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("error writing document %s: %v", document.Name, err)
+		}
+	}
+	return nil
+}
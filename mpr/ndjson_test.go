@@ -0,0 +1,43 @@
+package mpr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestExportModelNDJSON(t *testing.T) {
+	t.Run("app", func(t *testing.T) {
+		var buffer bytes.Buffer
+		if err := ExportModelNDJSON("./../resources/app", &buffer, ExportOptions{Mode: "basic"}); err != nil {
+			t.Fatalf("Failed to export model as ndjson: %v", err)
+		}
+		scanner := bufio.NewScanner(&buffer)
+		scanner.Buffer(nil, 64*1024*1024)
+		lines := 0
+		found := false
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatalf("Invalid line %d: %v", lines, err)
+			}
+			if lines == 0 && line["Kind"] != "metadata" {
+				t.Errorf("Expected metadata on the first line. Got: %v", line["Kind"])
+			}
+			if lines > 0 && line["Kind"] != "document" {
+				t.Errorf("Expected document on line %d. Got: %v", lines, line["Kind"])
+			}
+			if line["Name"] == "MicroflowSimple" {
+				found = line["Path"] == "MyFirstModule/Folder" && line["Type"] == "Microflows$Microflow"
+			}
+			lines++
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		if !found {
+			t.Errorf("Expected MicroflowSimple with its path and type")
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package mpr
+
+import "fmt"
+
+// getOrphanedDocuments describes the documents whose chain of containers does not reach the project, because
+// their container or one of its parents is missing from the model. Such documents end up at an incomplete path.
+func getOrphanedDocuments(units []MxUnit, folders []MxFolder, documents []MxDocument) []string {
+	folderMap := make(map[string]*MxFolder, len(folders))
+	for i := range folders {
+		folderMap[folders[i].ID] = &folders[i]
+	}
+	containers := make(map[string]string, len(units))
+	for _, unit := range units {
+		id, _ := idString(unit.Contents["$ID"])
+		containers[id] = unit.ContainerID
+	}
+
+	orphans := make([]string, 0)
+	for _, document := range documents {
+		id, _ := idString(document.Attributes["$ID"])
+		containerID := containers[id]
+		if !reachesProject(folderMap[containerID]) {
+			orphans = append(orphans, fmt.Sprintf("%s %s (%s) in container %s", document.Type, document.Name, id, containerID))
+		}
+	}
+	return orphans
+}
+
+// reachesProject follows the parents of the folder up to the project, stopping at cycles
+func reachesProject(folder *MxFolder) bool {
+	visited := make(map[string]bool)
+	for folder != nil && !visited[folder.ID] {
+		if folder.Attributes["$Type"] == "Projects$Project" {
+			return true
+		}
+		visited[folder.ID] = true
+		folder = folder.Parent
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package mpr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetOrphanedDocuments(t *testing.T) {
+	units := []MxUnit{
+		{UnitID: "project", Contents: map[string]interface{}{"$ID": "project", "$Type": "Projects$Project"}},
+		{UnitID: "module", ContainerID: "project", ContainmentName: "Modules", Contents: map[string]interface{}{"$ID": "module", "Name": "MyFirstModule"}},
+		{UnitID: "folder", ContainerID: "missing", ContainmentName: "Folders", Contents: map[string]interface{}{"$ID": "folder", "Name": "Lost"}},
+		{UnitID: "attached", ContainerID: "module", ContainmentName: "Documents", Contents: map[string]interface{}{"$ID": "attached"}},
+		{UnitID: "security", ContainerID: "project", ContainmentName: "ProjectDocuments", Contents: map[string]interface{}{"$ID": "security"}},
+		{UnitID: "in-lost-folder", ContainerID: "folder", ContainmentName: "Documents", Contents: map[string]interface{}{"$ID": "in-lost-folder"}},
+		{UnitID: "no-container", ContainerID: "unknown", ContainmentName: "Documents", Contents: map[string]interface{}{"$ID": "no-container"}},
+	}
+	folders, err := getMxFolders(units, MxVersion{}, ExportOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get folders: %v", err)
+	}
+	documents := make([]MxDocument, 0)
+	for _, unit := range units[3:] {
+		documents = append(documents, MxDocument{Name: unit.UnitID, Type: "Microflows$Microflow", Attributes: unit.Contents})
+	}
+
+	orphans := getOrphanedDocuments(units, folders, documents)
+	if len(orphans) != 2 || !strings.Contains(orphans[0], "in-lost-folder") || !strings.Contains(orphans[1], "no-container") {
+		t.Errorf("Unexpected orphaned documents. Got: %v", orphans)
+	}
+}
+
+func TestMPRStrict(t *testing.T) {
+	t.Run("no-orphans", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp/strict", ExportOptions{Mode: "basic", Strict: true}); err != nil {
+			t.Errorf("Expected all documents to be attached to a module: %v", err)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package mpr
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func transformPage(page MxDocument, documentIndex map[string]string, log Logger) MxDocument {
+	log.Infof("Transforming page %s", page.Name)
+
+	bindings := make([]map[string]interface{}, 0)
+	collectDataBindings(page.Attributes, documentIndex, &bindings)
+	page.Attributes["DataBindings"] = bindings
+	return page
+}
+
+// collectDataBindings lists the data sources of the outermost data widgets. Widgets nested in a
+// data widget get their data from it, so they are not visited.
+func collectDataBindings(value interface{}, documentIndex map[string]string, bindings *[]map[string]interface{}) {
+	switch v := value.(type) {
+	case bson.M:
+		collectDataBindings(map[string]interface{}(v), documentIndex, bindings)
+	case map[string]interface{}:
+		if source, ok := getDataSource(v); ok {
+			*bindings = append(*bindings, getDataBinding(v, source, documentIndex))
+			return
+		}
+		for _, key := range sortedKeys(v) {
+			collectDataBindings(v[key], documentIndex, bindings)
+		}
+	case primitive.A:
+		collectDataBindings([]interface{}(v), documentIndex, bindings)
+	case []interface{}:
+		for _, item := range v {
+			collectDataBindings(item, documentIndex, bindings)
+		}
+	}
+}
+
+// getDataSource returns the data source of a widget. Pluggable widgets keep it in one of their property values.
+func getDataSource(widget map[string]interface{}) (map[string]interface{}, bool) {
+	if source, ok := getObject(widget["DataSource"]); ok {
+		return source, true
+	}
+	if widget["$Type"] != "CustomWidgets$CustomWidget" {
+		return nil, false
+	}
+	object, _ := getObject(widget["Object"])
+	for _, property := range getObjectList(object["Properties"]) {
+		value, _ := getObject(property["Value"])
+		if source, ok := getObject(value["DataSource"]); ok {
+			return source, true
+		}
+	}
+	return nil, false
+}
+
+func getDataBinding(widget map[string]interface{}, source map[string]interface{}, documentIndex map[string]string) map[string]interface{} {
+	binding := map[string]interface{}{
+		"Widget":     widget["Name"],
+		"WidgetType": widget["$Type"],
+		"SourceType": source["$Type"],
+	}
+	if entityRef, ok := getObject(source["EntityRef"]); ok {
+		binding["Entity"] = getEntityRefTarget(entityRef)
+	}
+	if settings, ok := getObject(source["MicroflowSettings"]); ok {
+		binding["Microflow"] = resolveDocumentName(settings["Microflow"], documentIndex)
+	}
+	if nanoflow, ok := source["Nanoflow"]; ok {
+		binding["Nanoflow"] = resolveDocumentName(nanoflow, documentIndex)
+	}
+	return binding
+}
+
+// getEntityRefTarget returns the entity of a direct reference or the destination of the last association step
+func getEntityRefTarget(entityRef map[string]interface{}) interface{} {
+	if entity, ok := entityRef["Entity"]; ok {
+		return entity
+	}
+	steps := getObjectList(entityRef["Steps"])
+	if len(steps) == 0 {
+		return nil
+	}
+	return steps[len(steps)-1]["DestinationEntity"]
+}
+
+// resolveDocumentName returns the qualified name of a document reference stored either by name or by ID
+func resolveDocumentName(value interface{}, documentIndex map[string]string) interface{} {
+	if id, ok := idString(value); ok {
+		if qualifiedName, ok := documentIndex[id]; ok {
+			return qualifiedName
+		}
+	}
+	return value
+}
@@ -0,0 +1,73 @@
+package mpr
+
+import (
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMPRPageDataBindings(t *testing.T) {
+	t.Run("account-overview", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Mode: "advanced"}); err != nil {
+			t.Errorf("Failed to export units from MPR file")
+		}
+
+		pageFile, err := os.ReadFile("./../tmp/Administration/User Management/Admin/Account_Overview.Forms$Page.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		var page struct {
+			DataBindings []map[string]string `yaml:"DataBindings"`
+		}
+		if err := yaml.Unmarshal(pageFile, &page); err != nil {
+			t.Fatalf("Failed to unmarshal page file: %v", err)
+		}
+		if len(page.DataBindings) != 1 {
+			t.Fatalf("Expected only the top-level data binding. Got: %v", page.DataBindings)
+		}
+		binding := page.DataBindings[0]
+		if binding["Entity"] != "Administration.Account" || binding["Widget"] != "dataGrid21" {
+			t.Errorf("Unexpected data binding. Got: %v", binding)
+		}
+	})
+}
+
+func TestResolveReferences(t *testing.T) {
+	units := []MxUnit{
+		{UnitID: "module", ContainmentName: "Modules", Contents: map[string]interface{}{"$ID": "module", "Name": "MyFirstModule"}},
+		{UnitID: "page", ContainerID: "module", ContainmentName: "Documents", Contents: map[string]interface{}{
+			"$ID": primitive.Binary{Subtype: 3, Data: []byte{1, 2, 3}}, "$Type": "Forms$Page", "Name": "Home",
+		}},
+		{UnitID: "flow", ContainerID: "module", ContainmentName: "Documents", Contents: map[string]interface{}{"$ID": "flow", "$Type": "Microflows$Microflow", "Name": "ACT_Save"}},
+	}
+	folders := []MxFolder{{ID: "module", Name: "MyFirstModule"}}
+	refIndex := buildDocumentRefIndex(units, getMxFolderPaths(folders, log), MxVersion{})
+
+	action := bson.M{"$Type": "Forms$FormAction", "Form": primitive.Binary{Subtype: 3, Data: []byte{1, 2, 3}}}
+	attributes := map[string]interface{}{
+		"$ID":     "flow",
+		"Action":  action,
+		"Targets": primitive.A{int32(2), "flow", "page"},
+		"Caption": "Unrelated",
+	}
+	resolveReferences(attributes, refIndex)
+
+	if ref, ok := action["_FormRef"].(MxDocumentRef); !ok || ref.Name != "MyFirstModule.Home" || ref.Type != "Forms$Page" {
+		t.Errorf("Unexpected reference of the page. Got: %v", action["_FormRef"])
+	}
+	if _, ok := action["Form"].(primitive.Binary); !ok {
+		t.Errorf("Expected the raw ID to be kept")
+	}
+	if refs, ok := attributes["_TargetsRef"].([]MxDocumentRef); !ok || len(refs) != 2 || refs[0].Name != "MyFirstModule.ACT_Save" {
+		t.Errorf("Unexpected references of the list. Got: %v", attributes["_TargetsRef"])
+	}
+	if _, ok := attributes["_$IDRef"]; ok {
+		t.Errorf("Expected the own ID not to be resolved")
+	}
+	if _, ok := attributes["_CaptionRef"]; ok {
+		t.Errorf("Expected no reference for unknown values")
+	}
+}
@@ -0,0 +1,98 @@
+package mpr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// marshalProperties serializes the contents as a Java properties file with one line per scalar value. Like the
+// other formats the contents first go through encoding/json. Keys of nested maps are joined with dots and list
+// items are indexed, e.g. Flows[0].OriginPointer. Empty maps and lists are written as {} and [], null as an
+// empty value.
+func marshalProperties(contents interface{}) ([]byte, error) {
+	data, err := json.Marshal(contents)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeJSONNumbers(data)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	writeProperties(&buffer, "", value)
+	return buffer.Bytes(), nil
+}
+
+// writeProperties writes the scalar leaves of the value below the key
+func writeProperties(buffer *bytes.Buffer, key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && key != "" {
+			writeProperty(buffer, key, "{}")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeProperties(buffer, joinKeyPath(key, k), v[k])
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			writeProperty(buffer, key, "[]")
+			return
+		}
+		for i, item := range v {
+			writeProperties(buffer, fmt.Sprintf("%s[%d]", key, i), item)
+		}
+	case nil:
+		writeProperty(buffer, key, "")
+	case string:
+		writeProperty(buffer, key, v)
+	default:
+		writeProperty(buffer, key, fmt.Sprint(v))
+	}
+}
+
+func writeProperty(buffer *bytes.Buffer, key string, value string) {
+	buffer.WriteString(escapeProperty(key, true))
+	buffer.WriteString("=")
+	buffer.WriteString(escapeProperty(value, false))
+	buffer.WriteString("\n")
+}
+
+// escapeProperty escapes the characters that have a meaning in properties files. Spaces are escaped in keys
+// and at the start of values, which would otherwise be trimmed.
+func escapeProperty(text string, key bool) string {
+	var builder strings.Builder
+	for i, r := range text {
+		switch {
+		case r == '\\':
+			builder.WriteString(`\\`)
+		case r == '\n':
+			builder.WriteString(`\n`)
+		case r == '\r':
+			builder.WriteString(`\r`)
+		case r == '\t':
+			builder.WriteString(`\t`)
+		case r == '\f':
+			builder.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			builder.WriteString(`\ `)
+		case key && strings.ContainsRune("=:#!", r), !key && i == 0 && (r == '#' || r == '!'):
+			builder.WriteRune('\\')
+			builder.WriteRune(r)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&builder, `\u%04x`, r)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
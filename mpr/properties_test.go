@@ -0,0 +1,70 @@
+package mpr
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMarshalProperties(t *testing.T) {
+	t.Run("rules", func(t *testing.T) {
+		contents := bson.M{
+			"$Type":         "Microflows$Microflow",
+			"Name":          "ACT_Save",
+			"Documentation": " first line\nsecond = line",
+			"Empty":         nil,
+			"Count":         int32(2),
+			"Items":         primitive.A{bson.M{"$Type": "Texts$Translation", "Text": "a"}, "b"},
+			"Nested":        primitive.A{primitive.A{"x"}, primitive.A{}},
+			"Settings":      bson.M{},
+			"with key":      true,
+		}
+		data, err := marshal(contents, "properties")
+		if err != nil {
+			t.Fatalf("Failed to marshal: %v", err)
+		}
+		expected := `$Type=Microflows$Microflow
+Count=2
+Documentation=\ first line\nsecond = line
+Empty=
+Items[0].$Type=Texts$Translation
+Items[0].Text=a
+Items[1]=b
+Name=ACT_Save
+Nested[0][0]=x
+Nested[1]=[]
+Settings={}
+with\ key=true
+`
+		if string(data) != expected {
+			t.Errorf("Unexpected properties. Got:\n%s", data)
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		outputDirectory := "./../tmp/properties"
+		os.RemoveAll(outputDirectory)
+		options := ExportOptions{Mode: "basic", Format: "properties", Checksums: true}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		for _, path := range []string{"Metadata.properties", "checksums.yaml"} {
+			if _, err := os.Stat(outputDirectory + "/" + path); err != nil {
+				t.Errorf("Expected %s: %v", path, err)
+			}
+		}
+		contents, err := os.ReadFile(outputDirectory + "/MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.properties")
+		if err != nil {
+			t.Fatalf("Failed to read microflow: %v", err)
+		}
+		if !strings.Contains(string(contents), "\nName=MicroflowSimple\n") {
+			t.Errorf("Expected the name of the microflow. Got:\n%s", contents)
+		}
+		if err := VerifyExport(outputDirectory); err != nil {
+			t.Errorf("Failed to verify properties export: %v", err)
+		}
+	})
+}
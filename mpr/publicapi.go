@@ -0,0 +1,85 @@
+package mpr
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type mxPublicAPI struct {
+	Microflows        []string
+	JavaActions       []string
+	PublishedServices []string
+	Entities          []string
+}
+
+// exportPublicAPI writes a PublicAPI.yaml per module listing what consumers of the module can call into:
+// microflows exposed as action or usable outside the module, Java actions, published services and entities.
+// Entities are all public in source modules; otherwise only those not hidden by their export level.
+func exportPublicAPI(documents []MxDocument, moduleDirectories map[string]string, output Sink, format string, log Logger) error {
+	sourceModules := make(map[string]bool)
+	for _, document := range documents {
+		if document.Type == "Projects$ModuleSettings" && document.Attributes["ExportLevel"] == "Source" {
+			sourceModules[getMxModuleName(document.Path)] = true
+		}
+	}
+
+	apis := make(map[string]*mxPublicAPI)
+	for _, document := range documents {
+		module := getMxModuleName(document.Path)
+		if module == "" {
+			continue
+		}
+		if apis[module] == nil {
+			apis[module] = &mxPublicAPI{
+				Microflows:        make([]string, 0),
+				JavaActions:       make([]string, 0),
+				PublishedServices: make([]string, 0),
+				Entities:          make([]string, 0),
+			}
+		}
+		api := apis[module]
+		name := qualifiedName(module, document.Name)
+		switch {
+		case document.Type == "Microflows$Microflow":
+			if document.Attributes["MicroflowActionInfo"] != nil || isExported(document.Attributes) {
+				api.Microflows = append(api.Microflows, name)
+			}
+		case document.Type == "JavaActions$JavaAction":
+			api.JavaActions = append(api.JavaActions, name)
+		case strings.Contains(document.Type, "$Published"):
+			api.PublishedServices = append(api.PublishedServices, name)
+		case document.Type == "DomainModels$DomainModel":
+			for _, entity := range getObjectList(document.Attributes["Entities"]) {
+				if sourceModules[module] || isExported(entity) {
+					api.Entities = append(api.Entities, qualifiedName(module, entity["Name"]))
+				}
+			}
+		}
+	}
+
+	for module, api := range apis {
+		sort.Strings(api.Microflows)
+		sort.Strings(api.JavaActions)
+		sort.Strings(api.PublishedServices)
+		sort.Strings(api.Entities)
+		directory := filepath.ToSlash(getModuleDirectory(module, moduleDirectories))
+		contents := map[string]interface{}{
+			"Module":            module,
+			"Microflows":        api.Microflows,
+			"JavaActions":       api.JavaActions,
+			"PublishedServices": api.PublishedServices,
+			"Entities":          api.Entities,
+		}
+		if err := writeFile(output, path.Join(directory, "PublicAPI."+fileExtension(format)), contents, format, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isExported(object map[string]interface{}) bool {
+	exportLevel, _ := object["ExportLevel"].(string)
+	return exportLevel != "" && exportLevel != "Hidden"
+}
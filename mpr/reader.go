@@ -0,0 +1,33 @@
+package mpr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ExportModelFromReader exports an mpr file held in memory or any other io.ReaderAt, like an upload.
+// The sqlite driver reads databases from a path, so the contents are copied to a temporary file that is
+// removed once the export is done.
+func ExportModelFromReader(r io.ReaderAt, size int64, outputDirectory string, options ExportOptions) error {
+	directory, err := os.MkdirTemp("", "mxlint-mpr-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	MPRFilePath := filepath.Join(directory, "App.mpr")
+	file, err := os.Create(MPRFilePath)
+	if err != nil {
+		return fmt.Errorf("error creating temporary mpr file: %v", err)
+	}
+	if _, err := io.Copy(file, io.NewSectionReader(r, 0, size)); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing temporary mpr file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing temporary mpr file: %v", err)
+	}
+	return ExportModel(MPRFilePath, outputDirectory, options)
+}
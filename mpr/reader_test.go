@@ -0,0 +1,31 @@
+package mpr
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportModelFromReader(t *testing.T) {
+	t.Run("bytes", func(t *testing.T) {
+		contents, err := os.ReadFile("./../resources/app/App.mpr")
+		if err != nil {
+			t.Fatalf("Failed to read mpr file: %v", err)
+		}
+		outputDirectory := "./../tmp/from-reader"
+		os.RemoveAll(outputDirectory)
+		if err := ExportModelFromReader(bytes.NewReader(contents), int64(len(contents)), outputDirectory, ExportOptions{Mode: "basic"}); err != nil {
+			t.Fatalf("Failed to export model from reader: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirectory, "MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.yaml")); err != nil {
+			t.Errorf("Expected documents to be exported: %v", err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		contents := []byte("not a model")
+		if err := ExportModelFromReader(bytes.NewReader(contents), int64(len(contents)), "./../tmp/from-reader-invalid", ExportOptions{Mode: "basic"}); err == nil {
+			t.Errorf("Expected error for contents that are not an mpr file")
+		}
+	})
+}
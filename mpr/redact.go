@@ -0,0 +1,75 @@
+package mpr
+
+import (
+	"fmt"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const redactedPlaceholder = "REDACTED"
+
+func compileRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %s: %v", pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// redactValues replaces the scalar values below every key path matching one of the patterns by a placeholder.
+// Key paths join the keys from the document root with dots, e.g. Entities.Attributes.Value.DefaultValue;
+// list items do not add a segment. Structure, $Type and Name are kept.
+func redactValues(value interface{}, path string, patterns []*regexp.Regexp, redact bool) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		redactObject(v, path, patterns, redact)
+	case map[string]interface{}:
+		redactObject(v, path, patterns, redact)
+	case primitive.A:
+		for i, item := range v {
+			v[i] = redactValues(item, path, patterns, redact)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValues(item, path, patterns, redact)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			redactObject(item, path, patterns, redact)
+		}
+	case nil:
+	default:
+		if redact {
+			return redactedPlaceholder
+		}
+	}
+	return value
+}
+
+func redactObject(object map[string]interface{}, path string, patterns []*regexp.Regexp, redact bool) {
+	for key, item := range object {
+		if key == "$Type" || key == "Name" {
+			continue
+		}
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		object[key] = redactValues(item, keyPath, patterns, redact || matchesAny(keyPath, patterns))
+	}
+}
+
+func matchesAny(keyPath string, patterns []*regexp.Regexp) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(keyPath) {
+			return true
+		}
+	}
+	return false
+}
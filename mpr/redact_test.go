@@ -0,0 +1,67 @@
+package mpr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRedactValues(t *testing.T) {
+	t.Run("default-values", func(t *testing.T) {
+		patterns, err := compileRedactPatterns([]string{`\.Value\.DefaultValue$`, `^Value$`})
+		if err != nil {
+			t.Fatalf("Failed to compile patterns: %v", err)
+		}
+		data := bson.M{
+			"$Type": "Constants$Constant",
+			"Name":  "ApiKey",
+			"Value": "secret",
+			"Attributes": []interface{}{
+				bson.M{
+					"Name":  "Price",
+					"Value": bson.M{"$Type": "DomainModels$StoredValue", "DefaultValue": "42"},
+				},
+			},
+		}
+		redactValues(data, "", patterns, false)
+
+		if data["Value"] != redactedPlaceholder {
+			t.Errorf("Expected constant value to be redacted. Got: %v", data["Value"])
+		}
+		value := data["Attributes"].([]interface{})[0].(bson.M)["Value"].(bson.M)
+		if value["DefaultValue"] != redactedPlaceholder {
+			t.Errorf("Expected default value to be redacted. Got: %v", value["DefaultValue"])
+		}
+		if value["$Type"] != "DomainModels$StoredValue" || data["Name"] != "ApiKey" {
+			t.Errorf("Expected structure to be kept")
+		}
+	})
+	t.Run("memory", func(t *testing.T) {
+		documents, _, err := ExportModelToMemory("./../resources/app", ExportOptions{Mode: "basic", Redact: []string{`^Documentation$`}})
+		if err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		found := false
+		for _, document := range documents {
+			if documentation, ok := document.Attributes["Documentation"]; ok {
+				found = true
+				if documentation != redactedPlaceholder {
+					t.Errorf("Expected documentation of %s to be redacted. Got: %v", document.Name, documentation)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected documents with documentation")
+		}
+	})
+	t.Run("links", func(t *testing.T) {
+		if err := ExportModel("./../resources/app", "./../tmp/redacted-links", ExportOptions{Mode: "basic", Links: true, Redact: []string{`^Documentation$`}}); err == nil {
+			t.Errorf("Expected links to be rejected with redaction")
+		}
+	})
+	t.Run("invalid-pattern", func(t *testing.T) {
+		if _, err := compileRedactPatterns([]string{"("}); err == nil {
+			t.Errorf("Expected error for invalid pattern")
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package mpr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/ghodss/yaml"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// validateRoundtrip parses the serialized data again and compares it to the contents it was marshaled from.
+// The error names the key path of the first value that did not survive serialization.
+func validateRoundtrip(data []byte, contents interface{}, format string) error {
+	if format != "json" {
+		var err error
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return fmt.Errorf("error parsing written output: %v", err)
+		}
+	}
+	parsed, err := decodeJSONNumbers(data)
+	if err != nil {
+		return fmt.Errorf("error parsing written output: %v", err)
+	}
+	return compareRoundtrip("", contents, parsed)
+}
+
+// decodeJSONNumbers decodes JSON keeping numbers as written, so a loss of precision is noticed
+func decodeJSONNumbers(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func compareRoundtrip(path string, expected interface{}, actual interface{}) error {
+	mismatch := func() error {
+		return fmt.Errorf("value at %s does not survive serialization: wrote %v (%T), read back %v", roundtripPath(path), expected, expected, actual)
+	}
+	// nil slices and maps are serialized as null
+	if v := reflect.ValueOf(expected); (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		expected = nil
+	}
+	switch e := expected.(type) {
+	case bson.M:
+		return compareRoundtrip(path, map[string]interface{}(e), actual)
+	case primitive.A:
+		return compareRoundtrip(path, []interface{}(e), actual)
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok || len(a) != len(e) {
+			return mismatch()
+		}
+		for _, key := range sortedKeys(e) {
+			value, ok := a[key]
+			if !ok {
+				return fmt.Errorf("key %s is missing after serialization", roundtripPath(joinKeyPath(path, key)))
+			}
+			if err := compareRoundtrip(joinKeyPath(path, key), e[key], value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return mismatch()
+		}
+		for i := range e {
+			if err := compareRoundtrip(fmt.Sprintf("%s[%d]", path, i), e[i], a[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []map[string]interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return mismatch()
+		}
+		for i := range e {
+			if err := compareRoundtrip(fmt.Sprintf("%s[%d]", path, i), e[i], a[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		if actual != nil {
+			return mismatch()
+		}
+		return nil
+	case string, bool:
+		if actual != expected {
+			return mismatch()
+		}
+		return nil
+	case int, int32, int64:
+		a, ok := actual.(json.Number)
+		if !ok || a.String() != strconv.FormatInt(reflect.ValueOf(e).Int(), 10) {
+			return mismatch()
+		}
+		return nil
+	case float32, float64:
+		f := reflect.ValueOf(e).Float()
+		a, ok := actual.(json.Number)
+		if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return mismatch()
+		}
+		if parsed, err := a.Float64(); err != nil || parsed != f {
+			return mismatch()
+		}
+		return nil
+	}
+	// other values, like binary IDs and structs, are compared with their canonical JSON form
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return fmt.Errorf("value at %s cannot be serialized: %v", roundtripPath(path), err)
+	}
+	canonical, err := decodeJSONNumbers(data)
+	if err != nil || !reflect.DeepEqual(canonical, actual) {
+		return mismatch()
+	}
+	return nil
+}
+
+func joinKeyPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func roundtripPath(path string) string {
+	if path == "" {
+		return "the top level"
+	}
+	return path
+}
@@ -0,0 +1,53 @@
+package mpr
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestValidateRoundtrip(t *testing.T) {
+	contents := map[string]interface{}{
+		"$ID":     primitive.Binary{Subtype: 3, Data: []byte{1, 2, 3}},
+		"Name":    "yes",
+		"Count":   int64(9007199254740993),
+		"Ratio":   0.1,
+		"Widgets": []interface{}(nil),
+		"Items":   primitive.A{int32(3), bson.M{"Caption": "on", "Empty": nil}},
+	}
+	for _, format := range []string{"yaml", "json"} {
+		t.Run(format, func(t *testing.T) {
+			data, err := marshal(contents, format)
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+			if err := validateRoundtrip(data, contents, format); err != nil {
+				t.Errorf("Expected the contents to survive serialization: %v", err)
+			}
+		})
+	}
+
+	t.Run("mismatch", func(t *testing.T) {
+		data := []byte("Items:\n- 3\n- Caption: off\n  Empty: null\n")
+		err := validateRoundtrip(data, map[string]interface{}{"Items": contents["Items"]}, "yaml")
+		if err == nil || !strings.Contains(err.Error(), "Items[1].Caption") {
+			t.Errorf("Expected a mismatch at Items[1].Caption, got %v", err)
+		}
+	})
+
+	t.Run("missing-key", func(t *testing.T) {
+		err := validateRoundtrip([]byte("{}"), map[string]interface{}{"Name": "x"}, "json")
+		if err == nil {
+			t.Errorf("Expected an error for a missing key")
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		options := ExportOptions{Mode: "advanced", ValidateRoundtrip: true}
+		if err := ExportModel("./../resources/app", "./../tmp/roundtrip", options); err != nil {
+			t.Errorf("Failed to export model with round-trip validation: %v", err)
+		}
+	})
+}
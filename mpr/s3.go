@@ -0,0 +1,175 @@
+package mpr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Sink uploads the files to a bucket of an S3 compatible object store with signature version 4
+type s3Sink struct {
+	client       *http.Client
+	endpoint     string
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// NewS3Sink returns a sink for an output of the form s3://bucket/prefix. Credentials and the region are read from
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION. AWS_ENDPOINT_URL points the sink at
+// another S3 compatible store; objects are then addressed path-style.
+func NewS3Sink(output string) (Sink, error) {
+	location, err := url.Parse(output)
+	if err != nil || location.Scheme != "s3" || location.Host == "" {
+		return nil, fmt.Errorf("invalid s3 output %s, expected s3://bucket/prefix", output)
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for s3 output")
+	}
+	return &s3Sink{
+		client:       &http.Client{Timeout: time.Minute},
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		bucket:       location.Host,
+		prefix:       strings.Trim(location.Path, "/"),
+		region:       region,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+// MkdirAll does nothing as object stores have no directories
+func (s *s3Sink) MkdirAll(path string) error {
+	return nil
+}
+
+func (s *s3Sink) WriteFile(name string, data []byte) error {
+	response, err := s.do(http.MethodPut, name, data)
+	if err != nil {
+		return fmt.Errorf("error uploading %s: %v", name, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("error uploading %s: %s %s", name, response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ReadFile downloads an object, so incremental exports can read the state of the previous run from the bucket
+func (s *s3Sink) ReadFile(name string) ([]byte, error) {
+	response, err := s.do(http.MethodGet, name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %v", name, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, fs.ErrNotExist
+	}
+	if response.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("error downloading %s: %s %s", name, response.Status, strings.TrimSpace(string(body)))
+	}
+	return io.ReadAll(response.Body)
+}
+
+// do sends a signed request for the object at name with the data as body
+func (s *s3Sink) do(method string, name string, data []byte) (*http.Response, error) {
+	uri := "/" + escapeS3Path(path.Join(s.bucket, s.prefix, name))
+	request, err := http.NewRequest(method, s.endpoint+uri, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	payloadHash := sha256.Sum256(data)
+	headers := map[string]string{
+		"host":                 request.URL.Host,
+		"x-amz-content-sha256": hex.EncodeToString(payloadHash[:]),
+		"x-amz-date":           time.Now().UTC().Format("20060102T150405Z"),
+	}
+	if s.sessionToken != "" {
+		headers["x-amz-security-token"] = s.sessionToken
+	}
+	for key, value := range headers {
+		if key != "host" {
+			request.Header.Set(key, value)
+		}
+	}
+	request.Header.Set("Authorization", signS3Request(method, uri, headers, s.region, s.accessKey, s.secretKey))
+	return s.client.Do(request)
+}
+
+// signS3Request returns the signature version 4 Authorization header of a request without query parameters.
+// The headers must hold host, x-amz-content-sha256 and x-amz-date.
+func signS3Request(method string, uri string, headers map[string]string, region string, accessKey string, secretKey string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		method, uri, "", canonicalHeaders.String(), signedHeaders, headers["x-amz-content-sha256"],
+	}, "\n")
+
+	date := headers["x-amz-date"]
+	scope := date[:8] + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", date, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapeS3Path percent-encodes every byte of the object path except unreserved characters and slashes
+func escapeS3Path(name string) string {
+	var builder strings.Builder
+	for _, b := range []byte(name) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', strings.IndexByte("-._~/", b) >= 0:
+			builder.WriteByte(b)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,62 @@
+package mpr
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// keyPathStats counts how often a key path occurs and the Go types of its values
+type keyPathStats struct {
+	Count int            `json:"Count"`
+	Types map[string]int `json:"Types"`
+}
+
+// exportSchemaStats writes schema-stats.yaml with every key path found in the contents of all units, rooted at
+// the $Type of the unit, e.g. Microflows$Microflow.ObjectCollection.Objects[].Caption. List items share the
+// key path of their list.
+func exportSchemaStats(units []MxUnit, output Sink, format string, log Logger) error {
+	stats := make(map[string]*keyPathStats)
+	for _, unit := range units {
+		unitType, _ := unit.Contents["$Type"].(string)
+		collectKeyPaths(unit.Contents, unitType, stats)
+	}
+	log.Infof("Found %d key paths in %d units", len(stats), len(units))
+
+	keyPaths := make(map[string]interface{}, len(stats))
+	for keyPath, s := range stats {
+		keyPaths[keyPath] = s
+	}
+	return writeFile(output, "schema-stats."+fileExtension(format), map[string]interface{}{
+		"Units":    len(units),
+		"KeyPaths": keyPaths,
+	}, format, log)
+}
+
+func collectKeyPaths(value interface{}, keyPath string, stats map[string]*keyPathStats) {
+	switch v := value.(type) {
+	case bson.M:
+		collectKeyPaths(map[string]interface{}(v), keyPath, stats)
+	case map[string]interface{}:
+		for key, item := range v {
+			itemPath := joinKey(keyPath, key)
+			s, ok := stats[itemPath]
+			if !ok {
+				s = &keyPathStats{Types: make(map[string]int)}
+				stats[itemPath] = s
+			}
+			s.Count++
+			s.Types[fmt.Sprintf("%T", item)]++
+			collectKeyPaths(item, itemPath, stats)
+		}
+	case primitive.A:
+		for i, item := range v {
+			if _, ok := item.(int32); ok && i == 0 {
+				// array type marker
+				continue
+			}
+			collectKeyPaths(item, keyPath+"[]", stats)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package mpr
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Sink receives the exported files. Paths are slash separated and relative to the root of the sink.
+type Sink interface {
+	MkdirAll(path string) error
+	WriteFile(path string, data []byte) error
+}
+
+// SinkReader is implemented by sinks whose files can be read back. Incremental exports read the state of the
+// previous run through it; with other sinks every run starts from scratch.
+type SinkReader interface {
+	ReadFile(path string) ([]byte, error)
+}
+
+// readSinkFile reads a file back from the sink. Files of sinks that cannot be read back do not exist.
+func readSinkFile(sink Sink, name string) ([]byte, error) {
+	reader, ok := sink.(SinkReader)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return reader.ReadFile(name)
+}
+
+// localSink writes the files below a directory on disk
+type localSink struct {
+	directory string
+}
+
+// NewLocalSink returns a sink that writes the files below the given directory
+func NewLocalSink(directory string) Sink {
+	return localSink{directory: directory}
+}
+
+func (s localSink) MkdirAll(path string) error {
+	return os.MkdirAll(filepath.Join(s.directory, filepath.FromSlash(path)), 0755)
+}
+
+func (s localSink) WriteFile(path string, data []byte) error {
+	return os.WriteFile(filepath.Join(s.directory, filepath.FromSlash(path)), data, 0644)
+}
+
+func (s localSink) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.directory, filepath.FromSlash(path)))
+}
+
+// subSink writes below a directory of another sink, like the subdirectory every mpr file is exported to
+type subSink struct {
+	parent    Sink
+	directory string
+}
+
+// newSubSink returns a sink for the directory of the parent sink. The directory is slash separated.
+func newSubSink(parent Sink, directory string) Sink {
+	if directory == "" || directory == "." {
+		return parent
+	}
+	return subSink{parent: parent, directory: directory}
+}
+
+func (s subSink) MkdirAll(name string) error {
+	return s.parent.MkdirAll(path.Join(s.directory, name))
+}
+
+func (s subSink) WriteFile(name string, data []byte) error {
+	return s.parent.WriteFile(path.Join(s.directory, name), data)
+}
+
+func (s subSink) ReadFile(name string) ([]byte, error) {
+	return readSinkFile(s.parent, path.Join(s.directory, name))
+}
+
+// skipFileSink drops writes of a single file and passes everything else on
+type skipFileSink struct {
+	Sink
+	name string
+}
+
+func (s skipFileSink) WriteFile(name string, data []byte) error {
+	if name == s.name {
+		return nil
+	}
+	return s.Sink.WriteFile(name, data)
+}
+
+// writeSinkFile creates the directory of the file in the sink and writes the file
+func writeSinkFile(sink Sink, name string, data []byte) error {
+	if err := sink.MkdirAll(path.Dir(name)); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	return sink.WriteFile(name, data)
+}
@@ -0,0 +1,77 @@
+package mpr
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// writeDocumentFile writes the contents to the file at name in the sink. When maxBytes is set and the serialized contents exceed it,
+// the top level attributes are spread over numbered part files in a directory named after the file instead,
+// together with an index listing the attributes of every part. With validate the serialized contents are
+// parsed again and compared to the contents before anything is written.
+func writeDocumentFile(output Sink, name string, contents map[string]interface{}, format string, maxBytes int, validate bool, log Logger) error {
+	data, err := marshal(contents, format)
+	if err != nil {
+		return fmt.Errorf("error marshaling: %v", err)
+	}
+	if validate {
+		if err := validateRoundtrip(data, contents, format); err != nil {
+			return fmt.Errorf("error validating %s: %v", name, err)
+		}
+	}
+	if maxBytes <= 0 || len(data) <= maxBytes {
+		log.Debugf("Writing file %s", name)
+		if err := writeSinkFile(output, name, data); err != nil {
+			return fmt.Errorf("error writing file: %v", err)
+		}
+		return nil
+	}
+
+	extension := fileExtension(format)
+	directory := strings.TrimSuffix(name, "."+extension)
+	log.Infof("Splitting %s of %d bytes into parts in %s", name, len(data), directory)
+	parts, err := splitDocument(contents, format, maxBytes)
+	if err != nil {
+		return err
+	}
+	index := make([]map[string]interface{}, 0, len(parts))
+	for i, part := range parts {
+		fname := fmt.Sprintf("part-%03d.%s", i+1, extension)
+		if err := writeFile(output, path.Join(directory, fname), part, format, log); err != nil {
+			return err
+		}
+		index = append(index, map[string]interface{}{
+			"File": fname,
+			"Keys": sortedKeys(part),
+		})
+	}
+	return writeFile(output, path.Join(directory, "index."+extension), map[string]interface{}{
+		"Parts": index,
+	}, format, log)
+}
+
+// splitDocument groups the top level attributes in key order into parts that stay below maxBytes when
+// serialized. An attribute that is larger than maxBytes by itself gets a part of its own.
+func splitDocument(contents map[string]interface{}, format string, maxBytes int) ([]map[string]interface{}, error) {
+	parts := make([]map[string]interface{}, 0)
+	current := make(map[string]interface{})
+	size := 0
+	for _, key := range sortedKeys(contents) {
+		data, err := marshal(map[string]interface{}{key: contents[key]}, format)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling: %v", err)
+		}
+		if len(current) > 0 && size+len(data) > maxBytes {
+			parts = append(parts, current)
+			current = make(map[string]interface{})
+			size = 0
+		}
+		current[key] = contents[key]
+		size += len(data)
+	}
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts, nil
+}
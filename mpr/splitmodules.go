@@ -0,0 +1,58 @@
+package mpr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportModules exports every selected module of the mpr file on its own into a directory or archive named after
+// the module directory below the output directory. Each holds only the documents of its module and metadata that
+// lists just that module, so it can be handed to the owners of the module.
+func exportModules(ctx context.Context, inputDirectory string, outputDirectory string, options ExportOptions) error {
+	log := options.logger()
+	MPRFilePath := inputDirectory
+	if input, err := os.Stat(inputDirectory); err == nil && input.IsDir() {
+		MPRFiles, err := findMPRFiles(ctx, inputDirectory, options.Skip, log)
+		if err != nil {
+			return err
+		}
+		if len(MPRFiles) != 1 {
+			return fmt.Errorf("modules can only be split for a single mpr file, found %d in %s", len(MPRFiles), inputDirectory)
+		}
+		MPRFilePath = MPRFiles[0]
+	}
+	metadataOptions := options
+	metadataOptions.MetadataOnly = true
+	file, err := readMPRFile(ctx, MPRFilePath, metadataOptions)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", MPRFilePath, err)
+	}
+	modules := exportedModules(getMxModules(file.Units, file.Version, options.Modules, log))
+	moduleDirectories, err := getModuleDirectories(modules, options.ModuleDirTemplate, log)
+	if err != nil {
+		return err
+	}
+	for _, module := range modules {
+		directory := getModuleDirectory(module.Name, moduleDirectories)
+		moduleOptions := options
+		moduleOptions.Modules = []string{module.Name}
+		if options.Archive {
+			directory += ".tar.gz"
+		}
+		moduleOutput := filepath.Join(outputDirectory, directory)
+		if strings.HasPrefix(outputDirectory, "s3://") {
+			moduleOutput = strings.TrimSuffix(outputDirectory, "/") + "/" + directory
+		}
+		if options.Sink != nil {
+			moduleOptions.Sink = newSubSink(options.Sink, directory)
+		}
+		log.Infof("Exporting module %s to %s", module.Name, directory)
+		if err := exportOutput(ctx, MPRFilePath, moduleOutput, moduleOptions); err != nil {
+			return fmt.Errorf("error exporting module %s: %v", module.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package mpr
+
+import (
+	"fmt"
+	"sort"
+)
+
+// getExportStats counts the exported modules, folders and documents and lists units that could not be
+// placed or named properly
+func getExportStats(file mprFile, folders []MxFolder, folderPaths map[string]string, documents []MxDocument, options ExportOptions) ExportStats {
+	stats := ExportStats{
+		Modules:       len(exportedModules(getMxModules(file.Units, file.Version, options.Modules, options.logger()))),
+		Documents:     len(documents),
+		DocumentTypes: make(map[string]int),
+		Warnings:      make([]string, 0),
+		SkippedUnits:  file.SkippedUnits,
+	}
+	for _, folder := range folders {
+		if folder.Parent != nil && folder.Parent.Parent != nil {
+			stats.Folders++
+		}
+	}
+	for _, document := range documents {
+		stats.DocumentTypes[document.Type]++
+	}
+
+	documentTypes := getDocumentContainments(file.Version, options)
+	for _, unit := range file.Units {
+		if !ContainsFold(documentTypes, unit.ContainmentName) {
+			continue
+		}
+		if _, ok := folderPaths[unit.ContainerID]; !ok {
+			stats.Warnings = append(stats.Warnings, fmt.Sprintf("unit %s has an unresolved parent folder %s", unit.UnitID, unit.ContainerID))
+		}
+		if name, ok := unit.Contents["Name"].(string); unit.ContainmentName == containmentName("Documents", file.Version) && (!ok || name == "") {
+			stats.Warnings = append(stats.Warnings, fmt.Sprintf("unit %s has no name", unit.UnitID))
+		}
+	}
+	return stats
+}
+
+func logExportStats(MPRFilePath string, stats ExportStats, log Logger) {
+	log.Infof("Exported %d modules, %d folders and %d documents from %s", stats.Modules, stats.Folders, stats.Documents, MPRFilePath)
+	types := make([]string, 0, len(stats.DocumentTypes))
+	for documentType := range stats.DocumentTypes {
+		types = append(types, documentType)
+	}
+	sort.Strings(types)
+	for _, documentType := range types {
+		log.Debugf("%s: %d", documentType, stats.DocumentTypes[documentType])
+	}
+	for _, warning := range stats.Warnings {
+		log.Warnf("Export warning: %s", warning)
+	}
+	if stats.SkippedUnits > 0 {
+		log.Warnf("Skipped %d units of %s that could not be decoded or were too large", stats.SkippedUnits, MPRFilePath)
+	}
+}
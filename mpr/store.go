@@ -0,0 +1,43 @@
+package mpr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeObject stores the serialized contents of the contents in a git-like object store keyed by its sha256 hash.
+// Existing objects are not rewritten, so unchanged documents across exports share storage.
+func writeObject(storeDirectory string, contents map[string]interface{}, format string, log Logger) (string, error) {
+	yamlstring, err := marshal(contents, format)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling: %v", err)
+	}
+	sum := sha256.Sum256(yamlstring)
+	hash := hex.EncodeToString(sum[:])
+
+	directory := filepath.Join(storeDirectory, hash[:2])
+	objectPath := filepath.Join(directory, hash[2:])
+	if _, err := os.Stat(objectPath); err == nil {
+		log.Debugf("Object %s already stored", hash)
+		return hash, nil
+	}
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return "", fmt.Errorf("error creating directory: %v", err)
+	}
+	if err := os.WriteFile(objectPath, yamlstring, 0644); err != nil {
+		return "", fmt.Errorf("error writing object: %v", err)
+	}
+	return hash, nil
+}
+
+// writeManifest writes the mapping of logical document paths to object hashes
+func writeManifest(output Sink, manifest map[string]string, format string, log Logger) error {
+	contents := make(map[string]interface{}, len(manifest))
+	for documentPath, hash := range manifest {
+		contents[documentPath] = hash
+	}
+	return writeFile(output, "manifest."+fileExtension(format), contents, format, log)
+}
@@ -0,0 +1,80 @@
+package mpr
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// resolveCaptions replaces every Texts$Text object by a single caption. The languages are tried in order;
+// a language matches its full code (en_US) or just the language part (en), and "*" matches any language.
+func resolveCaptions(value interface{}, languages []string) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		if v["$Type"] == "Texts$Text" {
+			return resolveCaption(v, languages)
+		}
+		for key, item := range v {
+			v[key] = resolveCaptions(item, languages)
+		}
+	case map[string]interface{}:
+		if v["$Type"] == "Texts$Text" {
+			return resolveCaption(v, languages)
+		}
+		for key, item := range v {
+			v[key] = resolveCaptions(item, languages)
+		}
+	case primitive.A:
+		for i, item := range v {
+			v[i] = resolveCaptions(item, languages)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = resolveCaptions(item, languages)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			resolveCaptions(item, languages)
+		}
+	}
+	return value
+}
+
+func resolveCaption(text map[string]interface{}, languages []string) string {
+	translations := getTranslations(text)
+	for _, language := range languages {
+		for _, translation := range translations {
+			if matchesLanguage(translation.LanguageCode, language) && translation.Text != "" {
+				return translation.Text
+			}
+		}
+	}
+	return ""
+}
+
+type translation struct {
+	LanguageCode string
+	Text         string
+}
+
+func getTranslations(text map[string]interface{}) []translation {
+	translations := make([]translation, 0)
+	for _, itemMap := range getObjectList(text["Items"]) {
+		t := translation{}
+		t.LanguageCode, _ = itemMap["LanguageCode"].(string)
+		t.Text, _ = itemMap["Text"].(string)
+		translations = append(translations, t)
+	}
+	return translations
+}
+
+func matchesLanguage(languageCode string, language string) bool {
+	if language == "*" {
+		return true
+	}
+	if strings.EqualFold(languageCode, language) {
+		return true
+	}
+	return strings.EqualFold(strings.SplitN(languageCode, "_", 2)[0], language)
+}
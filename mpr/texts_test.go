@@ -0,0 +1,38 @@
+// texts_test.go
+package mpr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCaptionFallback(t *testing.T) {
+	text := func() bson.M {
+		return bson.M{
+			"$Type": "Texts$Text",
+			"Items": bson.A{
+				bson.M{"$Type": "Texts$Translation", "LanguageCode": "nl_NL", "Text": "Tekst"},
+				bson.M{"$Type": "Texts$Translation", "LanguageCode": "en_US", "Text": "Text"},
+			},
+		}
+	}
+	t.Run("first-language-available", func(t *testing.T) {
+		result := resolveCaptions(bson.M{"Caption": text()}, []string{"nl", "en"}).(bson.M)
+		if result["Caption"] != "Tekst" {
+			t.Errorf("Unexpected caption. Got: %v", result["Caption"])
+		}
+	})
+	t.Run("fallback-language", func(t *testing.T) {
+		result := resolveCaptions(bson.M{"Caption": text()}, []string{"de", "en_US"}).(bson.M)
+		if result["Caption"] != "Text" {
+			t.Errorf("Unexpected caption. Got: %v", result["Caption"])
+		}
+	})
+	t.Run("any-language", func(t *testing.T) {
+		result := resolveCaptions(bson.M{"Caption": text()}, []string{"de", "*"}).(bson.M)
+		if result["Caption"] != "Tekst" {
+			t.Errorf("Unexpected caption. Got: %v", result["Caption"])
+		}
+	})
+}
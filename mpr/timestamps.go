@@ -0,0 +1,57 @@
+package mpr
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// getTimestampFormat returns the location and layout for timestamps, defaulting to UTC and RFC3339
+func getTimestampFormat(options ExportOptions) (*time.Location, string, error) {
+	location := time.UTC
+	if options.TimeZone != "" {
+		loc, err := time.LoadLocation(options.TimeZone)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid timezone %s: %v", options.TimeZone, err)
+		}
+		location = loc
+	}
+	layout := time.RFC3339
+	if options.TimeFormat != "" {
+		layout = options.TimeFormat
+	}
+	return location, layout, nil
+}
+
+// formatTimestamps replaces BSON dates by strings in the given location and layout
+func formatTimestamps(value interface{}, location *time.Location, layout string) interface{} {
+	switch v := value.(type) {
+	case primitive.DateTime:
+		return v.Time().In(location).Format(layout)
+	case time.Time:
+		return v.In(location).Format(layout)
+	case bson.M:
+		for key, item := range v {
+			v[key] = formatTimestamps(item, location, layout)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = formatTimestamps(item, location, layout)
+		}
+	case primitive.A:
+		for i, item := range v {
+			v[i] = formatTimestamps(item, location, layout)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = formatTimestamps(item, location, layout)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			formatTimestamps(item, location, layout)
+		}
+	}
+	return value
+}
@@ -0,0 +1,52 @@
+package mpr
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFormatTimestamps(t *testing.T) {
+	date := primitive.NewDateTimeFromTime(time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC))
+	t.Run("default", func(t *testing.T) {
+		location, layout, err := getTimestampFormat(ExportOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data := bson.M{"Created": date, "Items": primitive.A{bson.M{"Changed": date}}}
+		formatTimestamps(data, location, layout)
+		if data["Created"] != "2024-03-01T12:30:00Z" {
+			t.Errorf("Unexpected timestamp. Got: %v", data["Created"])
+		}
+		if data["Items"].(primitive.A)[0].(bson.M)["Changed"] != "2024-03-01T12:30:00Z" {
+			t.Errorf("Expected nested timestamp to be formatted")
+		}
+	})
+	t.Run("timezone-and-format", func(t *testing.T) {
+		location, layout, err := getTimestampFormat(ExportOptions{TimeZone: "Europe/Amsterdam", TimeFormat: "2006-01-02 15:04"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data := bson.M{"Created": date}
+		formatTimestamps(data, location, layout)
+		if data["Created"] != "2024-03-01 13:30" {
+			t.Errorf("Unexpected timestamp. Got: %v", data["Created"])
+		}
+	})
+	t.Run("invalid-timezone", func(t *testing.T) {
+		if _, _, err := getTimestampFormat(ExportOptions{TimeZone: "Nowhere/Special"}); err == nil {
+			t.Errorf("Expected error for invalid timezone")
+		}
+		outputDirectory := "./../tmp/invalid-timezone"
+		os.RemoveAll(outputDirectory)
+		if err := ExportModel("./../resources/app", outputDirectory, ExportOptions{Mode: "basic", TimeZone: "Nowhere/Special"}); err == nil {
+			t.Errorf("Expected export with an invalid timezone to fail")
+		}
+		if _, err := os.Stat(outputDirectory); !os.IsNotExist(err) {
+			t.Errorf("Expected the export to fail before writing anything")
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package mpr
+
+import (
+	"strings"
+	"sync"
+)
+
+// TransformFunc rewrites a document in advanced mode before it is exported
+type TransformFunc func(MxDocument) MxDocument
+
+// documentTransform is a transform that also gets the index of qualified document names by unit ID and the
+// logger of the export
+type documentTransform func(MxDocument, map[string]string, Logger) MxDocument
+
+// registeredTransform runs for the documents whose $Type starts with the prefix. The built-in transforms only
+// run for their exact type, e.g. not for Forms$PageTemplate.
+type registeredTransform struct {
+	typePrefix string
+	exact      bool
+	transform  documentTransform
+}
+
+var (
+	transformsMutex sync.RWMutex
+	transforms      = []registeredTransform{
+		{"Microflows$Microflow", true, transformMicroflow},
+		{"DomainModels$DomainModel", true, func(document MxDocument, _ map[string]string, log Logger) MxDocument {
+			return transformDomainModel(document, log)
+		}},
+		{"Forms$Page", true, transformPage},
+	}
+)
+
+// RegisterTransform adds a transform for the documents whose $Type starts with the prefix, e.g. Workflows$.
+// Transforms run in advanced mode in the order they are registered, after the built-in ones.
+func RegisterTransform(typePrefix string, fn TransformFunc) {
+	transformsMutex.Lock()
+	defer transformsMutex.Unlock()
+	transforms = append(transforms, registeredTransform{
+		typePrefix: typePrefix,
+		transform:  func(document MxDocument, _ map[string]string, _ Logger) MxDocument { return fn(document) },
+	})
+}
+
+// applyTransforms runs every transform registered for the type of the document
+func applyTransforms(document MxDocument, documentIndex map[string]string, log Logger) MxDocument {
+	transformsMutex.RLock()
+	defer transformsMutex.RUnlock()
+	documentType := document.Type
+	unitID := document.unitID
+	for _, registered := range transforms {
+		if documentType == registered.typePrefix || !registered.exact && strings.HasPrefix(documentType, registered.typePrefix) {
+			document = registered.transform(document, documentIndex, log)
+		}
+	}
+	// transforms of other packages cannot set the unit ID
+	document.unitID = unitID
+	return document
+}
@@ -0,0 +1,30 @@
+package mpr
+
+import "testing"
+
+func TestRegisterTransform(t *testing.T) {
+	registered := transforms
+	defer func() { transforms = registered }()
+
+	RegisterTransform("Workflows$", func(document MxDocument) MxDocument {
+		document.Attributes["Steps"] = 1
+		return document
+	})
+	RegisterTransform("Workflows$Workflow", func(document MxDocument) MxDocument {
+		document.Attributes["Steps"] = document.Attributes["Steps"].(int) + 1
+		return document
+	})
+
+	t.Run("matching prefixes in order", func(t *testing.T) {
+		document := applyTransforms(MxDocument{Name: "Approve", Type: "Workflows$Workflow", Attributes: map[string]interface{}{}}, nil, log)
+		if document.Attributes["Steps"] != 2 {
+			t.Errorf("Expected both transforms to run in order, got %v", document.Attributes["Steps"])
+		}
+	})
+	t.Run("other types", func(t *testing.T) {
+		document := applyTransforms(MxDocument{Name: "Constant", Type: "Constants$Constant", Attributes: map[string]interface{}{}}, nil, log)
+		if _, ok := document.Attributes["Steps"]; ok {
+			t.Errorf("Expected no transform to run for %s", document.Type)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package mpr
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// exportTranslations writes every translatable text of the model with all its language variants to translations.yaml.
+// Texts are identified by the file of their document and the key path of the text within it.
+func exportTranslations(documents []MxDocument, files map[string]string, output Sink, options ExportOptions, log Logger) error {
+	languages := make(map[string]bool)
+	texts := make([]map[string]interface{}, 0)
+	for _, document := range documents {
+		name, prefix := documentTextKey(document, files, options.Layout)
+		collectTranslations(document.Attributes, prefix, func(key string, text map[string]interface{}) {
+			variants := make(map[string]interface{})
+			for _, t := range getTranslations(text) {
+				if t.Text == "" {
+					continue
+				}
+				variants[t.LanguageCode] = t.Text
+				languages[t.LanguageCode] = true
+			}
+			if len(variants) == 0 {
+				return
+			}
+			texts = append(texts, map[string]interface{}{
+				"Document":     name,
+				"Key":          key,
+				"Translations": variants,
+			})
+		})
+	}
+	log.Infof("Found %d translatable texts", len(texts))
+
+	return writeFile(output, "translations."+fileExtension(options.Format), map[string]interface{}{
+		"Languages": sortedBoolKeys(languages),
+		"Texts":     texts,
+	}, options.Format, log)
+}
+
+// exportLanguageTexts writes a texts.<language>.yaml per language that maps the key of every translatable text to
+// its text in that language. The key is the document file and the key path of the text within it, so it stays the
+// same between exports as long as the document is not moved or renamed.
+func exportLanguageTexts(documents []MxDocument, files map[string]string, output Sink, options ExportOptions, log Logger) error {
+	languages := make(map[string]map[string]interface{})
+	for _, document := range documents {
+		name, prefix := documentTextKey(document, files, options.Layout)
+		collectTranslations(document.Attributes, prefix, func(key string, text map[string]interface{}) {
+			for _, t := range getTranslations(text) {
+				if t.Text == "" {
+					continue
+				}
+				if languages[t.LanguageCode] == nil {
+					languages[t.LanguageCode] = make(map[string]interface{})
+				}
+				languages[t.LanguageCode][name+"#"+key] = t.Text
+			}
+		})
+	}
+
+	for language, texts := range languages {
+		log.Infof("Found %d texts in %s", len(texts), language)
+		fname := fmt.Sprintf("texts.%s.%s", sanitizeFilename(language), fileExtension(options.Format))
+		if err := writeFile(output, fname, texts, options.Format, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentTextKey returns the file of a document and the key path of its attributes within that file. In the
+// flat-module layout the documents are list items of their module file, identified by name like other list items.
+func documentTextKey(document MxDocument, files map[string]string, layout string) (string, string) {
+	id, _ := idString(document.Attributes["$ID"])
+	if layout != "flat-module" {
+		return files[id], ""
+	}
+	name := document.Name
+	if name == "" {
+		name = document.Type
+	}
+	return files[id], fmt.Sprintf("[%s].Attributes", name)
+}
+
+// collectTranslations calls found for every Texts$Text object with its dotted key path. List items are
+// identified by their Name or InternalKey when they have one and by their position otherwise.
+func collectTranslations(value interface{}, key string, found func(string, map[string]interface{})) {
+	switch v := value.(type) {
+	case bson.M:
+		collectTranslations(map[string]interface{}(v), key, found)
+	case map[string]interface{}:
+		if v["$Type"] == "Texts$Text" {
+			found(key, v)
+			return
+		}
+		for _, k := range sortedKeys(v) {
+			collectTranslations(v[k], joinKey(key, k), found)
+		}
+	case primitive.A:
+		collectTranslations([]interface{}(v), key, found)
+	case []interface{}:
+		i := 0
+		for _, item := range v {
+			if _, ok := item.(int32); ok {
+				// array type marker
+				continue
+			}
+			collectTranslations(item, listItemKey(key, i, item), found)
+			i++
+		}
+	case []map[string]interface{}:
+		for i, item := range v {
+			collectTranslations(item, listItemKey(key, i, item), found)
+		}
+	}
+}
+
+func listItemKey(key string, index int, item interface{}) string {
+	if obj, ok := getObject(item); ok {
+		for _, field := range []string{"Name", "InternalKey"} {
+			if name, ok := obj[field].(string); ok && name != "" {
+				return fmt.Sprintf("%s[%s]", key, name)
+			}
+		}
+	}
+	return fmt.Sprintf("%s[%d]", key, index)
+}
+
+func joinKey(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return strings.Join([]string{path, key}, ".")
+}
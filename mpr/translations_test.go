@@ -0,0 +1,81 @@
+package mpr
+
+import (
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestMPRTranslations(t *testing.T) {
+	t.Run("single-mpr", func(t *testing.T) {
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", "./../tmp", ExportOptions{Mode: "basic", Translations: true}); err != nil {
+			t.Errorf("Failed to export units from MPR file: %v", err)
+		}
+		translationsFile, err := os.ReadFile("./../tmp/translations.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read translations file: %v", err)
+		}
+		var translations struct {
+			Languages []string `yaml:"Languages"`
+			Texts     []struct {
+				Document     string            `yaml:"Document"`
+				Key          string            `yaml:"Key"`
+				Translations map[string]string `yaml:"Translations"`
+			} `yaml:"Texts"`
+		}
+		if err := yaml.Unmarshal(translationsFile, &translations); err != nil {
+			t.Fatalf("Failed to unmarshal translations file: %v", err)
+		}
+		if len(translations.Languages) == 0 || len(translations.Texts) == 0 {
+			t.Fatalf("Expected languages and texts")
+		}
+		for _, text := range translations.Texts {
+			if text.Document == "" || text.Key == "" || len(text.Translations) == 0 {
+				t.Errorf("Incomplete text: %v", text)
+				break
+			}
+		}
+	})
+}
+
+func TestMPRLanguageTexts(t *testing.T) {
+	t.Run("texts-per-language", func(t *testing.T) {
+		outputDirectory := "./../tmp/language-texts"
+		os.RemoveAll(outputDirectory)
+		if err := exportTestUnits(t, "./../resources/app/App.mpr", outputDirectory, ExportOptions{Mode: "basic", Translations: true, LanguageTexts: true}); err != nil {
+			t.Fatalf("Failed to export units from MPR file: %v", err)
+		}
+		translationsFile, err := os.ReadFile(outputDirectory + "/translations.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read translations file: %v", err)
+		}
+		var translations struct {
+			Languages []string `yaml:"Languages"`
+			Texts     []struct {
+				Document     string            `yaml:"Document"`
+				Key          string            `yaml:"Key"`
+				Translations map[string]string `yaml:"Translations"`
+			} `yaml:"Texts"`
+		}
+		if err := yaml.Unmarshal(translationsFile, &translations); err != nil {
+			t.Fatalf("Failed to unmarshal translations file: %v", err)
+		}
+		for _, language := range translations.Languages {
+			textsFile, err := os.ReadFile(outputDirectory + "/texts." + language + ".yaml")
+			if err != nil {
+				t.Fatalf("Expected texts of %s: %v", language, err)
+			}
+			var texts map[string]string
+			if err := yaml.Unmarshal(textsFile, &texts); err != nil {
+				t.Fatalf("Failed to unmarshal texts of %s: %v", language, err)
+			}
+			for _, text := range translations.Texts {
+				if expected, ok := text.Translations[language]; ok && texts[text.Document+"#"+text.Key] != expected {
+					t.Errorf("Unexpected %s text of %s#%s. Got: %s", language, text.Document, text.Key, texts[text.Document+"#"+text.Key])
+					break
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package mpr
+
+import (
+	"sort"
+)
+
+// exportTree writes tree.yaml with the nested modules and folders of the project, built from the parent
+// references of the folders. Folders whose parent could not be resolved are listed under Unresolved.
+func exportTree(folders []MxFolder, output Sink, format string, log Logger) error {
+	children := make(map[string][]MxFolder)
+	var roots, orphans []MxFolder
+	for _, folder := range folders {
+		switch {
+		case folder.Parent != nil:
+			children[folder.Parent.ID] = append(children[folder.Parent.ID], folder)
+		case folder.Attributes["$Type"] == "Projects$Project":
+			roots = append(roots, folder)
+		default:
+			log.Warnf("Folder %s (%s) has no resolved parent %s", folder.Name, folder.ID, folder.ParentID)
+			orphans = append(orphans, folder)
+		}
+	}
+
+	tree := make([]map[string]interface{}, 0)
+	for _, root := range roots {
+		tree = append(tree, getTreeNodes(children[root.ID], children, nil)...)
+	}
+	unresolved := getTreeNodes(orphans, children, nil)
+
+	return writeFile(output, "tree."+fileExtension(format), map[string]interface{}{
+		"Folders":    tree,
+		"Unresolved": unresolved,
+	}, format, log)
+}
+
+// getTreeNodes returns the folders sorted by name with their nested folders. The chain of visited folder IDs
+// stops at cycles.
+func getTreeNodes(folders []MxFolder, children map[string][]MxFolder, chain []string) []map[string]interface{} {
+	nodes := make([]map[string]interface{}, 0, len(folders))
+	for _, folder := range folders {
+		if Contains(chain, folder.ID) {
+			continue
+		}
+		node := map[string]interface{}{
+			"Name":    folder.Name,
+			"Folders": getTreeNodes(children[folder.ID], children, append(chain, folder.ID)),
+		}
+		if folder.Attributes["$Type"] == "Projects$ModuleImpl" {
+			node["Module"] = true
+		}
+		nodes = append(nodes, node)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i]["Name"].(string) < nodes[j]["Name"].(string)
+	})
+	return nodes
+}
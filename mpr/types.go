@@ -1,5 +1,185 @@
 package mpr
 
+import (
+	"encoding/xml"
+	"time"
+)
+
+type ExportOptions struct {
+	// Mode is basic (the default) for documents as they are stored, advanced for documents transformed for
+	// readability, headers for only a catalog of the documents or schema-stats for only the key paths of the units
+	Mode string
+	// Format is the format of the written files: yaml (the default), json, xml or properties
+	Format string
+	// Raw keeps all attributes of the documents as they are in the model instead of cleaning them
+	Raw bool
+
+	// Skip are patterns as in path.Match of file and directory names below the input directory that are not searched
+	// for mpr files. Nil means DefaultSkipPaths
+	Skip []string
+	// Modules restricts the export to modules matching these names, e.g. MyModule or My*
+	Modules []string
+	// IncludeContainments exports units with these containment names in addition to the defaults
+	IncludeContainments []string
+	// ReplaceContainments exports only the units with the IncludeContainments containment names
+	ReplaceContainments bool
+	// ExcludeTypes skips documents of these types, e.g. Projects$ModuleSettings or Projects
+	ExcludeTypes []string
+	// NameFilter is a regular expression documents names must match to be exported
+	NameFilter string
+	// Since would only export documents changed after this time. mpr files do not record modification times, so
+	// setting it fails the export with ErrSinceNotSupported
+	Since time.Time
+	// MaxUnitBytes is the size of the BSON contents above which a unit is skipped without decoding it, or fails
+	// the export in strict mode. 0 disables it
+	MaxUnitBytes int
+	// MetadataOnly writes only the metadata with the modules and versions. Only module units are read from the mpr
+	// file and no documents are exported
+	MetadataOnly bool
+
+	// Layout is tree (default) for a file per document in the folder structure, flat-module for a file per module
+	// or by-type for the folder structure below a directory per document type
+	Layout string
+	// RootFolderName is the name of the project folder that contains the modules. Empty by default, so project
+	// documents are written to the output directory itself
+	RootFolderName string
+	// ModuleDirTemplate is a text/template for the directory of every module with the fields Name, Version, Source
+	// and Attributes of the module unit. Modules whose template references a missing attribute keep their name
+	ModuleDirTemplate string
+	// FileNameTemplate is a text/template for the file names of named documents relative to their folder, with the
+	// fields Name, Type and Path. The extension is appended. Defaults to {{.Name}}.{{.Type}}
+	FileNameTemplate string
+	// OnCollision decides what happens when documents resolve to the same file: error (default), suffix or overwrite
+	OnCollision string
+	// OnDuplicateModule decides what happens when modules share a name: warn (default), error or suffix
+	OnDuplicateModule string
+	// MergeMetadata exports multiple mpr files into the same output directory with a single metadata file keyed by
+	// mpr file. By default every mpr file is exported into a subdirectory named after it.
+	MergeMetadata bool
+	// SplitModules exports every selected module on its own into a directory named after the module below the
+	// output directory, or an archive with Archive. Each holds only the documents of the module and metadata
+	// listing just that module. The input must hold a single mpr file
+	SplitModules bool
+	// MaxFileBytes is the serialized size above which a document is split into part files. 0 disables it
+	MaxFileBytes int
+
+	// StripKeys are patterns as in path.Match of attribute keys removed from the output. Nil means DefaultStripKeys
+	StripKeys []string
+	// Redact holds regular expressions on dotted key paths whose values are replaced by a placeholder in every
+	// document and every output built from the documents. Links and GraphML cannot be combined with it
+	Redact []string
+	// NormalizeIDs writes all identifiers as lowercase hex UUIDs instead of base64 strings and binary values
+	NormalizeIDs bool
+	// IDEncoding is the encoding of unit and container IDs: base64 (the default), base64url or hex
+	IDEncoding string
+	// IncludeIDs adds the _UnitID and _ContainerID of the unit to every document
+	IncludeIDs bool
+	// TimeZone is the IANA name of the timezone timestamps are written in. Defaults to UTC. Raw exports keep
+	// timestamps as they are
+	TimeZone string
+	// TimeFormat is the Go layout timestamps are written with. Defaults to RFC3339
+	TimeFormat string
+	// CaptionLanguages is the fallback chain used to resolve translated texts, e.g. nl, en, *
+	CaptionLanguages []string
+	// MaxStringLength is the number of characters above which strings are handled according to LargeStrings. 0 disables it
+	MaxStringLength int
+	// LargeStrings is either truncate or externalize, which writes large strings to sidecar files
+	LargeStrings string
+	// Delta only writes the attributes that differ from the most common value of their type across the model. The
+	// defaults per type are written to defaults.yaml, so the left out attributes can be restored
+	Delta bool
+	// ResolveReferences adds the name and type of the target document next to document IDs in microflows and
+	// pages. Requires advanced mode
+	ResolveReferences bool
+	// EmbedMetrics adds the microflow metrics as Metrics to every exported microflow. Requires advanced mode
+	EmbedMetrics bool
+
+	// EmitDiagrams writes a Mermaid flowchart next to every microflow in advanced mode
+	EmitDiagrams bool
+	// EmitBSON writes the original BSON of every document to a .bson file next to it. It cannot be combined with
+	// Redact or StripKeys other than DefaultStripKeys
+	EmitBSON bool
+	// EmitTree writes tree.yaml with the nested module and folder names of the project
+	EmitTree bool
+	// EntitiesSummary writes entities.yaml with the entities, attributes and associations of all domain models
+	EntitiesSummary bool
+	// GraphML writes graph.graphml with the object reference graph of the whole model
+	GraphML bool
+	// Links writes links.yaml with every resolved reference as source document, source field and target document
+	Links bool
+	// PublicAPI writes a PublicAPI.yaml per module with its exposed microflows, Java actions, services and entities
+	PublicAPI bool
+	// Translations writes translations.yaml with every translatable text in all its languages
+	Translations bool
+	// LanguageTexts writes a texts.<language>.yaml per language with the text of every translatable text by key
+	LanguageTexts bool
+	// MicroflowMetrics writes microflow-metrics.yaml with the activities, decisions, loops and cyclomatic
+	// complexity of every microflow. Requires advanced mode
+	MicroflowMetrics bool
+	// CodeOwners is the path to a yaml file mapping module names to owning teams
+	CodeOwners string
+
+	// Sink receives the exported files instead of the output directory. An output directory of the form
+	// s3://bucket/prefix uses an S3 sink. Every file is written to the sink as it is exported
+	Sink Sink
+	// Archive streams the export into a tar.gz at the output path instead of writing loose files. It cannot be
+	// combined with Incremental
+	Archive bool
+	// EncryptKey is a passphrase every written file is encrypted with using AES-256-GCM as it is written, so no
+	// plain text copy exists. Files get an .enc suffix and the key derivation parameters are written to
+	// encryption.yaml; checksums.yaml and .gitignore are not encrypted. See DecryptExport
+	EncryptKey string
+	// ContentStore is the directory of a shared object store; documents are written there by content hash
+	// and the output directory only receives a manifest of document paths to hashes
+	ContentStore string
+	// DryRun logs the files that would be written instead of writing them
+	DryRun bool
+	// Incremental skips mpr files whose content hash matches the previous run, as recorded in export-state.yaml.
+	// The state is read back from the sink, so sinks that do not implement SinkReader export every file
+	Incremental bool
+	// Checksums records the sha256 of every written file in checksums.yaml, see VerifyExport
+	Checksums bool
+	// GitIgnore adds the auxiliary files of the export, like export-state.yaml and checksums.yaml, to the
+	// .gitignore in the output directory
+	GitIgnore bool
+
+	// Workers is the number of documents written concurrently. Defaults to the number of CPUs
+	Workers int
+	// FileWorkers is the number of mpr files exported concurrently when every file has its own subdirectory.
+	// Defaults to 1
+	FileWorkers int
+	// Progress is called after every written document with the number of documents done and the total of its mpr
+	// file. Calls never overlap, but with FileWorkers the calls for different mpr files are interleaved
+	Progress func(done, total int)
+
+	// Strict fails the export when documents are not attached to any module or the project, or when a unit is
+	// skipped because its BSON cannot be decoded or is larger than MaxUnitBytes
+	Strict bool
+	// StrictFolders fails the export when the parent of a folder is missing instead of logging a warning
+	StrictFolders bool
+	// ContinueOnError keeps exporting the remaining mpr files when one fails; the errors are returned at the end
+	ContinueOnError bool
+	// ValidateRoundtrip parses every written document and the metadata again and fails the export when a value
+	// does not survive serialization
+	ValidateRoundtrip bool
+	// LockRetries is the number of times reading a locked mpr file is retried with backoff
+	LockRetries int
+	// LockTimeout is the maximum time spent retrying a locked mpr file. 0 means only LockRetries applies
+	LockTimeout time.Duration
+
+	// Logger receives the log entries of the export. Defaults to the logger set with SetLogger
+	Logger Logger
+	// LogFile also writes the info, warning and error logs of the run as ndjson to export.log, with the mpr file
+	// and the unit or document every entry is about
+	LogFile bool
+}
+
+// ImportOptions configures ImportModel
+type ImportOptions struct {
+	// Logger receives the log entries of the import. Defaults to the logger set with SetLogger
+	Logger Logger
+}
+
 type MxMetadata struct {
 	ProductVersion string     `yaml:"ProductVersion"`
 	BuildVersion   string     `yaml:"BuildVersion"`
@@ -11,6 +191,32 @@ type MxUnit struct {
 	ContainerID     string                 `yaml:"ContainerID"`
 	ContainmentName string                 `yaml:"ContainmentName"`
 	Contents        map[string]interface{} `yaml:"Contents"`
+	// RawContents holds the BSON of the unit as stored in the mpr file when ExportOptions.EmitBSON is set
+	RawContents []byte `yaml:"-"`
+	// rawUnitID is the unit ID as stored in the mpr file, before it is encoded
+	rawUnitID []byte
+}
+
+// ExportStats summarizes an export of a single mpr file
+type ExportStats struct {
+	Modules       int
+	Folders       int
+	Documents     int
+	DocumentTypes map[string]int
+	Warnings      []string
+	Metadata      MxMetadata
+	// SkippedUnits is the number of units whose BSON could not be decoded or was larger than MaxUnitBytes
+	SkippedUnits int
+}
+
+// mprFile holds the contents of an mpr file that are needed for an export
+type mprFile struct {
+	Path           string
+	ProductVersion string
+	BuildVersion   string
+	Version        MxVersion
+	Units          []MxUnit
+	SkippedUnits   int
 }
 
 type MxDocument struct {
@@ -18,12 +224,24 @@ type MxDocument struct {
 	Type       string                 `yaml:"Type"`
 	Path       string                 `yaml:"Path"`
 	Attributes map[string]interface{} `yaml:"Attributes"`
+	// unitID is the ID of the unit of the document as stored in the mpr file
+	unitID []byte
 }
 
 type MxModule struct {
-	Name       string                 `yaml:"Name"`
-	ID         string                 `yaml:"ID"`
-	Attributes map[string]interface{} `yaml:"Attributes"`
+	Name         string                 `yaml:"Name"`
+	ID           string                 `yaml:"ID"`
+	Version      string                 `yaml:"Version"`
+	Exported     bool                   `yaml:"Exported"`
+	Source       string                 `yaml:"Source"`
+	Dependencies []MxModuleDependency   `yaml:"Dependencies"`
+	Attributes   map[string]interface{} `yaml:"Attributes"`
+}
+
+type MxModuleDependency struct {
+	GroupId    string `yaml:"GroupId"`
+	ArtifactId string `yaml:"ArtifactId"`
+	Version    string `yaml:"Version"`
 }
 
 type MxFolder struct {
@@ -67,3 +285,40 @@ type MxID struct {
 	Data    string `yaml:"Data"`
 	Subtype int    `yaml:"Subtype"`
 }
+
+type GraphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []GraphMLKey `xml:"key"`
+	Graph   GraphMLGraph `xml:"graph"`
+}
+
+type GraphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type GraphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []GraphMLNode `xml:"node"`
+	Edges       []GraphMLEdge `xml:"edge"`
+}
+
+type GraphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []GraphMLData `xml:"data"`
+}
+
+type GraphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []GraphMLData `xml:"data"`
+}
+
+type GraphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
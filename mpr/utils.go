@@ -2,26 +2,80 @@ package mpr
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"path"
 	"reflect"
+	"strings"
+	"unicode"
 
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var log = logrus.New() // Initialize with a default logger
+// Logger is the logging interface of the package. It is implemented by *logrus.Logger.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// noopLogger discards all messages, so importing the package does not log unless a logger is set
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Infof(format string, args ...interface{})  {}
+func (noopLogger) Warnf(format string, args ...interface{})  {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
+
+var log Logger = noopLogger{}
 
 // SetLogger allows the main application to set the logger, including its configuration.
-func SetLogger(logger *logrus.Logger) {
+// A nil logger silences the package.
+func SetLogger(logger Logger) {
+	if logger == nil {
+		logger = noopLogger{}
+	}
 	log = logger
 }
 
-var ignoredAttributes = []string{"$ID", "Flows", "OriginPointer", "Type", "LineType", "DestinationPointer", "Image", "ImageData", "GUID", "StableId", "Size", "RelativeMiddlePoint", "Location", "OriginBezierVector", "DestinationBezierVector", "OriginConnectionIndex", "DestinationConnectionIndex"}
+// logger returns the logger of the export, which is the package logger unless ExportOptions.Logger is set
+func (options ExportOptions) logger() Logger {
+	if options.Logger != nil {
+		return options.Logger
+	}
+	return log
+}
+
+// logger returns the logger of the import, which is the package logger unless ImportOptions.Logger is set
+func (options ImportOptions) logger() Logger {
+	if options.Logger != nil {
+		return options.Logger
+	}
+	return log
+}
+
+var ignoredAttributes = []string{"$ID", "Flows", "OriginPointer", "Type", "LineType", "DestinationPointer", "Image", "ImageData"}
+
+// DefaultStripKeys are the patterns of attributes that change on every save or only hold editor layout. They are
+// stripped from the output unless ExportOptions.StripKeys is set.
+var DefaultStripKeys = []string{"GUID", "StableId", "Size", "RelativeMiddlePoint", "Location", "*BezierVector", "*ConnectionIndex"}
 
-func Contains(slice []string, str string) bool {
+// Contains reports whether the slice holds the value
+func Contains[T comparable](slice []T, value T) bool {
 	for _, item := range slice {
-		if item == str {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsFold reports whether the slice holds the string, ignoring case
+func ContainsFold(slice []string, str string) bool {
+	for _, item := range slice {
+		if strings.EqualFold(item, str) {
 			return true
 		}
 	}
@@ -35,7 +89,7 @@ func ignoreAttributes(data bson.M, ignore []string) bson.M {
 		ignoreKey := false
 
 		for _, ignoreAttr := range ignore {
-			if key == ignoreAttr {
+			if matched, _ := path.Match(ignoreAttr, key); matched {
 				ignoreKey = true
 				break
 			}
@@ -93,16 +147,75 @@ func ignoreAttributes(data bson.M, ignore []string) bson.M {
 	return result
 }
 
-func cleanData(data bson.M, raw bool) bson.M {
+// cleanData drops the ignored attributes and the attributes matching the strip key patterns, which default to
+// DefaultStripKeys when nil. Raw data is returned as is.
+func cleanData(data bson.M, raw bool, stripKeys []string) bson.M {
 	var filteredData bson.M
 	if raw {
 		filteredData = data
 	} else {
-		filteredData = ignoreAttributes(data, ignoredAttributes)
+		if stripKeys == nil {
+			stripKeys = DefaultStripKeys
+		}
+		ignore := append(append([]string{}, ignoredAttributes...), stripKeys...)
+		filteredData, _ = decodeBinaryIDs(ignoreAttributes(data, ignore)).(bson.M)
 	}
 	return filteredData
 }
 
+// decodeBinaryIDs returns a copy of the value with 16 byte binaries written as UUIDs and object IDs as hex,
+// so references are readable in the output. Other binaries like images are kept as they are.
+func decodeBinaryIDs(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.Binary:
+		if len(v.Data) == 16 {
+			return formatUUID(v.Data)
+		}
+	case primitive.ObjectID:
+		return v.Hex()
+	case bson.M:
+		result := make(bson.M, len(v))
+		for key, item := range v {
+			result[key] = decodeBinaryIDs(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = decodeBinaryIDs(item)
+		}
+		return result
+	case primitive.A:
+		if v == nil {
+			return v
+		}
+		result := make(primitive.A, len(v))
+		for i, item := range v {
+			result[i] = decodeBinaryIDs(item)
+		}
+		return result
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = decodeBinaryIDs(item)
+		}
+		return result
+	case []map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		result := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			result[i], _ = decodeBinaryIDs(item).(map[string]interface{})
+		}
+		return result
+	}
+	return value
+}
+
 func bsonToMap(data bson.M) map[string]interface{} {
 	result := make(map[string]interface{})
 	for key, value := range data {
@@ -112,8 +225,11 @@ func bsonToMap(data bson.M) map[string]interface{} {
 			result[key] = value
 		case primitive.Binary:
 			data := value.(primitive.Binary).Data
-			encodedData := base64.StdEncoding.EncodeToString(data)
-			result[key] = encodedData
+			if len(data) == 16 {
+				result[key] = formatUUID(data)
+			} else {
+				result[key] = base64.StdEncoding.EncodeToString(data)
+			}
 		case bson.A:
 			// Handle bson.A (array) by converting to slice of interface{}
 			result[key] = convertBsonAToSliceInterface(value.(bson.A))
@@ -152,3 +268,131 @@ func convertMxMicroflowNodeToMap(node *MxMicroflowNode) map[string]interface{} {
 	c["Attributes"] = node.Attributes
 	return c
 }
+
+// formatUUID renders a 16 byte identifier as a lowercase hex UUID. Mendix stores GUIDs in
+// .NET byte order, so the first three groups are little-endian.
+func formatUUID(data []byte) string {
+	if len(data) != 16 {
+		return hex.EncodeToString(data)
+	}
+	b := []byte{
+		data[3], data[2], data[1], data[0],
+		data[5], data[4],
+		data[7], data[6],
+	}
+	b = append(b, data[8:]...)
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// normalizeUnitIDs rewrites the unit IDs and every binary ID inside the unit contents as UUID strings
+func normalizeUnitIDs(units []MxUnit) {
+	for i := range units {
+		units[i].UnitID = base64ToUUID(units[i].UnitID)
+		units[i].ContainerID = base64ToUUID(units[i].ContainerID)
+		normalizeIDs(units[i].Contents)
+	}
+}
+
+// encodeUnitID encodes a unit or container ID of the Unit table. Valid encodings are base64 (the default),
+// base64url without padding and hex; the latter two are safe in file names and URLs.
+func encodeUnitID(id []byte, encoding string) string {
+	switch encoding {
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(id)
+	case "hex":
+		return hex.EncodeToString(id)
+	}
+	return base64.StdEncoding.EncodeToString(id)
+}
+
+func base64ToUUID(id string) string {
+	data, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return id
+	}
+	return formatUUID(data)
+}
+
+func normalizeIDs(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.Binary:
+		if len(v.Data) == 16 {
+			return formatUUID(v.Data)
+		}
+	case bson.M:
+		for key, item := range v {
+			v[key] = normalizeIDs(item)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeIDs(item)
+		}
+	case primitive.A:
+		for i, item := range v {
+			v[i] = normalizeIDs(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeIDs(item)
+		}
+	}
+	return value
+}
+
+// idString returns the identifier of binary IDs and of IDs that were already normalized to strings
+func idString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case primitive.Binary:
+		return base64.StdEncoding.EncodeToString(v.Data), true
+	case string:
+		return v, true
+	}
+	return "", false
+}
+
+// getObject returns the value as an object for both decoded BSON documents and plain maps
+func getObject(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case bson.M:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// getObjectList returns the objects of a BSON array, skipping the leading array type marker
+func getObjectList(value interface{}) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0)
+	var items []interface{}
+	switch v := value.(type) {
+	case primitive.A:
+		items = v
+	case []interface{}:
+		items = v
+	case []map[string]interface{}:
+		return v
+	}
+	for _, item := range items {
+		if obj, ok := getObject(item); ok {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+// sanitizeFilename replaces path separators, control characters and characters that are not allowed in
+// file names on Windows with an underscore, so a name always maps to a single file or directory
+func sanitizeFilename(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if sanitized == ".." {
+		return "__"
+	}
+	return sanitized
+}
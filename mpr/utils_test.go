@@ -0,0 +1,120 @@
+package mpr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCleanDataBinaryIDs(t *testing.T) {
+	id := primitive.Binary{Subtype: 0, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}}
+	image := primitive.Binary{Subtype: 0, Data: []byte{0x89, 0x50, 0x4e, 0x47}}
+	data := bson.M{
+		"$Type":  "Microflows$Microflow",
+		"Entity": id,
+		"Icon":   image,
+		"Parameters": bson.A{
+			int32(3),
+			bson.M{"$Type": "Microflows$MicroflowParameter", "Target": id},
+		},
+	}
+
+	t.Run("decoded", func(t *testing.T) {
+		cleaned := cleanData(data, false, nil)
+		if cleaned["Entity"] != formatUUID(id.Data) {
+			t.Errorf("Expected entity id as UUID, got %v", cleaned["Entity"])
+		}
+		if _, ok := cleaned["Icon"].(primitive.Binary); !ok {
+			t.Errorf("Expected other binaries to be kept, got %T", cleaned["Icon"])
+		}
+		parameters := getObjectList(cleaned["Parameters"])
+		if len(parameters) != 1 || parameters[0]["Target"] != formatUUID(id.Data) {
+			t.Errorf("Expected nested id as UUID, got %v", cleaned["Parameters"])
+		}
+		if _, ok := data["Entity"].(primitive.Binary); !ok {
+			t.Errorf("Expected the original data to be left unchanged")
+		}
+	})
+	t.Run("raw", func(t *testing.T) {
+		cleaned := cleanData(data, true, nil)
+		if _, ok := cleaned["Entity"].(primitive.Binary); !ok {
+			t.Errorf("Expected raw bytes to be kept, got %T", cleaned["Entity"])
+		}
+	})
+	t.Run("nil slices", func(t *testing.T) {
+		var items []interface{}
+		if decoded := decodeBinaryIDs(items).([]interface{}); decoded != nil {
+			t.Errorf("Expected nil slice to stay nil, got %v", decoded)
+		}
+	})
+	t.Run("map", func(t *testing.T) {
+		converted := bsonToMap(data)
+		if converted["Entity"] != formatUUID(id.Data) {
+			t.Errorf("Expected entity id as UUID, got %v", converted["Entity"])
+		}
+		if converted["Icon"] != "iVBORw==" {
+			t.Errorf("Expected other binaries as base64, got %v", converted["Icon"])
+		}
+	})
+}
+
+func TestCleanDataStripKeys(t *testing.T) {
+	data := bson.M{
+		"$Type":              "Microflows$ActionActivity",
+		"Caption":            "Retrieve",
+		"Location":           "100;200",
+		"OriginBezierVector": "0;0",
+		"Action": bson.M{
+			"$Type": "Microflows$RetrieveAction",
+			"GUID":  "volatile",
+		},
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		cleaned := cleanData(data, false, nil)
+		if _, ok := cleaned["Location"]; ok {
+			t.Errorf("Expected Location to be stripped")
+		}
+		if _, ok := cleaned["OriginBezierVector"]; ok {
+			t.Errorf("Expected OriginBezierVector to match *BezierVector")
+		}
+		if _, ok := cleaned["Action"].(bson.M)["GUID"]; ok {
+			t.Errorf("Expected nested GUID to be stripped")
+		}
+	})
+	t.Run("custom", func(t *testing.T) {
+		cleaned := cleanData(data, false, []string{"Capt*"})
+		if _, ok := cleaned["Caption"]; ok {
+			t.Errorf("Expected Caption to be stripped")
+		}
+		if _, ok := cleaned["Location"]; !ok {
+			t.Errorf("Expected the defaults to be replaced")
+		}
+	})
+	t.Run("raw", func(t *testing.T) {
+		cleaned := cleanData(data, true, []string{"Caption"})
+		if _, ok := cleaned["Caption"]; !ok {
+			t.Errorf("Expected no stripping of raw data")
+		}
+	})
+}
+
+func TestContains(t *testing.T) {
+	t.Run("generic", func(t *testing.T) {
+		if !Contains([]int{1, 2, 3}, 2) || Contains([]int{1, 2, 3}, 4) {
+			t.Errorf("Unexpected result for ints")
+		}
+		if !Contains([]string{"Documents"}, "Documents") || Contains([]string{"Documents"}, "documents") {
+			t.Errorf("Expected exact match for strings")
+		}
+	})
+	t.Run("fold", func(t *testing.T) {
+		if !ContainsFold([]string{"DomainModel", "Documents"}, "domainModel") {
+			t.Errorf("Expected match ignoring case")
+		}
+		if ContainsFold([]string{"Documents"}, "Folders") {
+			t.Errorf("Unexpected match")
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package mpr
+
+import (
+	"regexp"
+	"sort"
+)
+
+// expressionVariable matches $Variable in expressions, but not the $ in qualified type names like Microflows$Microflow
+var expressionVariable = regexp.MustCompile(`(?:^|[^A-Za-z0-9_])\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// quotedLiteral matches string literals in expressions, in which a doubled quote is an escaped quote
+var quotedLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+// expressionKeys are the attributes that hold expressions or XPath constraints. Other strings, like the
+// documentation and captions, may mention $ without referencing a variable.
+var expressionKeys = []string{"Argument", "Expression", "InitialValue", "ReturnValue", "Value", "XpathConstraint"}
+
+// variableProducer is the microflow object that introduces a variable
+type variableProducer struct {
+	ID   string
+	Type string
+}
+
+// getVariableProducers maps every variable of a microflow to the parameter, action or loop that introduces it
+func getVariableProducers(objs []MxMicroflowObject, producers map[string]variableProducer, log Logger) {
+	for _, obj := range objs {
+		switch obj.Type {
+		case "Microflows$MicroflowParameter":
+			if name, ok := obj.Attributes["Name"].(string); ok && name != "" {
+				producers[name] = variableProducer{ID: obj.ID, Type: obj.Type}
+			}
+		case "Microflows$ActionActivity":
+			action, _ := obj.Attributes["Action"].(map[string]interface{})
+			actionType, _ := action["$Type"].(string)
+			for _, key := range []string{"ResultVariableName", "VariableName", "OutputVariableName"} {
+				if name, ok := action[key].(string); ok && name != "" {
+					producers[name] = variableProducer{ID: obj.ID, Type: actionType}
+				}
+			}
+		case "Microflows$LoopedActivity":
+			source, _ := obj.Attributes["LoopSource"].(map[string]interface{})
+			if name, ok := source["VariableName"].(string); ok && name != "" {
+				producers[name] = variableProducer{ID: obj.ID, Type: obj.Type}
+			}
+			collection, _ := obj.Attributes["ObjectCollection"].(map[string]interface{})
+			rawObjs, _ := collection["Objects"].([]interface{})
+			getVariableProducers(convertToMxMicroflowObjects(rawObjs, log), producers, log)
+		}
+	}
+}
+
+// transformVariableReferences annotates every node whose expressions reference variables with those variables
+// and the object producing them. System variables like $currentUser have no producer.
+func transformVariableReferences(value interface{}, producers map[string]variableProducer) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if attributes, ok := v["Attributes"].(map[string]interface{}); ok {
+			names := make(map[string]bool)
+			collectExpressionVariables(attributes, "", names)
+			if len(names) > 0 {
+				references := make([]map[string]interface{}, 0, len(names))
+				for _, name := range sortedBoolKeys(names) {
+					reference := map[string]interface{}{"Variable": name}
+					if producer, ok := producers[name]; ok {
+						reference["ProducedBy"] = producer.ID
+						reference["ProducerType"] = producer.Type
+					}
+					references = append(references, reference)
+				}
+				v["VariableReferences"] = references
+			}
+		}
+		for key, item := range v {
+			if key != "Attributes" {
+				transformVariableReferences(item, producers)
+			}
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			transformVariableReferences(item, producers)
+		}
+	case []interface{}:
+		for _, item := range v {
+			transformVariableReferences(item, producers)
+		}
+	}
+}
+
+// collectExpressionVariables adds the variables referenced by the expressions below the value, which is found
+// under key. String literals in the expressions are skipped.
+func collectExpressionVariables(value interface{}, key string, names map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		if !Contains(expressionKeys, key) {
+			return
+		}
+		for _, match := range expressionVariable.FindAllStringSubmatch(quotedLiteral.ReplaceAllString(v, "''"), -1) {
+			names[match[1]] = true
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			collectExpressionVariables(item, k, names)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			collectExpressionVariables(item, key, names)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectExpressionVariables(item, key, names)
+		}
+	}
+}
+
+func sortedBoolKeys(data map[string]bool) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package mpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MxVersion is the major and minor part of the Mendix version a model was saved with.
+// The zero value stands for an unknown version and is handled like the latest version.
+type MxVersion struct {
+	Major int
+	Minor int
+}
+
+// parseProductVersion parses the major and minor version from a product version like 10.6.1.24967
+func parseProductVersion(productVersion string) (MxVersion, error) {
+	parts := strings.Split(productVersion, ".")
+	if len(parts) < 2 {
+		return MxVersion{}, fmt.Errorf("invalid product version %s", productVersion)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return MxVersion{}, fmt.Errorf("invalid product version %s: %v", productVersion, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return MxVersion{}, fmt.Errorf("invalid product version %s: %v", productVersion, err)
+	}
+	return MxVersion{Major: major, Minor: minor}, nil
+}
+
+// getMxVersion parses the product version, falling back to an unknown version when it can't be parsed
+func getMxVersion(productVersion string, log Logger) MxVersion {
+	version, err := parseProductVersion(productVersion)
+	if err != nil {
+		log.Warnf("Unknown product version %s, assuming the latest model layout: %v", productVersion, err)
+	}
+	return version
+}
+
+// containmentName returns the containment name as it is stored in models of the version. Mendix 8 and earlier
+// store them in camel case, like moduleSettings. Unknown versions use the names of the latest version.
+func containmentName(name string, version MxVersion) string {
+	if version.Major == 0 || version.Major >= 9 {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// containmentNames returns the containment names as they are stored in models of the version
+func containmentNames(version MxVersion, names ...string) []string {
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = containmentName(name, version)
+	}
+	return result
+}
+
+// documentContainmentNames returns the containment names of the units exported as documents
+func documentContainmentNames(version MxVersion) []string {
+	return containmentNames(version, "ProjectDocuments", "DomainModel", "ModuleSettings", "ModuleSecurity", "Documents")
+}
+
+// getDocumentContainments returns the containment names to export: the defaults of the version extended with
+// options.IncludeContainments, or only options.IncludeContainments when options.ReplaceContainments is set
+func getDocumentContainments(version MxVersion, options ExportOptions) []string {
+	if options.ReplaceContainments {
+		return options.IncludeContainments
+	}
+	return append(documentContainmentNames(version), options.IncludeContainments...)
+}
+
+// folderContainmentNames returns the containment names of the units that make up the folder tree
+func folderContainmentNames(version MxVersion) []string {
+	return containmentNames(version, "Folders", "Modules")
+}
+
+// metadataContainmentNames returns the containment names of the units the metadata is built from
+func metadataContainmentNames(version MxVersion) []string {
+	return containmentNames(version, "Modules", "ModuleSettings")
+}
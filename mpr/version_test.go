@@ -0,0 +1,79 @@
+package mpr
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProductVersion(t *testing.T) {
+	t.Run("full version", func(t *testing.T) {
+		version, err := parseProductVersion("10.6.1.24967")
+		if err != nil {
+			t.Fatalf("Failed to parse version: %v", err)
+		}
+		if version.Major != 10 || version.Minor != 6 {
+			t.Errorf("Expected 10.6, got %d.%d", version.Major, version.Minor)
+		}
+	})
+	t.Run("invalid version", func(t *testing.T) {
+		if _, err := parseProductVersion("ten"); err == nil {
+			t.Errorf("Expected error for invalid version")
+		}
+		if _, err := parseProductVersion("8.x.1"); err == nil {
+			t.Errorf("Expected error for invalid minor version")
+		}
+	})
+	t.Run("unknown version falls back", func(t *testing.T) {
+		if version := getMxVersion("", log); version != (MxVersion{}) {
+			t.Errorf("Expected unknown version, got %v", version)
+		}
+	})
+}
+
+func TestContainmentNames(t *testing.T) {
+	mendix8 := MxVersion{Major: 8, Minor: 18}
+	units := []MxUnit{
+		{UnitID: "root", ContainerID: "root", ContainmentName: "", Contents: map[string]interface{}{"$Type": "Projects$Project"}},
+		{UnitID: "module", ContainerID: "root", ContainmentName: "modules", Contents: map[string]interface{}{"$Type": "Projects$ModuleImpl", "Name": "MyFirstModule"}},
+		{UnitID: "folder", ContainerID: "module", ContainmentName: "folders", Contents: map[string]interface{}{"$Type": "Projects$Folder", "Name": "Flows"}},
+		{UnitID: "settings", ContainerID: "module", ContainmentName: "moduleSettings", Contents: map[string]interface{}{"$ID": "settings", "$Type": "Projects$ModuleSettings", "Version": "1.0.0"}},
+		{UnitID: "flow", ContainerID: "folder", ContainmentName: "documents", Contents: map[string]interface{}{"$ID": "flow", "$Type": "Microflows$Microflow", "Name": "ACT_Save"}},
+	}
+
+	t.Run("mendix 8", func(t *testing.T) {
+		folders, err := getMxFolders(units, mendix8, ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get folders: %v", err)
+		}
+		documents, err := getMxDocuments(units, getMxFolderPaths(folders, log), mendix8, ExportOptions{Mode: "basic"})
+		if err != nil {
+			t.Fatalf("Failed to get documents: %v", err)
+		}
+		paths := make(map[string]string)
+		for _, document := range documents {
+			paths[document.Type] = document.Path
+		}
+		if paths["Microflows$Microflow"] != filepath.Join("MyFirstModule", "Flows") || paths["Projects$ModuleSettings"] != "MyFirstModule" {
+			t.Errorf("Expected the documents in their module and folder, got %v", paths)
+		}
+		modules := getMxModules(units, mendix8, nil, log)
+		if len(modules) != 1 || modules[0].Name != "MyFirstModule" || modules[0].Version != "1.0.0" {
+			t.Errorf("Expected the module with its settings, got %v", modules)
+		}
+		if index := buildDocumentIndex(units, getMxFolderPaths(folders, log), mendix8); index["flow"] != "MyFirstModule.ACT_Save" {
+			t.Errorf("Expected the qualified name of the microflow, got %v", index)
+		}
+	})
+	t.Run("latest", func(t *testing.T) {
+		if names := folderContainmentNames(MxVersion{Major: 10, Minor: 6}); names[0] != "Folders" {
+			t.Errorf("Expected the names of the latest version, got %v", names)
+		}
+		folders, err := getMxFolders(units, MxVersion{}, ExportOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get folders: %v", err)
+		}
+		if len(folders) != 1 {
+			t.Errorf("Expected only the project for camel case names of an unknown version, got %v", folders)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package mpr
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// runWorkers calls fn for every item using the given number of goroutines, defaulting to the number of CPUs.
+// All errors are collected and returned together.
+func runWorkers[T any](workers int, items []T, fn func(T) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan T)
+	var errs []error
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				if err := fn(item); err != nil {
+					lock.Lock()
+					errs = append(errs, err)
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	for _, item := range items {
+		jobs <- item
+	}
+	close(jobs)
+	wg.Wait()
+	return errors.Join(errs...)
+}
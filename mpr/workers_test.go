@@ -0,0 +1,36 @@
+package mpr
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunWorkers(t *testing.T) {
+	documents := make([]MxDocument, 100)
+	for i := range documents {
+		documents[i] = MxDocument{Name: fmt.Sprintf("Doc%d", i)}
+	}
+	t.Run("all-documents", func(t *testing.T) {
+		var count int32
+		err := runWorkers(4, documents, func(document MxDocument) error {
+			atomic.AddInt32(&count, 1)
+			return nil
+		})
+		if err != nil || count != int32(len(documents)) {
+			t.Errorf("Expected all documents to be processed. Got: %d, %v", count, err)
+		}
+	})
+	t.Run("collect-errors", func(t *testing.T) {
+		err := runWorkers(0, documents, func(document MxDocument) error {
+			if document.Name == "Doc3" || document.Name == "Doc42" {
+				return fmt.Errorf("failed %s", document.Name)
+			}
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "failed Doc3") || !strings.Contains(err.Error(), "failed Doc42") {
+			t.Errorf("Expected both errors to be returned. Got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package mpr
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// marshalXML serializes the contents as an indented XML document. The contents first go through encoding/json
+// like the other formats, so every value is a map, list, string, number, bool or null. They are mapped as follows:
+//   - the root element is Metadata for the metadata of an mpr file, Documents for lists and Document otherwise
+//   - map keys become child elements in sorted order; keys starting with $ become attributes without the $,
+//     e.g. $Type becomes Type="Microflows$Microflow"
+//   - keys that are not valid element names become an entry element with the key in its Key attribute
+//   - list items repeat the element of their key; items of nested lists and of a root list are Item elements
+//   - null becomes an empty element with Nil="true"; other values become the text of their element
+func marshalXML(contents interface{}) ([]byte, error) {
+	data, err := json.Marshal(contents)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	root := "Document"
+	if _, ok := contents.(MxMetadata); ok {
+		root = "Metadata"
+	} else if _, ok := value.([]interface{}); ok {
+		root = "Documents"
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buffer)
+	encoder.Indent("", "  ")
+	if items, ok := value.([]interface{}); ok {
+		if err := encodeXMLList(encoder, xml.StartElement{Name: xml.Name{Local: root}}, "Item", items); err != nil {
+			return nil, err
+		}
+	} else if err := encodeXMLElement(encoder, xml.StartElement{Name: xml.Name{Local: root}}, value); err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	buffer.WriteString("\n")
+	return buffer.Bytes(), nil
+}
+
+// encodeXMLElement writes a value as the given element
+func encodeXMLElement(encoder *xml.Encoder, start xml.StartElement, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		children := make([]string, 0, len(keys))
+		for _, key := range keys {
+			name := strings.TrimPrefix(key, "$")
+			if name != key && isXMLName(name) && isXMLScalar(v[key]) {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: name}, Value: xmlText(v[key])})
+				continue
+			}
+			children = append(children, key)
+		}
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, key := range children {
+			child := xml.StartElement{Name: xml.Name{Local: key}}
+			if !isXMLName(key) {
+				child = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "Key"}, Value: key}}}
+			}
+			if items, ok := v[key].([]interface{}); ok {
+				for _, item := range items {
+					if err := encodeXMLListItem(encoder, child, item); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := encodeXMLElement(encoder, child, v[key]); err != nil {
+				return err
+			}
+		}
+		return encoder.EncodeToken(start.End())
+	case []interface{}:
+		return encodeXMLList(encoder, start, "Item", v)
+	case nil:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "Nil"}, Value: "true"})
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		return encoder.EncodeToken(start.End())
+	}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encoder.EncodeToken(xml.CharData(xmlText(value))); err != nil {
+		return err
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// encodeXMLListItem writes an item of a list under a map key. Items that are lists themselves are wrapped in
+// the element of the key, with Item elements inside.
+func encodeXMLListItem(encoder *xml.Encoder, start xml.StartElement, item interface{}) error {
+	if items, ok := item.([]interface{}); ok {
+		return encodeXMLList(encoder, start, "Item", items)
+	}
+	return encodeXMLElement(encoder, start, item)
+}
+
+// encodeXMLList writes the items of a list as elements with the given name inside the start element
+func encodeXMLList(encoder *xml.Encoder, start xml.StartElement, name string, items []interface{}) error {
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := encodeXMLListItem(encoder, xml.StartElement{Name: xml.Name{Local: name}}, item); err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+func isXMLScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, bool, json.Number:
+		return true
+	}
+	return false
+}
+
+func xmlText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// isXMLName reports whether the key can be used as element name as is. Names starting with xml are reserved.
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package mpr
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMarshalXML(t *testing.T) {
+	t.Run("rules", func(t *testing.T) {
+		contents := bson.M{
+			"$Type":    "Microflows$Microflow",
+			"Name":     "ACT_<Save>",
+			"Empty":    nil,
+			"Count":    int32(2),
+			"Items":    primitive.A{bson.M{"$Type": "Texts$Translation", "Text": "a"}, "b"},
+			"Nested":   primitive.A{primitive.A{"x", "y"}},
+			"with key": true,
+		}
+		data, err := marshal(contents, "xml")
+		if err != nil {
+			t.Fatalf("Failed to marshal: %v", err)
+		}
+		expected := `<?xml version="1.0" encoding="UTF-8"?>
+<Document Type="Microflows$Microflow">
+  <Count>2</Count>
+  <Empty Nil="true"></Empty>
+  <Items Type="Texts$Translation">
+    <Text>a</Text>
+  </Items>
+  <Items>b</Items>
+  <Name>ACT_&lt;Save&gt;</Name>
+  <Nested>
+    <Item>x</Item>
+    <Item>y</Item>
+  </Nested>
+  <entry Key="with key">true</entry>
+</Document>
+`
+		if string(data) != expected {
+			t.Errorf("Unexpected xml. Got:\n%s", data)
+		}
+	})
+
+	t.Run("roots", func(t *testing.T) {
+		data, err := marshal(MxMetadata{ProductVersion: "10.12.2"}, "xml")
+		if err != nil || !strings.Contains(string(data), "<Metadata>") {
+			t.Errorf("Expected a Metadata root, got %s %v", data, err)
+		}
+		data, err = marshal([]map[string]interface{}{{"Name": "a"}}, "xml")
+		if err != nil || !strings.Contains(string(data), "<Documents>\n  <Item>\n    <Name>a</Name>") {
+			t.Errorf("Expected a Documents root with items, got %s %v", data, err)
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		outputDirectory := "./../tmp/xml"
+		os.RemoveAll(outputDirectory)
+		options := ExportOptions{Mode: "basic", Format: "xml", Checksums: true}
+		if err := ExportModel("./../resources/app", outputDirectory, options); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		for _, path := range []string{"Metadata.xml", "MyFirstModule/Folder/MicroflowSimple.Microflows$Microflow.xml", "checksums.yaml"} {
+			if _, err := os.Stat(outputDirectory + "/" + path); err != nil {
+				t.Errorf("Expected %s: %v", path, err)
+			}
+		}
+		if err := VerifyExport(outputDirectory); err != nil {
+			t.Errorf("Failed to verify xml export: %v", err)
+		}
+	})
+}